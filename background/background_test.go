@@ -0,0 +1,153 @@
+package background
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-motifs/genome"
+)
+
+func kmers(seq string, k int) map[string]int {
+	counts := map[string]int{}
+
+	for i := 0; i+k <= len(seq); i++ {
+		counts[seq[i:i+k]]++
+	}
+
+	return counts
+}
+
+func TestShuffleDinucleotides(t *testing.T) {
+	seq := "ACGTTGCAAACCGGTTTACGATCGATCGGGCTAGCTAGNNACGT"
+
+	for k := 1; k <= 4; k++ {
+		rng := NewRand(1)
+		changed := false
+
+		for range 50 {
+			shuffled := ShuffleKmers(seq, k, rng)
+
+			if len(shuffled) != len(seq) ||
+				k > 1 && (shuffled[:k-1] != seq[:k-1] || shuffled[len(seq)-k+1:] != seq[len(seq)-k+1:]) {
+				t.Fatalf("k %d: bad shuffle %s", k, shuffled)
+			}
+
+			expected := kmers(seq, k)
+			found := kmers(shuffled, k)
+
+			if !maps.Equal(expected, found) {
+				t.Fatalf("k %d: %s does not keep the k-mers of %s", k, shuffled, seq)
+			}
+
+			changed = changed || shuffled != seq
+		}
+
+		if !changed {
+			t.Fatalf("k %d: sequence was never shuffled", k)
+		}
+	}
+}
+
+func TestShuffleSeed(t *testing.T) {
+	seq := "ACGTTGCAAACCGGTTTACGATCGATCGGGCTAGCTAG"
+
+	a := ShuffleDinucleotides(seq, NewRand(42))
+	b := ShuffleDinucleotides(seq, NewRand(42))
+
+	if a != b {
+		t.Fatalf("seeded shuffles differ: %s %s", a, b)
+	}
+
+	_, err := Shuffled([]*motifs.Sequence{{Name: "seq", Seq: seq}}, 0, 1, NewRand(42))
+
+	if !errors.Is(err, ErrShuffleK) {
+		t.Fatalf("expected k error, found %v", err)
+	}
+}
+
+// an in memory genome
+type testGenome map[string]string
+
+func (g testGenome) Chromosomes() []*genome.Chromosome {
+	return []*genome.Chromosome{{Name: "chr1", Length: len(g["chr1"])}, {Name: "chr2", Length: len(g["chr2"])}}
+}
+
+func (g testGenome) Seq(chr string, start int, end int) (string, error) {
+	return g[chr][start:end], nil
+}
+
+func (g testGenome) Close() error {
+	return nil
+}
+
+func TestGCMatched(t *testing.T) {
+	// an AT rich and a GC rich chromosome
+	reader := testGenome{"chr1": strings.Repeat("AATA", 500), "chr2": strings.Repeat("GGCG", 500)}
+
+	targets := []*motifs.Sequence{{Name: "at", Seq: "ATATATATAT"}, {Name: "gc", Seq: "GCGCGCGCGC"}}
+
+	opts := NewSampleOptions()
+	opts.Times = 3
+
+	bg, err := GCMatched(reader, targets, opts, NewRand(1))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bg) != 6 {
+		t.Fatalf("expected 6 sequences, found %d", len(bg))
+	}
+
+	for i, seq := range bg {
+		if len(seq.Seq) != 10 {
+			t.Fatalf("unexpected length %d", len(seq.Seq))
+		}
+
+		if i < 3 && !strings.HasPrefix(seq.Name, "chr1:") || i >= 3 && !strings.HasPrefix(seq.Name, "chr2:") {
+			t.Fatalf("sequence %d is not GC matched: %s %s", i, seq.Name, seq.Seq)
+		}
+	}
+}
+
+func TestGCMatchedExclude(t *testing.T) {
+	reader := testGenome{"chr1": strings.Repeat("ACGT", 100), "chr2": strings.Repeat("ACGT", 100)}
+
+	opts := NewSampleOptions()
+	opts.Times = 20
+	// only the last 20 bases of chr2 are left
+	opts.Exclude = []*genome.Region{{Chr: "chr1", Start: 0, End: 400},
+		{Chr: "chr2", Start: 0, End: 200},
+		{Chr: "chr2", Start: 150, End: 380}}
+
+	targets := []*motifs.Sequence{{Name: "t", Seq: "ACGTACGTAC"}}
+
+	bg, err := GCMatched(reader, targets, opts, NewRand(7))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, seq := range bg {
+		region, err := genome.ParseRegion(seq.Name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if region.Chr != "chr2" || region.Start < 380 {
+			t.Fatalf("sampled excluded region %s", seq.Name)
+		}
+	}
+
+	opts.Exclude = append(opts.Exclude, &genome.Region{Chr: "chr2", Start: 370, End: 400})
+
+	_, err = GCMatched(reader, targets, opts, NewRand(7))
+
+	if !errors.Is(err, ErrEmptyGenome) {
+		t.Fatalf("expected empty genome error, found %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+package background
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+
+	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-motifs/genome"
+)
+
+const (
+	// sampled regions must be within this GC fraction of their target
+	// unless told otherwise
+	DefaultGCTolerance = 0.025
+	// sampled regions may have at most this fraction of N bases
+	MaxNFraction = 0.1
+	// regions drawn per background sequence before settling for the
+	// closest in GC content
+	MaxSampleTries = 100
+)
+
+var (
+	ErrEmptyGenome   = errors.New("no part of the genome is left to sample regions from")
+	ErrSampleOptions = errors.New("sample times and GC tolerance must not be negative")
+)
+
+type (
+	SampleOptions struct {
+		// regions drawn per target
+		Times int `json:"times"`
+		// sampled regions must be within this GC fraction of their
+		// target
+		Tolerance float64 `json:"tolerance"`
+		// regions no sample may overlap, e.g. the targets themselves
+		// or blacklisted regions
+		Exclude []*genome.Region `json:"exclude"`
+	}
+
+	// Sampler draws random regions from a genome matching the GC
+	// content of targets
+	Sampler struct {
+		reader   genome.Reader
+		chrs     []*genome.Chromosome
+		excluded map[string]*intervals
+		// possible starts by region length
+		starts map[int]*startRuns
+		opts   *SampleOptions
+		rng    *rand.Rand
+	}
+
+	// sorted, non overlapping, end exclusive intervals on a chromosome
+	intervals struct {
+		starts []int
+		ends   []int
+	}
+
+	// runs of consecutive positions regions can start at, numbered
+	// from 0 to total across all chromosomes
+	startRuns struct {
+		chrs   []string
+		starts []int
+		// number of the first position of each run
+		offsets []int
+		total   int
+	}
+)
+
+// counts of G or C and of any of A, C, G or T, ignoring case
+func baseCounts(seq string) (int, int) {
+	gc := 0
+	acgt := 0
+
+	for i := 0; i < len(seq); i++ {
+		switch seq[i] {
+		case 'G', 'C', 'g', 'c':
+			gc++
+			acgt++
+		case 'A', 'T', 'a', 't':
+			acgt++
+		}
+	}
+
+	return gc, acgt
+}
+
+// GCContent is the fraction of G and C bases among the A, C, G and T
+// bases of a sequence, ignoring case, or 0 if there are none
+func GCContent(seq string) float64 {
+	gc, acgt := baseCounts(seq)
+
+	if acgt == 0 {
+		return 0
+	}
+
+	return float64(gc) / float64(acgt)
+}
+
+// fraction of bases that are not A, C, G or T
+func nFraction(seq string) float64 {
+	if len(seq) == 0 {
+		return 1
+	}
+
+	_, acgt := baseCounts(seq)
+
+	return 1 - float64(acgt)/float64(len(seq))
+}
+
+// NewSampleOptions returns the default options, one region per
+// target with no regions excluded
+func NewSampleOptions() *SampleOptions {
+	return &SampleOptions{Times: 1, Tolerance: DefaultGCTolerance}
+}
+
+// NewSampler creates a sampler for a genome, which is only read from
+// when regions are drawn
+func NewSampler(reader genome.Reader, opts *SampleOptions, rng *rand.Rand) (*Sampler, error) {
+	if opts == nil {
+		opts = NewSampleOptions()
+	}
+
+	if opts.Times < 0 || opts.Tolerance < 0 {
+		return nil, ErrSampleOptions
+	}
+
+	// defaults are filled in on a copy
+	filled := *opts
+
+	sampler := Sampler{reader: reader,
+		chrs:     reader.Chromosomes(),
+		excluded: make(map[string]*intervals, 25),
+		starts:   make(map[int]*startRuns, 5),
+		opts:     &filled,
+		rng:      rng}
+
+	if sampler.opts.Times == 0 {
+		sampler.opts.Times = 1
+	}
+
+	if sampler.opts.Tolerance == 0 {
+		sampler.opts.Tolerance = DefaultGCTolerance
+	}
+
+	for _, region := range opts.Exclude {
+		iv, found := sampler.excluded[region.Chr]
+
+		if !found {
+			iv = &intervals{}
+			sampler.excluded[region.Chr] = iv
+		}
+
+		iv.starts = append(iv.starts, region.Start)
+		iv.ends = append(iv.ends, region.End)
+	}
+
+	for _, iv := range sampler.excluded {
+		iv.merge()
+	}
+
+	return &sampler, nil
+}
+
+// sort intervals by start and merge those that overlap so that ends
+// are sorted too
+func (iv *intervals) merge() {
+	order := make([]int, len(iv.starts))
+
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(iv.starts[a], iv.starts[b])
+	})
+
+	starts := make([]int, 0, len(order))
+	ends := make([]int, 0, len(order))
+
+	for _, i := range order {
+		n := len(ends)
+
+		if n > 0 && iv.starts[i] <= ends[n-1] {
+			ends[n-1] = max(ends[n-1], iv.ends[i])
+			continue
+		}
+
+		starts = append(starts, iv.starts[i])
+		ends = append(ends, iv.ends[i])
+	}
+
+	iv.starts = starts
+	iv.ends = ends
+}
+
+// GCMatched draws opts.Times random regions from a genome for each
+// target, each the same length as its target and with a GC content
+// within opts.Tolerance of it, avoiding the regions in opts.Exclude.
+// If no such region is found after MaxSampleTries the closest is used.
+func GCMatched(reader genome.Reader,
+	targets []*motifs.Sequence,
+	opts *SampleOptions,
+	rng *rand.Rand) ([]*motifs.Sequence, error) {
+
+	sampler, err := NewSampler(reader, opts, rng)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*motifs.Sequence, 0, len(targets)*sampler.opts.Times)
+
+	for _, target := range targets {
+		gc := GCContent(target.Seq)
+
+		for range sampler.opts.Times {
+			seq, err := sampler.Sample(len(target.Seq), gc)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, seq)
+		}
+	}
+
+	return ret, nil
+}
+
+// Sample draws a region of a given length whose GC content is within
+// the tolerance of gc, or the closest found after MaxSampleTries.
+// Sequences are named by their 1-based location.
+func (sampler *Sampler) Sample(length int, gc float64) (*motifs.Sequence, error) {
+	starts := sampler.startRuns(length)
+
+	if starts.total == 0 {
+		return nil, ErrEmptyGenome
+	}
+
+	var best *motifs.Sequence
+	bestDiff := math.Inf(1)
+
+	for range MaxSampleTries {
+		chr, p := starts.pick(sampler.rng.IntN(starts.total))
+
+		seq, err := sampler.reader.Seq(chr, p, p+length)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if nFraction(seq) > MaxNFraction {
+			continue
+		}
+
+		diff := math.Abs(GCContent(seq) - gc)
+
+		if diff < bestDiff {
+			best = &motifs.Sequence{Name: fmt.Sprintf("%s:%d-%d", chr, p+1, p+length), Seq: seq}
+			bestDiff = diff
+		}
+
+		if bestDiff <= sampler.opts.Tolerance {
+			break
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("%w: every region sampled was mostly N", ErrEmptyGenome)
+	}
+
+	return best, nil
+}
+
+// the positions a region of a length can start at without running off
+// a chromosome or overlapping an excluded region, cached since
+// targets are often all the same length
+func (sampler *Sampler) startRuns(length int) *startRuns {
+	if runs, found := sampler.starts[length]; found {
+		return runs
+	}
+
+	runs := startRuns{}
+
+	add := func(chr string, from int, to int) {
+		if to > from {
+			runs.chrs = append(runs.chrs, chr)
+			runs.starts = append(runs.starts, from)
+			runs.offsets = append(runs.offsets, runs.total)
+			runs.total += to - from
+		}
+	}
+
+	for _, chr := range sampler.chrs {
+		from := 0
+
+		if iv, found := sampler.excluded[chr.Name]; found {
+			// regions must end before each excluded interval starts
+			// and start after it ends
+			for i, start := range iv.starts {
+				add(chr.Name, from, start-length+1)
+				from = max(from, iv.ends[i])
+			}
+		}
+
+		add(chr.Name, from, chr.Length-length+1)
+	}
+
+	sampler.starts[length] = &runs
+
+	return &runs
+}
+
+// the chromosome and start of the nth possible start
+func (runs *startRuns) pick(n int) (string, int) {
+	// last run starting at or before n
+	i := sort.SearchInts(runs.offsets, n+1) - 1
+
+	return runs.chrs[i], runs.starts[i] + n - runs.offsets[i]
+}
@@ -0,0 +1,151 @@
+// Package background generates background sequences for motif
+// enrichment by shuffling targets or sampling a genome
+package background
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+const (
+	// dinucleotide shuffles, the usual background for motif
+	// enrichment
+	DefaultShuffleK = 2
+)
+
+var (
+	ErrShuffleK = errors.New("shuffle k-mer size must be at least 1")
+)
+
+// NewRand returns a random number generator that always produces the
+// same backgrounds for the same seed, or a randomly seeded one if seed
+// is 0
+func NewRand(seed uint64) *rand.Rand {
+	if seed == 0 {
+		return rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// ShuffleDinucleotides shuffles a sequence keeping the count of each
+// pair of adjacent bases, and the first and last bases
+func ShuffleDinucleotides(seq string, rng *rand.Rand) string {
+	return ShuffleKmers(seq, DefaultShuffleK, rng)
+}
+
+// ShuffleKmers shuffles a sequence keeping the count of each k-mer,
+// and the first and last k-1 bases, using the Altschul-Erickson
+// algorithm as generalised by uShuffle. k of 1 shuffles the bases
+// freely. Case is preserved, so soft-masked bases count as different
+// letters.
+func ShuffleKmers(seq string, k int, rng *rand.Rand) string {
+	n := len(seq)
+
+	if k <= 1 {
+		ret := []byte(seq)
+
+		rng.Shuffle(n, func(i, j int) {
+			ret[i], ret[j] = ret[j], ret[i]
+		})
+
+		return string(ret)
+	}
+
+	if n <= k {
+		return seq
+	}
+
+	// the sequence is a walk through the graph of its (k-1)-mers, with
+	// an edge for each k-mer labelled by the base it adds. Edges out
+	// of each (k-1)-mer are listed in the order they are used.
+	edges := make(map[string][]byte, 64)
+
+	for i := 0; i+k <= n; i++ {
+		v := seq[i : i+k-1]
+		edges[v] = append(edges[v], seq[i+k-1])
+	}
+
+	first := seq[:k-1]
+	last := seq[n-k+1:]
+
+	// (k-1)-mers are visited in a fixed order so that a seeded rng
+	// always gives the same shuffle
+	vertices := slices.Sorted(maps.Keys(edges))
+
+	// the last edge out of every (k-1)-mer except the final one must
+	// form a tree leading to the final (k-1)-mer, or the walk would
+	// get stuck before using every edge. Wilson's algorithm picks a
+	// random tree with loop erased random walks.
+	lastEdges := make(map[string]int, len(vertices))
+	inTree := map[string]bool{last: true}
+
+	for _, v := range vertices {
+		for u := v; !inTree[u]; {
+			lastEdges[u] = rng.IntN(len(edges[u]))
+			u = u[1:] + string(edges[u][lastEdges[u]])
+		}
+
+		for u := v; !inTree[u]; {
+			inTree[u] = true
+			u = u[1:] + string(edges[u][lastEdges[u]])
+		}
+	}
+
+	for _, v := range vertices {
+		out := edges[v]
+		final := len(out)
+
+		if v != last {
+			// move the chosen last edge to the end and shuffle the
+			// rest
+			final = len(out) - 1
+			out[lastEdges[v]], out[final] = out[final], out[lastEdges[v]]
+		}
+
+		rng.Shuffle(final, func(i, j int) {
+			out[i], out[j] = out[j], out[i]
+		})
+	}
+
+	ret := make([]byte, 0, n)
+	ret = append(ret, first...)
+
+	used := make(map[string]int, len(vertices))
+	v := first
+
+	for len(ret) < n {
+		b := edges[v][used[v]]
+		used[v]++
+		ret = append(ret, b)
+		v = v[1:] + string(b)
+	}
+
+	return string(ret)
+}
+
+// Shuffled returns times k-mer shuffles of each target to use as a
+// background. Shuffles are named after their target.
+func Shuffled(targets []*motifs.Sequence, k int, times int, rng *rand.Rand) ([]*motifs.Sequence, error) {
+	if k < 1 {
+		return nil, ErrShuffleK
+	}
+
+	times = max(times, 1)
+
+	ret := make([]*motifs.Sequence, 0, len(targets)*times)
+
+	for _, target := range targets {
+		for i := range times {
+			ret = append(ret, &motifs.Sequence{Name: fmt.Sprintf("%s_shuffle%d", target.Name, i+1),
+				Seq: ShuffleKmers(target.Seq, k, rng)})
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,705 @@
+package build
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-motifs/homer"
+	"github.com/antonybholmes/go-motifs/jaspar"
+	"github.com/antonybholmes/go-motifs/meme"
+	"github.com/antonybholmes/go-motifs/transfac"
+	"github.com/antonybholmes/go-sys/db"
+	"github.com/antonybholmes/go-sys/log"
+	"github.com/google/uuid"
+)
+
+type (
+	// A collection of motifs that will be stored as one dataset
+	Dataset struct {
+		Name string
+		// A, C, G, T background frequencies from the file header
+		Background []float64
+		Motifs     []*motifs.Motif
+	}
+)
+
+const (
+	SchemaSql = `PRAGMA foreign_keys = ON;
+
+	CREATE TABLE datasets (
+		id INTEGER PRIMARY KEY,
+		public_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		bg_a REAL NOT NULL DEFAULT 0.25,
+		bg_c REAL NOT NULL DEFAULT 0.25,
+		bg_g REAL NOT NULL DEFAULT 0.25,
+		bg_t REAL NOT NULL DEFAULT 0.25);
+	CREATE INDEX idx_datasets_name ON datasets (LOWER(name));
+
+	CREATE TABLE species (
+		taxon_id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL);
+
+	-- taxon_id is 0 if the species is unknown
+	CREATE TABLE genes (
+		id INTEGER PRIMARY KEY,
+		public_id TEXT NOT NULL,
+		name TEXT NOT NULL UNIQUE,
+		taxon_id INTEGER NOT NULL DEFAULT 0);
+	CREATE INDEX idx_genes_public_id ON genes (public_id);
+	CREATE INDEX idx_genes_name ON genes (LOWER(name));
+
+	CREATE TABLE motifs (
+		id INTEGER PRIMARY KEY,
+		public_id TEXT NOT NULL,
+		dataset_id INTEGER NOT NULL,
+		motif_id TEXT NOT NULL,
+		motif_name TEXT NOT NULL,
+		length INTEGER NOT NULL,
+		taxon_id INTEGER NOT NULL DEFAULT 0,
+		-- sites the matrix was built from, 0 if unknown
+		nsites REAL NOT NULL DEFAULT 0,
+		evalue REAL NOT NULL DEFAULT 0,
+		url TEXT NOT NULL DEFAULT '',
+		UNIQUE (dataset_id, motif_id),
+		FOREIGN KEY (dataset_id) REFERENCES datasets(id) ON DELETE CASCADE);
+	CREATE INDEX idx_motifs_motif_id ON motifs (LOWER(motif_id));
+	CREATE INDEX idx_motifs_name ON motifs (LOWER(motif_name));
+	CREATE INDEX idx_motifs_dataset_id ON motifs (dataset_id);
+	CREATE INDEX idx_motifs_taxon_id ON motifs (taxon_id);
+
+	CREATE TABLE motif_genes (
+		motif_id INTEGER NOT NULL,
+		gene_id INTEGER NOT NULL,
+		PRIMARY KEY (motif_id, gene_id),
+		FOREIGN KEY (motif_id) REFERENCES motifs(id) ON DELETE CASCADE,
+		FOREIGN KEY (gene_id) REFERENCES genes(id) ON DELETE CASCADE);
+
+	-- parts of HOCOMOCO motif ids, where sources are the letter
+	-- codes of the experiments, e.g. PSM
+	CREATE TABLE hocomoco_motifs (
+		motif_id INTEGER PRIMARY KEY,
+		gene TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		subtype INTEGER NOT NULL,
+		sources TEXT NOT NULL,
+		grade TEXT NOT NULL,
+		FOREIGN KEY (motif_id) REFERENCES motifs(id) ON DELETE CASCADE);
+	CREATE INDEX idx_hocomoco_motifs_grade ON hocomoco_motifs (grade);
+
+	CREATE TABLE weights (
+		id INTEGER PRIMARY KEY,
+		motif_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		a REAL NOT NULL,
+		c REAL NOT NULL,
+		g REAL NOT NULL,
+		t REAL NOT NULL,
+		-- raw counts, NULL if the source only gave probabilities
+		count_a REAL,
+		count_c REAL,
+		count_g REAL,
+		count_t REAL,
+		FOREIGN KEY (motif_id) REFERENCES motifs(id) ON DELETE CASCADE);
+	CREATE INDEX idx_weights_motif_id ON weights (motif_id);`
+
+	InsertDatasetSql = `INSERT INTO datasets
+		(id, public_id, name, bg_a, bg_c, bg_g, bg_t)
+		VALUES (:id, :public_id, :name, :bg_a, :bg_c, :bg_g, :bg_t);`
+
+	InsertSpeciesSql = `INSERT INTO species (taxon_id, name) VALUES (:taxon_id, :name);`
+
+	InsertGeneSql = `INSERT INTO genes (id, public_id, name, taxon_id) VALUES (:id, :public_id, :name, :taxon_id);`
+
+	InsertMotifSql = `INSERT INTO motifs
+		(id, public_id, dataset_id, motif_id, motif_name, length, taxon_id, nsites, evalue, url)
+		VALUES (:id, :public_id, :dataset_id, :motif_id, :motif_name, :length, :taxon_id, :nsites, :evalue, :url);`
+
+	InsertHocomocoSql = `INSERT INTO hocomoco_motifs
+		(motif_id, gene, collection, subtype, sources, grade)
+		VALUES (:motif_id, :gene, :collection, :subtype, :sources, :grade);`
+
+	InsertMotifGeneSql = `INSERT INTO motif_genes (motif_id, gene_id) VALUES (:motif_id, :gene_id);`
+
+	InsertWeightSql = `INSERT INTO weights
+		(motif_id, position, a, c, g, t, count_a, count_c, count_g, count_t)
+		VALUES (:motif_id, :position, :a, :c, :g, :t, :count_a, :count_c, :count_g, :count_t);`
+
+	// Full text index for MotifDB.FullTextSearch. Column order sets
+	// the bm25 weights used when searching. Genes are stored as one
+	// space separated string per motif.
+	FullTextSchemaSql = `CREATE VIRTUAL TABLE motifs_fts USING fts5(
+		motif_id,
+		motif_name,
+		genes,
+		dataset,
+		prefix = '2 3');`
+
+	FullTextIndexSql = `INSERT INTO motifs_fts (rowid, motif_id, motif_name, genes, dataset)
+		SELECT
+		m.id,
+		m.motif_id,
+		m.motif_name,
+		COALESCE((SELECT GROUP_CONCAT(name, ' ') FROM (
+			SELECT g.name FROM motif_genes mg
+			JOIN genes g ON mg.gene_id = g.id
+			WHERE mg.motif_id = m.id
+			ORDER BY g.name)), ''),
+		d.name
+		FROM motifs m
+		JOIN datasets d ON m.dataset_id = d.id
+		ORDER BY m.id;`
+)
+
+var (
+	ErrNoDatasets       = errors.New("no datasets to build")
+	ErrDuplicateDataset = errors.New("duplicate dataset")
+
+	// Public ids are name based (v5) uuids in this namespace so that
+	// rebuilding from the same files gives the same ids
+	PublicIdNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/antonybholmes/go-motifs"))
+)
+
+// DatasetNameFromFile derives a dataset name from a motif file path,
+// e.g. scripts/meme/H12CORE_meme_format.meme becomes H12CORE
+func DatasetNameFromFile(file string) string {
+	name := filepath.Base(file)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = strings.TrimSuffix(name, "_meme_format")
+
+	return name
+}
+
+// readMotifFile reads JASPAR count files ending in .jaspar or .pfm,
+// JASPAR JSON ending in .json, HOMER files ending in .motif or
+// .motifs, TRANSFAC files ending in .transfac or .dat and MEME files
+// otherwise, returning the motifs and background. Formats other than
+// MEME have a uniform background.
+func readMotifFile(file string) ([]*motifs.Motif, []float64, error) {
+	var ms []*motifs.Motif
+	var err error
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".jaspar", ".pfm":
+		ms, err = jaspar.ReadFile(file)
+	case ".json":
+		var jasparMotifs []*jaspar.Motif
+
+		jasparMotifs, err = jaspar.ReadJSONFile(file)
+
+		for _, motif := range jasparMotifs {
+			ms = append(ms, motif.Motif)
+		}
+	case ".motif", ".motifs":
+		var homerMotifs []*homer.Motif
+
+		homerMotifs, err = homer.ReadFile(file)
+
+		for _, motif := range homerMotifs {
+			ms = append(ms, motif.Motif)
+		}
+	case ".transfac", ".dat":
+		var transfacMotifs []*transfac.Motif
+
+		transfacMotifs, err = transfac.ReadFile(file)
+
+		for _, motif := range transfacMotifs {
+			ms = append(ms, motif.Motif)
+		}
+	default:
+		f, err := meme.ReadFile(file)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return f.Motifs, f.Background, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ms, slices.Clone(motifs.UniformBackground), nil
+}
+
+// ReadDataset loads the motifs in a file and assigns genes, and
+// species if the file does not give them, to each using the parsing
+// rules for the dataset
+func ReadDataset(name string, file string) (*Dataset, error) {
+	ms, background, err := readMotifFile(file)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	parser := GeneParserForDataset(name)
+	speciesParser := SpeciesParserForDataset(name)
+	hocomoco := hocomocoDatasetRegex.MatchString(name)
+
+	for _, motif := range ms {
+		motif.Genes = parser(motif)
+
+		if motif.Species == nil {
+			motif.Species = speciesParser(motif)
+		}
+
+		if hocomoco {
+			motif.Hocomoco, err = motifs.ParseHocomocoId(motif.MotifId)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+		}
+	}
+
+	return &Dataset{Name: name, Background: background, Motifs: ms}, nil
+}
+
+// ReadDatasets loads each file as a dataset. Files may be given as
+// name=path to override the dataset name derived from the file.
+func ReadDatasets(files []string) ([]*Dataset, error) {
+	datasets := make([]*Dataset, 0, len(files))
+
+	for _, file := range files {
+		name, path, found := strings.Cut(file, "=")
+
+		if !found {
+			path = file
+			name = DatasetNameFromFile(file)
+		}
+
+		dataset, err := ReadDataset(name, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		datasets = append(datasets, dataset)
+	}
+
+	return datasets, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+
+	return g.f.Close()
+}
+
+// open a file, decompressing it if it ends in .gz
+func openFile(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(file, ".gz") {
+		return f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+func PublicId(kind string, keys ...string) string {
+	return uuid.NewSHA1(PublicIdNamespace, []byte(kind+":"+strings.Join(keys, ":"))).String()
+}
+
+// Build writes the datasets to a new SQLite database using the schema
+// MotifDB queries. Any existing file is replaced. Ids are assigned in
+// the order datasets and motifs are given so the same inputs always
+// produce the same database.
+func Build(file string, datasets []*Dataset) error {
+	if len(datasets) == 0 {
+		return ErrNoDatasets
+	}
+
+	names := make(map[string]struct{}, len(datasets))
+
+	for _, dataset := range datasets {
+		if _, found := names[dataset.Name]; found {
+			return fmt.Errorf("%w: %s", ErrDuplicateDataset, dataset.Name)
+		}
+
+		names[dataset.Name] = struct{}{}
+	}
+
+	err := os.Remove(file)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	conn, err := sql.Open(db.Sqlite3DB, file)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Exec(SchemaSql)
+
+	if err != nil {
+		return err
+	}
+
+	// filled with the families motif files give, and replaced by
+	// AddFamilies
+	_, err = conn.Exec(FamiliesSchemaSql)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = insertSpecies(tx, datasets)
+
+	if err != nil {
+		return err
+	}
+
+	err = insertDatasets(tx, datasets)
+
+	if err != nil {
+		return err
+	}
+
+	err = indexFullText(tx)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// index motifs for full text search. SQLite drivers are not always
+// compiled with FTS5, e.g. mattn/go-sqlite3 needs -tags sqlite_fts5,
+// in which case the database is still usable without the index.
+func indexFullText(tx *sql.Tx) error {
+	_, err := tx.Exec(FullTextSchemaSql)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			log.Warn().Msgf("skipping full text index: %s", err)
+			return nil
+		}
+
+		return err
+	}
+
+	_, err = tx.Exec(FullTextIndexSql)
+
+	return err
+}
+
+// the species of the motifs, in taxonomy order
+func insertSpecies(tx *sql.Tx, datasets []*Dataset) error {
+	species := make(map[int]*motifs.Species, 10)
+
+	for _, dataset := range datasets {
+		for _, motif := range dataset.Motifs {
+			if motif.Species != nil {
+				species[motif.Species.TaxonId] = motif.Species
+			}
+		}
+	}
+
+	stmt, err := tx.Prepare(InsertSpeciesSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, taxonId := range slices.Sorted(maps.Keys(species)) {
+		_, err := stmt.Exec(sql.Named("taxon_id", taxonId),
+			sql.Named("name", species[taxonId].Name))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// the weights and, if the motif has them, counts to store. Weights
+// are derived from counts when there are counts so the two agree.
+func motifMatrices(motif *motifs.Motif) ([][]float64, [][]float64, error) {
+	if len(motif.Counts) == 0 {
+		return motif.Weights, nil, nil
+	}
+
+	weights, err := motifs.NormalizeWeights(motif.Counts)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return weights, motif.Counts, nil
+}
+
+// the taxonomy id stored for a species, 0 if unknown
+func taxonId(species *motifs.Species) int {
+	if species == nil {
+		return 0
+	}
+
+	return species.TaxonId
+}
+
+// geneTaxonIds gives each gene the species of the motifs binding it,
+// ignoring motifs of unknown species. Genes whose motifs disagree are
+// unknown, so the species does not depend on the order datasets are
+// given in.
+func geneTaxonIds(datasets []*Dataset) map[string]int {
+	// -1 marks genes whose motifs disagree
+	ret := make(map[string]int, 1000)
+
+	for _, dataset := range datasets {
+		for _, motif := range dataset.Motifs {
+			id := taxonId(motif.Species)
+
+			if id == 0 {
+				continue
+			}
+
+			for _, gene := range motif.Genes {
+				current, found := ret[gene]
+
+				switch {
+				case !found:
+					ret[gene] = id
+				case current != id:
+					ret[gene] = -1
+				}
+			}
+		}
+	}
+
+	for gene, id := range ret {
+		if id == -1 {
+			delete(ret, gene)
+		}
+	}
+
+	return ret
+}
+
+func insertDatasets(tx *sql.Tx, datasets []*Dataset) error {
+	datasetStmt, err := tx.Prepare(InsertDatasetSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer datasetStmt.Close()
+
+	motifStmt, err := tx.Prepare(InsertMotifSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer motifStmt.Close()
+
+	geneStmt, err := tx.Prepare(InsertGeneSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer geneStmt.Close()
+
+	motifGeneStmt, err := tx.Prepare(InsertMotifGeneSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer motifGeneStmt.Close()
+
+	weightStmt, err := tx.Prepare(InsertWeightSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer weightStmt.Close()
+
+	hocomocoStmt, err := tx.Prepare(InsertHocomocoSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer hocomocoStmt.Close()
+
+	families, err := newFamilyWriter(tx)
+
+	if err != nil {
+		return err
+	}
+
+	defer families.Close()
+
+	// genes are shared across datasets so map names to ids
+	geneIds := make(map[string]int, 1000)
+	geneTaxons := geneTaxonIds(datasets)
+	motifIndex := 1
+
+	for datasetIndex, dataset := range datasets {
+		datasetId := datasetIndex + 1
+
+		log.Debug().Msgf("building dataset %s with %d motifs", dataset.Name, len(dataset.Motifs))
+
+		bg := dataset.Background
+
+		if len(bg) != 4 {
+			bg = motifs.UniformBackground
+		}
+
+		_, err := datasetStmt.Exec(sql.Named("id", datasetId),
+			sql.Named("public_id", PublicId("dataset", dataset.Name)),
+			sql.Named("name", dataset.Name),
+			sql.Named("bg_a", bg[0]),
+			sql.Named("bg_c", bg[1]),
+			sql.Named("bg_g", bg[2]),
+			sql.Named("bg_t", bg[3]))
+
+		if err != nil {
+			return err
+		}
+
+		for _, motif := range dataset.Motifs {
+			weights, counts, err := motifMatrices(motif)
+
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", dataset.Name, motif.MotifId, err)
+			}
+
+			_, err = motifStmt.Exec(sql.Named("id", motifIndex),
+				sql.Named("public_id", PublicId("motif", dataset.Name, motif.MotifId)),
+				sql.Named("dataset_id", datasetId),
+				sql.Named("motif_id", motif.MotifId),
+				sql.Named("motif_name", motif.Name),
+				sql.Named("length", len(weights)),
+				sql.Named("taxon_id", taxonId(motif.Species)),
+				sql.Named("nsites", motif.NSites),
+				sql.Named("evalue", motif.EValue),
+				sql.Named("url", motif.URL))
+
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", dataset.Name, motif.MotifId, err)
+			}
+
+			for _, gene := range motif.Genes {
+				geneId, found := geneIds[gene]
+
+				if !found {
+					geneId = len(geneIds) + 1
+					geneIds[gene] = geneId
+
+					_, err := geneStmt.Exec(sql.Named("id", geneId),
+						sql.Named("public_id", PublicId("gene", gene)),
+						sql.Named("name", gene),
+						sql.Named("taxon_id", geneTaxons[gene]))
+
+					if err != nil {
+						return err
+					}
+				}
+
+				_, err := motifGeneStmt.Exec(sql.Named("motif_id", motifIndex),
+					sql.Named("gene_id", geneId))
+
+				if err != nil {
+					return err
+				}
+			}
+
+			if motif.Hocomoco != nil {
+				_, err := hocomocoStmt.Exec(sql.Named("motif_id", motifIndex),
+					sql.Named("gene", motif.Hocomoco.Gene),
+					sql.Named("collection", motif.Hocomoco.Collection),
+					sql.Named("subtype", motif.Hocomoco.Subtype),
+					sql.Named("sources", motif.Hocomoco.SourceCodes()),
+					sql.Named("grade", motif.Hocomoco.Grade))
+
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, lineage := range motifLineages(motif) {
+				err := families.annotate(lineage, []int{motifIndex})
+
+				if err != nil {
+					return fmt.Errorf("%s %s: %w", dataset.Name, motif.MotifId, err)
+				}
+			}
+
+			for i, pw := range weights {
+				// NULL counts unless the source gave them
+				pc := make([]any, 4)
+
+				if counts != nil {
+					for b, v := range counts[i] {
+						pc[b] = v
+					}
+				}
+
+				_, err := weightStmt.Exec(sql.Named("motif_id", motifIndex),
+					sql.Named("position", i+1),
+					sql.Named("a", pw[0]),
+					sql.Named("c", pw[1]),
+					sql.Named("g", pw[2]),
+					sql.Named("t", pw[3]),
+					sql.Named("count_a", pc[0]),
+					sql.Named("count_c", pc[1]),
+					sql.Named("count_g", pc[2]),
+					sql.Named("count_t", pc[3]))
+
+				if err != nil {
+					return err
+				}
+			}
+
+			motifIndex++
+		}
+	}
+
+	return nil
+}
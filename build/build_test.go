@@ -0,0 +1,121 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+const testJaspar = `>MA0004.1	Arnt
+A  [ 4 19  0  0  0  0 ]
+C  [16  0 20  0  0  0 ]
+G  [ 0  1  0 20  0 20 ]
+T  [ 0  0  0  0 20  0 ]
+`
+
+func TestBuildCounts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.jaspar")
+
+	err := os.WriteFile(file, []byte(testJaspar), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := ReadDataset("test", file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbFile := filepath.Join(dir, "motifs.db")
+
+	err = Build(dbFile, []*Dataset{dataset})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mdb := motifs.NewMotifDB(dbFile)
+
+	motif, err := mdb.Motif(PublicId("motif", "test", "MA0004.1"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if motif.NSites != 20 || len(motif.Counts) != 6 || motif.Counts[1][0] != 19 || motif.Counts[1][2] != 1 {
+		t.Fatalf("bad counts %+v", motif)
+	}
+
+	if motif.Weights[0][1] != 0.8 {
+		t.Fatalf("bad weights %v", motif.Weights)
+	}
+}
+
+// a heterodimer with a class and family for each factor
+const testJSON = `[{
+  "matrix_id": "MA0089.2",
+  "name": "MAFG::NFE2L1",
+  "class": ["Basic leucine zipper factors (bZIP)", "Basic leucine zipper factors (bZIP)"],
+  "family": ["Maf-related factors", "CNC - bZIP factors"],
+  "species": [{"tax_id": "9606", "name": "Homo sapiens"}],
+  "pfm": {"A": [0, 3, 79], "C": [94, 75, 4], "G": [1, 0, 3], "T": [2, 19, 11]}
+}]`
+
+func TestBuildFamilies(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.json")
+
+	err := os.WriteFile(file, []byte(testJSON), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := ReadDataset("test", file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbFile := filepath.Join(dir, "motifs.db")
+
+	err = Build(dbFile, []*Dataset{dataset})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mdb := motifs.NewMotifDB(dbFile)
+
+	// the class is shared by both families
+	list, err := mdb.Families()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 3 || list[0].Level != motifs.FamilyLevelClass || list[0].MotifCount != 1 {
+		t.Fatalf("unexpected families %v", list)
+	}
+
+	motif, err := mdb.Motif(PublicId("motif", "test", "MA0089.2"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(motif.Families) != 3 {
+		t.Fatalf("motif has families %v", motif.Families)
+	}
+
+	for _, family := range motif.Families[1:] {
+		if family.Level != motifs.FamilyLevelFamily || family.Parent != motif.Families[0].PublicId {
+			t.Fatalf("motif has families %v", motif.Families)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package build
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-sys/db"
+)
+
+type (
+	// The class and family of the motifs with an id, or of the motifs
+	// binding a gene if no id is given. Levels that are not known
+	// are empty.
+	FamilyAnnotation struct {
+		MotifId    string
+		Gene       string
+		Superclass string
+		Class      string
+		Family     string
+		Subfamily  string
+	}
+
+	// the parts of a HOCOMOCO annotation line we use
+	hocomocoAnnotation struct {
+		Name           string `json:"name"`
+		MasterlistInfo struct {
+			Superclass json.RawMessage `json:"tfclass_superclass"`
+			Class      json.RawMessage `json:"tfclass_class"`
+			Family     json.RawMessage `json:"tfclass_family"`
+			Subfamily  json.RawMessage `json:"tfclass_subfamily"`
+		} `json:"masterlist_info"`
+	}
+
+	familyKey struct {
+		parentId int
+		level    string
+		name     string
+	}
+)
+
+const (
+	FamiliesSchemaSql = `CREATE TABLE IF NOT EXISTS families (
+		id INTEGER PRIMARY KEY,
+		public_id TEXT NOT NULL,
+		parent_id INTEGER NOT NULL DEFAULT 0,
+		depth INTEGER NOT NULL,
+		level TEXT NOT NULL,
+		name TEXT NOT NULL,
+		UNIQUE (parent_id, level, name));
+	CREATE INDEX IF NOT EXISTS idx_families_public_id ON families (public_id);
+	CREATE INDEX IF NOT EXISTS idx_families_name ON families (LOWER(name));
+
+	CREATE TABLE IF NOT EXISTS motif_families (
+		motif_id INTEGER NOT NULL,
+		family_id INTEGER NOT NULL,
+		PRIMARY KEY (motif_id, family_id),
+		FOREIGN KEY (motif_id) REFERENCES motifs(id) ON DELETE CASCADE,
+		FOREIGN KEY (family_id) REFERENCES families(id) ON DELETE CASCADE);
+	CREATE INDEX IF NOT EXISTS idx_motif_families_family_id ON motif_families (family_id);`
+
+	ClearFamiliesSql = `DELETE FROM motif_families; DELETE FROM families;`
+
+	InsertFamilySql = `INSERT INTO families
+		(id, public_id, parent_id, depth, level, name)
+		VALUES (:id, :public_id, :parent_id, :depth, :level, :name);`
+
+	InsertMotifFamilySql = `INSERT INTO motif_families (motif_id, family_id)
+		VALUES (:motif_id, :family_id)
+		ON CONFLICT DO NOTHING;`
+
+	MotifIdsSql = `SELECT id, LOWER(motif_id) FROM motifs ORDER BY id`
+
+	MotifGeneNamesSql = `SELECT mg.motif_id, LOWER(g.name)
+		FROM motif_genes mg
+		JOIN genes g ON mg.gene_id = g.id
+		ORDER BY mg.motif_id`
+)
+
+var (
+	ErrFamiliesHeader = errors.New("families file needs a motif_id or gene column and a class or family column")
+)
+
+// levels of the annotation from most to least general, matching
+// motifs.FamilyLevels
+func (a *FamilyAnnotation) levels() []string {
+	return []string{a.Superclass, a.Class, a.Family, a.Subfamily}
+}
+
+// ReadFamilies reads a tab separated file with a header naming its
+// columns, which are matched ignoring case. Rows are for a motif if
+// motif_id is set, otherwise for a gene, and may give any of the
+// superclass, class, family and subfamily columns, e.g.
+//
+//	gene	class	family
+//	SOX2	High-mobility group (HMG) domain factors	SOX-related factors
+func ReadFamilies(r io.Reader) ([]*FamilyAnnotation, error) {
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		err := scanner.Err()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrFamiliesHeader
+	}
+
+	cols := make(map[string]int, 6)
+
+	for i, name := range strings.Split(strings.TrimPrefix(scanner.Text(), "#"), "\t") {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	_, hasMotif := cols["motif_id"]
+	_, hasGene := cols["gene"]
+	_, hasClass := cols[motifs.FamilyLevelClass]
+	_, hasFamily := cols[motifs.FamilyLevelFamily]
+
+	if !(hasMotif || hasGene) || !(hasClass || hasFamily) {
+		return nil, ErrFamiliesHeader
+	}
+
+	ret := make([]*FamilyAnnotation, 0, 1000)
+
+	for scanner.Scan() {
+		tokens := strings.Split(scanner.Text(), "\t")
+
+		value := func(col string) string {
+			i, found := cols[col]
+
+			if !found || i >= len(tokens) {
+				return ""
+			}
+
+			return strings.TrimSpace(tokens[i])
+		}
+
+		annotation := FamilyAnnotation{MotifId: value("motif_id"),
+			Gene:       value("gene"),
+			Superclass: value(motifs.FamilyLevelSuperclass),
+			Class:      value(motifs.FamilyLevelClass),
+			Family:     value(motifs.FamilyLevelFamily),
+			Subfamily:  value(motifs.FamilyLevelSubfamily)}
+
+		if annotation.MotifId != "" || annotation.Gene != "" {
+			ret = append(ret, &annotation)
+		}
+	}
+
+	return ret, scanner.Err()
+}
+
+// ReadHocomocoFamilies reads the TFClass annotation of each motif from
+// a HOCOMOCO v12 or v13 annotation file, which has one JSON object per
+// line, e.g. H12CORE_annotation.jsonl
+func ReadHocomocoFamilies(r io.Reader) ([]*FamilyAnnotation, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	ret := make([]*FamilyAnnotation, 0, 2000)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			continue
+		}
+
+		var hocomoco hocomocoAnnotation
+
+		err := json.Unmarshal([]byte(text), &hocomoco)
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		info := hocomoco.MasterlistInfo
+
+		ret = append(ret, &FamilyAnnotation{MotifId: hocomoco.Name,
+			Superclass: tfClassName(info.Superclass),
+			Class:      tfClassName(info.Class),
+			Family:     tfClassName(info.Family),
+			Subfamily:  tfClassName(info.Subfamily)})
+	}
+
+	return ret, scanner.Err()
+}
+
+// TFClass levels are given as a name or a list of names, in which
+// case the first is used. Names may end with the TFClass id in
+// braces, e.g. Basic leucine zipper factors (bZIP){1.1}, which is
+// removed.
+func tfClassName(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var name string
+
+	if json.Unmarshal(raw, &name) != nil {
+		var names []string
+
+		if json.Unmarshal(raw, &names) != nil || len(names) == 0 {
+			return ""
+		}
+
+		name = names[0]
+	}
+
+	if i := strings.LastIndex(name, "{"); i > 0 && strings.HasSuffix(name, "}") {
+		name = name[:i]
+	}
+
+	return strings.TrimSpace(name)
+}
+
+// ReadFamiliesFile reads HOCOMOCO annotations from .jsonl files and
+// tab separated annotations otherwise. Files may be gzipped.
+func ReadFamiliesFile(file string) ([]*FamilyAnnotation, error) {
+	r, err := openFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var families []*FamilyAnnotation
+
+	if strings.HasSuffix(strings.TrimSuffix(file, ".gz"), ".jsonl") {
+		families, err = ReadHocomocoFamilies(r)
+	} else {
+		families, err = ReadFamilies(r)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return families, nil
+}
+
+// AddFamilies replaces the class and family annotations of an existing
+// database. Motif ids and genes are matched ignoring case and
+// annotations that match no motifs are skipped. Returns the number of
+// motifs annotated.
+func AddFamilies(file string, families []*FamilyAnnotation) (int, error) {
+	conn, err := sql.Open(db.Sqlite3DB, file)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	tx, err := conn.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	n, err := insertFamilies(tx, families)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// internal ids of motifs keyed by lowercase motif id and by lowercase
+// gene
+func motifIndex(tx *sql.Tx) (map[string][]int, map[string][]int, error) {
+	byId := make(map[string][]int, 10000)
+	byGene := make(map[string][]int, 2000)
+
+	for _, index := range []struct {
+		sql string
+		ids map[string][]int
+	}{{MotifIdsSql, byId}, {MotifGeneNamesSql, byGene}} {
+		rows, err := tx.Query(index.sql)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for rows.Next() {
+			var id int
+			var key string
+
+			err := rows.Scan(&id, &key)
+
+			if err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+
+			index.ids[key] = append(index.ids[key], id)
+		}
+
+		err = rows.Err()
+
+		rows.Close()
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return byId, byGene, nil
+}
+
+// familyWriter adds families and links motifs to them, sharing the
+// families already written
+type familyWriter struct {
+	familyStmt *sql.Stmt
+	motifStmt  *sql.Stmt
+	// ids are assigned in the order families are first seen so the
+	// same inputs always give the same database
+	familyIds map[familyKey]int
+	annotated map[int]struct{}
+}
+
+func newFamilyWriter(tx *sql.Tx) (*familyWriter, error) {
+	familyStmt, err := tx.Prepare(InsertFamilySql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	motifStmt, err := tx.Prepare(InsertMotifFamilySql)
+
+	if err != nil {
+		familyStmt.Close()
+		return nil, err
+	}
+
+	return &familyWriter{familyStmt: familyStmt,
+		motifStmt: motifStmt,
+		familyIds: make(map[familyKey]int, 1000),
+		annotated: make(map[int]struct{}, 10000)}, nil
+}
+
+func (w *familyWriter) Close() {
+	w.familyStmt.Close()
+	w.motifStmt.Close()
+}
+
+// annotate links motifs to each level of a lineage, given from most to
+// least general as in motifs.FamilyLevels. Empty levels are skipped.
+func (w *familyWriter) annotate(lineage []string, motifIds []int) error {
+	parentId := 0
+	parentPath := make([]string, 0, 8)
+
+	for depth, name := range lineage {
+		if name == "" {
+			continue
+		}
+
+		level := motifs.FamilyLevels[depth]
+		key := familyKey{parentId: parentId, level: level, name: name}
+		parentPath = append(parentPath, level, name)
+
+		familyId, found := w.familyIds[key]
+
+		if !found {
+			familyId = len(w.familyIds) + 1
+			w.familyIds[key] = familyId
+
+			_, err := w.familyStmt.Exec(sql.Named("id", familyId),
+				sql.Named("public_id", PublicId("family", parentPath...)),
+				sql.Named("parent_id", parentId),
+				sql.Named("depth", depth),
+				sql.Named("level", level),
+				sql.Named("name", name))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, motifId := range motifIds {
+			_, err := w.motifStmt.Exec(sql.Named("motif_id", motifId),
+				sql.Named("family_id", familyId))
+
+			if err != nil {
+				return err
+			}
+
+			w.annotated[motifId] = struct{}{}
+		}
+
+		parentId = familyId
+	}
+
+	return nil
+}
+
+// motifLineages turns the families a motif file gives a motif into
+// lineages for familyWriter.annotate. Levels with several names, e.g.
+// the classes of a heterodimer in JASPAR, are paired in order, and a
+// level with fewer names than another repeats its last.
+func motifLineages(motif *motifs.Motif) [][]string {
+	names := make([][]string, len(motifs.FamilyLevels))
+	n := 0
+
+	for _, family := range motif.Families {
+		depth := slices.Index(motifs.FamilyLevels, family.Level)
+
+		if depth == -1 || family.Name == "" {
+			continue
+		}
+
+		names[depth] = append(names[depth], family.Name)
+		n = max(n, len(names[depth]))
+	}
+
+	ret := make([][]string, 0, n)
+
+	for i := range n {
+		lineage := make([]string, len(names))
+
+		for depth, levelNames := range names {
+			if len(levelNames) > 0 {
+				lineage[depth] = levelNames[min(i, len(levelNames)-1)]
+			}
+		}
+
+		ret = append(ret, lineage)
+	}
+
+	return ret
+}
+
+func insertFamilies(tx *sql.Tx, families []*FamilyAnnotation) (int, error) {
+	_, err := tx.Exec(FamiliesSchemaSql)
+
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ClearFamiliesSql)
+
+	if err != nil {
+		return 0, err
+	}
+
+	byId, byGene, err := motifIndex(tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	writer, err := newFamilyWriter(tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer writer.Close()
+
+	for _, annotation := range families {
+		var motifIds []int
+
+		if annotation.MotifId != "" {
+			motifIds = byId[strings.ToLower(annotation.MotifId)]
+		} else {
+			motifIds = byGene[strings.ToLower(annotation.Gene)]
+		}
+
+		if len(motifIds) == 0 {
+			continue
+		}
+
+		err := writer.annotate(annotation.levels(), motifIds)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(writer.annotated), nil
+}
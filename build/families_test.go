@@ -0,0 +1,132 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+func TestReadFamilies(t *testing.T) {
+	tsv := "gene\tclass\tfamily\n" +
+		"SOX2\tHigh-mobility group (HMG) domain factors\tSOX-related factors\n" +
+		"\tno gene\t\n"
+
+	families, err := ReadFamilies(strings.NewReader(tsv))
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(families) != 1 || families[0].Gene != "SOX2" || families[0].Family != "SOX-related factors" {
+		t.Fatalf("unexpected families %v", families)
+	}
+
+	// levels may be a name or a list and end with a TFClass id
+	jsonl := `{"name": "AHR.H12CORE.0.P.B", "masterlist_info": {"tfclass_class": "Basic helix-loop-helix factors (bHLH){1.2}", "tfclass_family": ["PAS domain factors{1.2.5}"]}}` + "\n"
+
+	families, err = ReadHocomocoFamilies(strings.NewReader(jsonl))
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(families) != 1 || families[0].Class != "Basic helix-loop-helix factors (bHLH)" ||
+		families[0].Family != "PAS domain factors" {
+		t.Fatalf("unexpected hocomoco families %v", families[0])
+	}
+
+	_, err = ReadFamilies(strings.NewReader("gene\tname\n"))
+
+	if err == nil {
+		t.Fatalf("expected header error")
+	}
+}
+
+func TestAddFamilies(t *testing.T) {
+	file := testDatabase(t)
+
+	families := []*FamilyAnnotation{
+		{Gene: "SOX2", Class: "High-mobility group (HMG) domain factors", Family: "SOX-related factors"},
+		{Gene: "SOX9", Class: "High-mobility group (HMG) domain factors", Family: "SOX-related factors"},
+		{MotifId: "AHR.H12CORE.0.P.B", Class: "Basic helix-loop-helix factors (bHLH)", Family: "PAS domain factors"},
+	}
+
+	n, err := AddFamilies(file, families)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if n == 0 {
+		t.Fatalf("no motifs annotated")
+	}
+
+	mdb := motifs.NewMotifDB(file)
+
+	list, err := mdb.Families()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// two classes with a family each, classes first
+	if len(list) != 4 || list[0].Level != motifs.FamilyLevelClass || list[3].Level != motifs.FamilyLevelFamily {
+		t.Fatalf("unexpected families %v", list)
+	}
+
+	datasets, err := mdb.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	res, err := mdb.Search([]string{"SOX"}, ids,
+		&motifs.MotifFilter{Families: []string{"sox-related factors"}},
+		&motifs.Paging{Page: 1, PageSize: 100}, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if res.Total == 0 {
+		t.Fatalf("no motifs in family")
+	}
+
+	for _, motif := range res.Motifs {
+		if len(motif.Families) != 2 || motif.Families[1].Name != "SOX-related factors" ||
+			motif.Families[1].Parent != motif.Families[0].PublicId {
+			t.Fatalf("motif %s has families %v", motif.MotifId, motif.Families)
+		}
+	}
+}
+
+func TestMotifLineages(t *testing.T) {
+	family := func(level string, name string) *motifs.Family {
+		f := motifs.Family{Level: level}
+		f.Name = name
+		return &f
+	}
+
+	// one class shared by the families of a heterodimer
+	motif := motifs.Motif{Families: []*motifs.Family{family(motifs.FamilyLevelClass, "bZIP"),
+		family(motifs.FamilyLevelFamily, "Maf-related factors"),
+		family(motifs.FamilyLevelFamily, "CNC - bZIP factors")}}
+
+	lineages := motifLineages(&motif)
+
+	if len(lineages) != 2 || lineages[0][1] != "bZIP" || lineages[1][1] != "bZIP" ||
+		lineages[0][2] != "Maf-related factors" || lineages[1][2] != "CNC - bZIP factors" {
+		t.Fatalf("unexpected lineages %v", lineages)
+	}
+
+	if len(motifLineages(&motifs.Motif{})) != 0 {
+		t.Fatalf("expected no lineages")
+	}
+}
@@ -0,0 +1,201 @@
+package build
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+// GeneParser extracts the gene symbols a motif binds from its
+// id and name. Each collection encodes genes differently so the
+// parser is chosen per dataset
+type GeneParser func(motif *motifs.Motif) []string
+
+var (
+	// e.g. E2F1..5 or SMAD1..7,9
+	geneRangeRegex = regexp.MustCompile(`^(.*?)(\d+)\.\.(\d+)(.*)$`)
+
+	// e.g. FOS{B,L1} or SOX2{dimer}
+	geneBraceRegex = regexp.MustCompile(`^(.*)\{(.+)\}$`)
+
+	// annotations such as {dimer} or {mouse} rather than gene suffixes
+	geneNoteRegex = regexp.MustCompile(`^[a-z]+$`)
+
+	swissRegulonSuffixRegex = regexp.MustCompile(`\.p\d+$`)
+	jolmaSuffixRegex        = regexp.MustCompile(`_DBD.*`)
+	hocomocoSuffixRegex     = regexp.MustCompile(`\.H\d+CORE.*`)
+	hocomocoDatasetRegex    = regexp.MustCompile(`^H\d+CORE`)
+)
+
+// GeneParserForDataset picks the gene parsing rules for a
+// collection based on its name
+func GeneParserForDataset(dataset string) GeneParser {
+	switch {
+	case strings.HasPrefix(dataset, "SwissRegulon"):
+		return SwissRegulonGenes
+	case strings.HasPrefix(dataset, "jolma"):
+		return JolmaGenes
+	case hocomocoDatasetRegex.MatchString(dataset):
+		return HocomocoGenes
+	default:
+		return JasparGenes
+	}
+}
+
+// JasparGenes splits names such as FOS::JUN into their component
+// genes, dropping variant suffixes like _var.2
+func JasparGenes(motif *motifs.Motif) []string {
+	genes := make([]string, 0, 2)
+
+	for _, gene := range strings.Split(motif.Name, "::") {
+		gene, _, _ = strings.Cut(gene, "_")
+		genes = append(genes, gene)
+	}
+
+	return uniqueGenes(genes)
+}
+
+// SwissRegulonGenes handles the compact SwissRegulon names where
+// several genes are combined with _ and families are abbreviated
+// using ranges (E2F1..5), comma lists (CDX1,2,4) and braces
+// (FOX{C1,C2}).
+func SwissRegulonGenes(motif *motifs.Motif) []string {
+	genes := make([]string, 0, 5)
+
+	id := swissRegulonSuffixRegex.ReplaceAllString(motif.MotifId, "")
+
+	for _, gene := range strings.Split(id, "_") {
+		genes = append(genes, expandGene(gene)...)
+	}
+
+	return uniqueGenes(genes)
+}
+
+// JolmaGenes strips the _DBD and _full construct suffixes from the
+// Jolma et al. 2013 HT-SELEX ids
+func JolmaGenes(motif *motifs.Motif) []string {
+	id, _, _ := strings.Cut(motif.MotifId, "_full")
+	id = jolmaSuffixRegex.ReplaceAllString(id, "")
+
+	return uniqueGenes(strings.Split(id, "_"))
+}
+
+// HocomocoGenes takes the gene from ids such as AHR.H12CORE.0.P.B
+func HocomocoGenes(motif *motifs.Motif) []string {
+	id, _, _ := strings.Cut(motif.MotifId, "_full")
+	id = hocomocoSuffixRegex.ReplaceAllString(id, "")
+
+	return uniqueGenes(strings.Split(id, "_"))
+}
+
+// expand one abbreviated gene token into the genes it represents
+func expandGene(gene string) []string {
+	if matcher := geneRangeRegex.FindStringSubmatch(gene); matcher != nil {
+		prefix := matcher[1]
+		start, err1 := strconv.Atoi(matcher[2])
+		end, err2 := strconv.Atoi(matcher[3])
+
+		if err1 == nil && err2 == nil && start <= end {
+			genes := make([]string, 0, end-start+1)
+
+			for i := start; i <= end; i++ {
+				genes = append(genes, prefix+strconv.Itoa(i))
+			}
+
+			// ranges can be followed by a comma list e.g. SMAD1..7,9
+			if rest := strings.TrimPrefix(matcher[4], ","); rest != "" {
+				genes = append(genes, expandCommaList(genes[len(genes)-1] + "," + rest)[1:]...)
+			}
+
+			return genes
+		}
+	}
+
+	if matcher := geneBraceRegex.FindStringSubmatch(gene); matcher != nil {
+		prefix := matcher[1]
+
+		if geneNoteRegex.MatchString(matcher[2]) {
+			return []string{prefix}
+		}
+
+		genes := make([]string, 0, 5)
+
+		for _, suffix := range strings.Split(matcher[2], ",") {
+			genes = append(genes, prefix+strings.TrimSpace(suffix))
+		}
+
+		return genes
+	}
+
+	if strings.Contains(gene, ",") {
+		return expandCommaList(gene)
+	}
+
+	return []string{gene}
+}
+
+// comma lists give replacement endings for the first gene so
+// CDX1,2,4 is CDX1, CDX2, CDX4 and CEBPA,B is CEBPA, CEBPB
+func expandCommaList(gene string) []string {
+	tokens := strings.Split(gene, ",")
+	first := tokens[0]
+	genes := []string{first}
+
+	for _, suffix := range tokens[1:] {
+		suffix = strings.TrimSpace(suffix)
+
+		if suffix == "" || len(suffix) >= len(first) {
+			continue
+		}
+
+		// replace the trailing digits or letters of the first gene
+		// with the suffix
+		end := len(first)
+		start := end - len(suffix)
+
+		// multi digit suffixes should replace the whole trailing
+		// number, e.g. SOX9,10 is SOX9 and SOX10
+		if isDigits(suffix) {
+			start = end
+
+			for start > 0 && isDigits(first[start-1:start]) {
+				start--
+			}
+		}
+
+		genes = append(genes, first[:start]+suffix)
+	}
+
+	return genes
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// remove empty and duplicate genes and sort so builds are
+// deterministic
+func uniqueGenes(genes []string) []string {
+	ret := make([]string, 0, len(genes))
+
+	for _, gene := range genes {
+		gene = strings.TrimSpace(gene)
+
+		if gene != "" {
+			ret = append(ret, gene)
+		}
+	}
+
+	slices.Sort(ret)
+
+	return slices.Compact(ret)
+}
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+func TestGeneParsers(t *testing.T) {
+	tests := []struct {
+		dataset string
+		id      string
+		name    string
+		genes   []string
+	}{
+		{"JASPAR2022_CORE_redundant_v2", "MA0099.3", "FOS::JUN", []string{"FOS", "JUN"}},
+		{"JASPAR2022_CORE_redundant_v2", "MA0006.1", "Ahr::Arnt", []string{"Ahr", "Arnt"}},
+		{"SwissRegulon_human_and_mouse", "E2F1..5.p2", "", []string{"E2F1", "E2F2", "E2F3", "E2F4", "E2F5"}},
+		{"SwissRegulon_human_and_mouse", "SMAD1..7,9.p2", "", []string{"SMAD1", "SMAD2", "SMAD3", "SMAD4", "SMAD5", "SMAD6", "SMAD7", "SMAD9"}},
+		{"SwissRegulon_human_and_mouse", "CEBPA,B_DDIT3.p2", "", []string{"CEBPA", "CEBPB", "DDIT3"}},
+		{"SwissRegulon_human_and_mouse", "FOS_FOS{B,L1}_JUN{B,D}.p2", "", []string{"FOS", "FOSB", "FOSL1", "JUNB", "JUND"}},
+		{"SwissRegulon_human_and_mouse", "POU5F1_SOX2{dimer}.p2", "", []string{"POU5F1", "SOX2"}},
+		{"SwissRegulon_human_and_mouse", "NKX2-1,4.p2", "", []string{"NKX2-1", "NKX2-4"}},
+		{"jolma2013", "BARHL2_full_3", "", []string{"BARHL2"}},
+		{"jolma2013", "Alx1_DBD_2", "", []string{"Alx1"}},
+		{"H12CORE", "AHR.H12CORE.0.P.B", "", []string{"AHR"}},
+	}
+
+	for _, test := range tests {
+		motif := &motifs.Motif{MotifId: test.id}
+		motif.Name = test.name
+
+		genes := GeneParserForDataset(test.dataset)(motif)
+
+		if !slices.Equal(genes, test.genes) {
+			t.Errorf("%s: expected %v, found %v", test.id, test.genes, genes)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package build
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+// SpeciesParser works out the species of a motif when the file
+// format does not record it. It returns nil if the species is
+// unknown.
+type SpeciesParser func(motif *motifs.Motif) *motifs.Species
+
+var (
+	// JASPAR collections of vertebrate motifs, e.g.
+	// JASPAR2024_CORE_vertebrates_non-redundant, which are almost all
+	// human or mouse
+	jasparVertebratesRegex = regexp.MustCompile(`(?i)^JASPAR.*vertebrates`)
+)
+
+// SpeciesParserForDataset picks how to assign species for a
+// collection based on its name. Gene nomenclature is only used for
+// collections known to be human or mouse since plant and insect
+// symbols follow the same case conventions. Other collections are
+// left unknown unless the motif file gives species, as JASPAR JSON
+// does.
+func SpeciesParserForDataset(dataset string) SpeciesParser {
+	switch {
+	case hocomocoDatasetRegex.MatchString(dataset):
+		// HOCOMOCO core collections are named after human genes
+		return HumanSpecies
+	case strings.HasPrefix(dataset, "jolma"),
+		jasparVertebratesRegex.MatchString(dataset):
+		return NomenclatureSpecies
+	default:
+		// e.g. SwissRegulon, where human and mouse motifs share
+		// uppercase names, or JASPAR collections of every taxon
+		return UnknownSpecies
+	}
+}
+
+func UnknownSpecies(motif *motifs.Motif) *motifs.Species {
+	return nil
+}
+
+func HumanSpecies(motif *motifs.Motif) *motifs.Species {
+	return motifs.Human
+}
+
+// NomenclatureSpecies uses the convention that human gene symbols
+// are uppercase, e.g. SOX2, and mouse symbols are capitalized, e.g.
+// Sox2, as in JASPAR vertebrates and the Jolma et al. 2013 SELEX
+// motifs. Genes
+// of a motif must agree, otherwise the species is unknown.
+func NomenclatureSpecies(motif *motifs.Motif) *motifs.Species {
+	var ret *motifs.Species
+
+	for _, gene := range motif.Genes {
+		species := geneNomenclatureSpecies(gene)
+
+		if species == nil || (ret != nil && species != ret) {
+			return nil
+		}
+
+		ret = species
+	}
+
+	return ret
+}
+
+func geneNomenclatureSpecies(gene string) *motifs.Species {
+	upper := 0
+	lower := 0
+
+	for i, r := range gene {
+		switch {
+		case unicode.IsUpper(r):
+			// only the first letter of a mouse symbol is uppercase
+			if i > 0 && lower > 0 {
+				return nil
+			}
+
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	switch {
+	case upper > 0 && lower == 0:
+		return motifs.Human
+	case upper == 1 && lower > 0 && unicode.IsUpper([]rune(gene)[0]):
+		return motifs.Mouse
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+func TestNomenclatureSpecies(t *testing.T) {
+	tests := []struct {
+		genes   []string
+		species *motifs.Species
+	}{
+		{[]string{"FOS", "JUN"}, motifs.Human},
+		{[]string{"Ahr", "Arnt"}, motifs.Mouse},
+		{[]string{"Nkx2-1"}, motifs.Mouse},
+		{[]string{"Pou5f1", "SOX2"}, nil},
+		{[]string{"sox2"}, nil},
+		{[]string{}, nil},
+	}
+
+	for _, test := range tests {
+		species := NomenclatureSpecies(&motifs.Motif{Genes: test.genes})
+
+		if species != test.species {
+			t.Errorf("%v: expected %v, found %v", test.genes, test.species, species)
+		}
+	}
+}
+
+func TestSpeciesParserForDataset(t *testing.T) {
+	tests := []struct {
+		dataset string
+		genes   []string
+		species *motifs.Species
+	}{
+		{"H12CORE", []string{"Sox2"}, motifs.Human},
+		{"jolma2013", []string{"Sox2"}, motifs.Mouse},
+		{"JASPAR2024_CORE_vertebrates_non-redundant", []string{"SOX2"}, motifs.Human},
+		// plant and fly genes look human or mouse
+		{"JASPAR2022_CORE_redundant_v2", []string{"AGL3"}, nil},
+		{"JASPAR2024_CORE_insects_non-redundant", []string{"Cf2"}, nil},
+		{"SwissRegulon", []string{"SOX2"}, nil},
+	}
+
+	for _, test := range tests {
+		species := SpeciesParserForDataset(test.dataset)(&motifs.Motif{Genes: test.genes})
+
+		if species != test.species {
+			t.Errorf("%s %v: expected %v, found %v", test.dataset, test.genes, test.species, species)
+		}
+	}
+}
+
+func TestGeneTaxonIds(t *testing.T) {
+	vertebrates := &Dataset{Motifs: []*motifs.Motif{
+		{Genes: []string{"FOS", "JUN"}, Species: motifs.Human},
+		{Genes: []string{"ATF3"}, Species: motifs.Human}}}
+
+	other := &Dataset{Motifs: []*motifs.Motif{
+		{Genes: []string{"FOS"}},
+		{Genes: []string{"JUN"}, Species: motifs.Mouse}}}
+
+	// the same in either order
+	for _, datasets := range [][]*Dataset{{vertebrates, other}, {other, vertebrates}} {
+		ids := geneTaxonIds(datasets)
+
+		if ids["FOS"] != motifs.Human.TaxonId || ids["ATF3"] != motifs.Human.TaxonId || ids["JUN"] != 0 {
+			t.Fatalf("unexpected species %v", ids)
+		}
+	}
+}
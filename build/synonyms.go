@@ -0,0 +1,286 @@
+package build
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/antonybholmes/go-sys/db"
+)
+
+type (
+	// The other names of a gene, e.g. previous HGNC symbols and
+	// common aliases
+	GeneSynonyms struct {
+		Symbol   string
+		Synonyms []string
+	}
+)
+
+const (
+	// names are stored lowercase since they are matched ignoring case
+	SynonymsSchemaSql = `CREATE TABLE IF NOT EXISTS gene_synonyms (
+		symbol TEXT NOT NULL,
+		synonym TEXT NOT NULL,
+		PRIMARY KEY (symbol, synonym));
+	CREATE INDEX IF NOT EXISTS idx_gene_synonyms_synonym ON gene_synonyms (synonym);`
+
+	ClearSynonymsSql = `DELETE FROM gene_synonyms;`
+
+	InsertSynonymSql = `INSERT INTO gene_synonyms (symbol, synonym)
+		VALUES (:symbol, :synonym)
+		ON CONFLICT DO NOTHING;`
+
+	GeneNamesSql = `SELECT LOWER(name) FROM genes`
+)
+
+var (
+	ErrSynonymsHeader = errors.New("synonyms file has no symbol or synonym columns")
+
+	// header names of the symbol column in NCBI gene_info and HGNC
+	// files, in lowercase
+	symbolColumns = []string{"symbol", "approved symbol"}
+
+	// header names of columns holding other names. Values within a
+	// column are separated by | or ,
+	synonymColumns = []string{"synonyms", "alias_symbol", "prev_symbol", "alias symbols", "previous symbols"}
+)
+
+// ReadSynonyms reads a tab separated NCBI gene_info or HGNC style file.
+// Columns are found by name from the header, which may start with #,
+// ignoring case. The symbol column must be called Symbol or Approved
+// symbol and other names must be in Synonyms (NCBI), alias_symbol or
+// prev_symbol (HGNC downloads) or Alias symbols or Previous symbols
+// (HGNC custom downloads) columns.
+// Genes with no other names are skipped.
+func ReadSynonyms(r io.Reader) ([]*GeneSynonyms, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		err := scanner.Err()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrSynonymsHeader
+	}
+
+	header := strings.Split(strings.TrimPrefix(scanner.Text(), "#"), "\t")
+
+	symbolCol := -1
+	synonymCols := make([]int, 0, 2)
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		switch {
+		case slices.Contains(symbolColumns, name):
+			if symbolCol == -1 {
+				symbolCol = i
+			}
+		case slices.Contains(synonymColumns, name):
+			synonymCols = append(synonymCols, i)
+		}
+	}
+
+	if symbolCol == -1 || len(synonymCols) == 0 {
+		return nil, ErrSynonymsHeader
+	}
+
+	ret := make([]*GeneSynonyms, 0, 20000)
+
+	for scanner.Scan() {
+		tokens := strings.Split(scanner.Text(), "\t")
+
+		if len(tokens) <= symbolCol {
+			continue
+		}
+
+		symbol := synonymValue(tokens[symbolCol])
+
+		if symbol == "" {
+			continue
+		}
+
+		gene := GeneSynonyms{Symbol: symbol}
+
+		for _, col := range synonymCols {
+			if col >= len(tokens) {
+				continue
+			}
+
+			for _, synonym := range strings.FieldsFunc(tokens[col], func(r rune) bool {
+				return r == '|' || r == ','
+			}) {
+				synonym = synonymValue(synonym)
+
+				if synonym != "" && !strings.EqualFold(synonym, symbol) {
+					gene.Synonyms = append(gene.Synonyms, synonym)
+				}
+			}
+		}
+
+		if len(gene.Synonyms) > 0 {
+			ret = append(ret, &gene)
+		}
+	}
+
+	return ret, scanner.Err()
+}
+
+// trim quotes and treat - as missing, as in gene_info files
+func synonymValue(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+
+	if s == "-" {
+		return ""
+	}
+
+	return s
+}
+
+// ReadSynonymsFile reads a synonyms file, which may be gzipped as
+// gene_info files are when downloaded from NCBI
+func ReadSynonymsFile(file string) ([]*GeneSynonyms, error) {
+	r, err := openFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	synonyms, err := ReadSynonyms(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return synonyms, nil
+}
+
+// AddSynonyms replaces the gene synonyms of an existing database. Only
+// genes where the symbol or one of the synonyms is a gene in the
+// database are kept, since nothing else can be searched for. Returns
+// the number of symbol and synonym pairs stored.
+func AddSynonyms(file string, synonyms []*GeneSynonyms) (int, error) {
+	conn, err := sql.Open(db.Sqlite3DB, file)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	tx, err := conn.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	n, err := insertSynonyms(tx, synonyms)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+func insertSynonyms(tx *sql.Tx, synonyms []*GeneSynonyms) (int, error) {
+	_, err := tx.Exec(SynonymsSchemaSql)
+
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ClearSynonymsSql)
+
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(GeneNamesSql)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	genes := make(map[string]struct{}, 1000)
+
+	for rows.Next() {
+		var name string
+
+		err := rows.Scan(&name)
+
+		if err != nil {
+			return 0, err
+		}
+
+		genes[name] = struct{}{}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return 0, err
+	}
+
+	rows.Close()
+
+	stmt, err := tx.Prepare(InsertSynonymSql)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer stmt.Close()
+
+	n := 0
+
+	for _, gene := range synonyms {
+		symbol := strings.ToLower(gene.Symbol)
+		_, found := genes[symbol]
+
+		for _, synonym := range gene.Synonyms {
+			if found {
+				break
+			}
+
+			_, found = genes[strings.ToLower(synonym)]
+		}
+
+		if !found {
+			continue
+		}
+
+		for _, synonym := range gene.Synonyms {
+			res, err := stmt.Exec(sql.Named("symbol", symbol),
+				sql.Named("synonym", strings.ToLower(synonym)))
+
+			if err != nil {
+				return 0, err
+			}
+
+			added, err := res.RowsAffected()
+
+			if err != nil {
+				return 0, err
+			}
+
+			n += int(added)
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+const geneInfo = "#tax_id\tGeneID\tSymbol\tLocusTag\tSynonyms\tdbXrefs\n" +
+	"9606\t5460\tPOU5F1\t-\tOCT3|OCT4|OTF3|OTF4\t-\n" +
+	"9606\t7157\tTP53\t-\tBCC7|LFS1|P53\t-\n" +
+	"9606\t1\tA1BG\t-\t-\t-\n"
+
+func TestReadSynonyms(t *testing.T) {
+	synonyms, err := ReadSynonyms(strings.NewReader(geneInfo))
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// genes without synonyms are skipped
+	if len(synonyms) != 2 || synonyms[0].Symbol != "POU5F1" ||
+		!slices.Equal(synonyms[0].Synonyms, []string{"OCT3", "OCT4", "OTF3", "OTF4"}) {
+		t.Fatalf("unexpected synonyms %v", synonyms)
+	}
+
+	hgnc := "hgnc_id\tsymbol\tname\talias_symbol\tprev_symbol\n" +
+		"HGNC:9221\tPOU5F1\tPOU class 5 homeobox 1\t\"Oct4|OCT3\"\tOTF3\n"
+
+	synonyms, err = ReadSynonyms(strings.NewReader(hgnc))
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(synonyms) != 1 || !slices.Equal(synonyms[0].Synonyms, []string{"Oct4", "OCT3", "OTF3"}) {
+		t.Fatalf("unexpected hgnc synonyms %v", synonyms)
+	}
+
+	_, err = ReadSynonyms(strings.NewReader("a\tb\n"))
+
+	if err == nil {
+		t.Fatalf("expected header error")
+	}
+}
+
+// copy of the test database that can be changed
+func testDatabase(t *testing.T) string {
+	data, err := os.ReadFile("../../data/modules/motifs/motifs.db")
+
+	if err != nil {
+		t.Skipf("no test database: %s", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "motifs.db")
+
+	err = os.WriteFile(file, data, 0644)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	return file
+}
+
+func TestAddSynonyms(t *testing.T) {
+	file := testDatabase(t)
+
+	synonyms, err := ReadSynonyms(strings.NewReader(geneInfo))
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	n, err := AddSynonyms(file, synonyms)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if n != 7 {
+		t.Fatalf("expected 7 synonyms not %d", n)
+	}
+
+	mdb := motifs.NewMotifDB(file)
+
+	datasets, err := mdb.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	res, err := mdb.Search([]string{"oct4"}, ids, nil, &motifs.Paging{Page: 1, PageSize: 100}, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if res.Total == 0 {
+		t.Fatalf("no motifs found for an alias")
+	}
+
+	for _, motif := range res.Motifs {
+		if len(motif.Aliases) == 0 || !strings.EqualFold(motif.Aliases[0].Gene, "POU5F1") {
+			t.Fatalf("motif %s does not say which alias matched", motif.MotifId)
+		}
+	}
+
+	genes, err := mdb.MotifsToGenes([]string{"OCT4"})
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(genes) != 1 || !slices.ContainsFunc(genes[0].Aliases, func(gene string) bool {
+		return strings.EqualFold(gene, "POU5F1")
+	}) {
+		t.Fatalf("unexpected motifs to genes %v", genes)
+	}
+}
@@ -0,0 +1,231 @@
+package motifs
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+)
+
+type (
+	CentralOptions struct {
+		// how sequences are scanned. The best site in each sequence
+		// is counted if its p-value is at or below the threshold
+		ScanOptions
+
+		// only report motifs with an E-value at or below this, all
+		// motifs if 0
+		MaxEValue float64 `json:"maxEValue"`
+	}
+
+	// Where the best sites of a motif fall in sequences centred on
+	// peak summits, and the central window they are most enriched in
+	MotifCentrality struct {
+		Id      string `json:"id"`
+		MotifId string `json:"motifId"`
+		Name    string `json:"name"`
+		// sequences whose best site passed the threshold
+		Sites int `json:"sites"`
+		// best central window in site centre positions relative to
+		// the sequence centre, inclusive
+		WindowStart float64 `json:"windowStart"`
+		WindowEnd   float64 `json:"windowEnd"`
+		// sites in the window
+		WindowSites int `json:"windowSites"`
+		// fraction of possible site positions in the window, which
+		// would be the fraction of sites in it if they were uniform
+		WindowFraction float64 `json:"windowFraction"`
+		// binomial p-value of the best window, multiplied by the
+		// number of windows tried
+		PValue float64 `json:"pvalue"`
+		// p-value multiplied by the number of motifs tested
+		EValue float64 `json:"evalue"`
+		// best sites by position
+		Histogram *SiteHistogram `json:"histogram"`
+	}
+
+	// Counts of best sites at each position a site can be centred on,
+	// from First to the last position in steps of 1, relative to the
+	// sequence centre. Positions end in .5 if the site could be
+	// centred between two bases.
+	SiteHistogram struct {
+		First  float64 `json:"first"`
+		Counts []int   `json:"counts"`
+	}
+)
+
+var (
+	ErrCentralLengths = errors.New("sequences must all be the same length")
+)
+
+func NewCentralOptions() *CentralOptions {
+	return &CentralOptions{ScanOptions: *NewScanOptions()}
+}
+
+// CentralEnrichment tests whether the best site of each motif in
+// equal length sequences, e.g. regions centred on ChIP-seq summits,
+// tends to be near the centre, as CentriMo does. Symmetric central
+// windows of every width are tested with a binomial test of the sites
+// falling in them against uniformly placed sites, and the best window
+// is reported. Results are sorted by p-value.
+func CentralEnrichment(seqs []*Sequence, motifs []*Motif, opts *CentralOptions) ([]*MotifCentrality, error) {
+	if opts == nil {
+		opts = NewCentralOptions()
+	}
+
+	if len(seqs) == 0 {
+		return nil, ErrNoSequences
+	}
+
+	length := len(seqs[0].Seq)
+
+	for _, seq := range seqs {
+		if len(seq.Seq) != length {
+			return nil, ErrCentralLengths
+		}
+	}
+
+	scanner, err := NewScanner(motifs, &opts.ScanOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	windows := scanner.sequenceWindows(seqs)
+
+	results := make([]*MotifCentrality, len(motifs))
+
+	parallel(len(motifs), func(i int) {
+		results[i] = scanner.centralMotif(i, windows, length)
+	})
+
+	for _, result := range results {
+		result.EValue = result.PValue * float64(len(motifs))
+	}
+
+	if opts.MaxEValue > 0 {
+		results = slices.DeleteFunc(results, func(result *MotifCentrality) bool {
+			return result.EValue > opts.MaxEValue
+		})
+	}
+
+	slices.SortStableFunc(results, func(a, b *MotifCentrality) int {
+		return cmp.Compare(a.PValue, b.PValue)
+	})
+
+	return results, nil
+}
+
+// 0-based start of the best scoring site of a motif in a window, on
+// either strand, or -1 if no site passes the threshold. The first of
+// equally good sites is used.
+func (scanner *Scanner) bestSite(i int, window *scanWindow) int {
+	best := -1
+	bestScore := 0.0
+
+	scanner.scanMotif(i, window, func(hit *ScanHit) error {
+		if best == -1 || hit.Score > bestScore {
+			best = hit.Start - 1
+			bestScore = hit.Score
+		}
+
+		return nil
+	})
+
+	return best
+}
+
+func (scanner *Scanner) centralMotif(i int, windows []*scanWindow, length int) *MotifCentrality {
+	motif := scanner.motifs[i]
+	w := len(motif.Weights)
+
+	// positions a site can start at
+	positions := max(length-w+1, 0)
+
+	result := MotifCentrality{Id: motif.PublicId,
+		MotifId: motif.MotifId,
+		Name:    motif.Name,
+		PValue:  1,
+		Histogram: &SiteHistogram{First: float64(w-length) / 2,
+			Counts: make([]int, positions)}}
+
+	for _, window := range windows {
+		start := scanner.bestSite(i, window)
+
+		if start >= 0 {
+			result.Histogram.Counts[start]++
+			result.Sites++
+		}
+	}
+
+	if positions < 2 || result.Sites == 0 {
+		return &result
+	}
+
+	// windows grow outwards from the centre two positions at a time
+	// so they stay symmetric. Widths have the same parity as the
+	// number of positions.
+	width := 2 - positions%2
+	tried := 0
+	bestWindowSites := 0
+	bestWidth := 0
+	bestP := 1.0
+
+	for ; width < positions; width += 2 {
+		from := (positions - width) / 2
+		n := 0
+
+		for _, count := range result.Histogram.Counts[from : from+width] {
+			n += count
+		}
+
+		p := BinomialUpperTail(n, result.Sites, float64(width)/float64(positions))
+		tried++
+
+		if p < bestP || bestWidth == 0 {
+			bestP = p
+			bestWidth = width
+			bestWindowSites = n
+		}
+	}
+
+	if bestWidth == 0 {
+		return &result
+	}
+
+	from := (positions - bestWidth) / 2
+
+	result.WindowStart = result.Histogram.First + float64(from)
+	result.WindowEnd = result.WindowStart + float64(bestWidth-1)
+	result.WindowSites = bestWindowSites
+	result.WindowFraction = float64(bestWidth) / float64(positions)
+	result.PValue = min(bestP*float64(tried), 1)
+
+	return &result
+}
+
+// CentralEnrichment tests motifs chosen from the database for central
+// enrichment in equal length sequences
+func (mdb *MotifDB) CentralEnrichment(seqs []*Sequence,
+	selection *MotifSelection,
+	opts *CentralOptions) ([]*MotifCentrality, error) {
+
+	motifs, err := mdb.SelectMotifs(selection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil {
+		scanOpts, err := mdb.withDatasetBackgrounds(motifs, &opts.ScanOptions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filled := *opts
+		filled.ScanOptions = *scanOpts
+		opts = &filled
+	}
+
+	return CentralEnrichment(seqs, motifs, opts)
+}
@@ -0,0 +1,64 @@
+package motifs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCentralEnrichment(t *testing.T) {
+	seqs := make([]*Sequence, 0, 40)
+
+	for i := range 40 {
+		seq := []byte(strings.Repeat("C", 40))
+
+		// most sites at the centre, the rest near the ends
+		start := 18
+
+		if i%4 == 0 {
+			start = i % 8
+		}
+
+		copy(seq[start:], "GATA")
+
+		seqs = append(seqs, &Sequence{Name: fmt.Sprintf("seq%d", i), Seq: string(seq)})
+	}
+
+	opts := NewCentralOptions()
+	opts.PValue = 0.01
+	opts.Strand = StrandPlus
+
+	results, err := CentralEnrichment(seqs, []*Motif{testMotif()}, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := results[0]
+
+	if result.Sites != 40 || len(result.Histogram.Counts) != 37 || result.Histogram.First != -18 {
+		t.Fatalf("unexpected sites %+v %+v", result, result.Histogram)
+	}
+
+	if result.Histogram.Counts[18] != 30 {
+		t.Fatalf("expected 30 central sites, found %d", result.Histogram.Counts[18])
+	}
+
+	// the central position alone holds the most sites for the space
+	if result.WindowStart != 0 || result.WindowEnd != 0 || result.WindowSites != 30 {
+		t.Fatalf("unexpected window %g to %g with %d sites", result.WindowStart, result.WindowEnd, result.WindowSites)
+	}
+
+	if result.PValue > 1e-20 || result.EValue != result.PValue {
+		t.Fatalf("unexpected p-value %g", result.PValue)
+	}
+
+	seqs[0].Seq = "ACGT"
+
+	_, err = CentralEnrichment(seqs, []*Motif{testMotif()}, opts)
+
+	if !errors.Is(err, ErrCentralLengths) {
+		t.Fatalf("expected length error, found %v", err)
+	}
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-motifs/background"
+	"github.com/antonybholmes/go-motifs/build"
+	"github.com/antonybholmes/go-motifs/genome"
+	"github.com/antonybholmes/go-motifs/tracks"
+)
+
+const (
+	// regions read around peaks for central enrichment, as CentriMo
+	// recommends
+	DefaultCentralWidth = 500
+)
+
+const usage = `Usage: motifs <command> [options]
+
+Commands:
+  build       build a motif database from motif files
+  synonyms    load gene synonyms into an existing database
+  families    load transcription factor classes and families into an existing database
+  scan        scan a genome FASTA or 2bit file for motif occurrences
+  enrich      test motifs for enrichment in sequences or regions against a background
+  central     test motifs for enrichment at the centre of peaks
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "build":
+		err = buildCmd(os.Args[2:])
+	case "synonyms":
+		err = synonymsCmd(os.Args[2:])
+	case "families":
+		err = familiesCmd(os.Args[2:])
+	case "scan":
+		err = scanCmd(os.Args[2:])
+	case "enrich":
+		err = enrichCmd(os.Args[2:])
+	case "central":
+		err = centralCmd(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "motifs: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func buildCmd(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+
+	out := fs.String("o", "motifs.db", "database file to create")
+	synonyms := fs.String("synonyms", "", "optional NCBI gene_info or HGNC file of gene synonyms")
+	families := fs.String("families", "", "optional HOCOMOCO annotation .jsonl or tab separated file of motif families")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: motifs build [-o motifs.db] [-synonyms gene_info.gz] [-families annotation.jsonl] [name=]file.meme ...\n\n")
+		fmt.Fprintf(os.Stderr, "Each file becomes a dataset named after the file unless a name is given.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	datasets, err := build.ReadDatasets(fs.Args())
+
+	if err != nil {
+		return err
+	}
+
+	err = build.Build(*out, datasets)
+
+	if err != nil {
+		return err
+	}
+
+	total := 0
+
+	for _, dataset := range datasets {
+		total += len(dataset.Motifs)
+	}
+
+	fmt.Printf("wrote %d motifs in %d datasets to %s\n", total, len(datasets), *out)
+
+	if *synonyms != "" {
+		err = addSynonyms(*out, *synonyms)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if *families != "" {
+		return addFamilies(*out, *families)
+	}
+
+	return nil
+}
+
+func synonymsCmd(args []string) error {
+	fs := flag.NewFlagSet("synonyms", flag.ExitOnError)
+
+	out := fs.String("o", "motifs.db", "database file to update")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: motifs synonyms [-o motifs.db] gene_info.gz\n\n")
+		fmt.Fprintf(os.Stderr, "Replaces the gene synonyms of a database with those in an NCBI gene_info or HGNC file.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	return addSynonyms(*out, fs.Arg(0))
+}
+
+func addSynonyms(out string, file string) error {
+	synonyms, err := build.ReadSynonymsFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := build.AddSynonyms(out, synonyms)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %d gene synonyms to %s\n", n, out)
+
+	return nil
+}
+
+func familiesCmd(args []string) error {
+	fs := flag.NewFlagSet("families", flag.ExitOnError)
+
+	out := fs.String("o", "motifs.db", "database file to update")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: motifs families [-o motifs.db] annotation.jsonl|families.tsv ...\n\n")
+		fmt.Fprintf(os.Stderr, "Replaces the classes and families of a database with those in HOCOMOCO\n")
+		fmt.Fprintf(os.Stderr, "annotation files or tab separated files with motif_id or gene, class and\n")
+		fmt.Fprintf(os.Stderr, "family columns, e.g. the H12CORE and H13CORE annotations together.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	return addFamilies(*out, fs.Args()...)
+}
+
+func addFamilies(out string, files ...string) error {
+	families := make([]*build.FamilyAnnotation, 0, 5000)
+
+	for _, file := range files {
+		annotations, err := build.ReadFamiliesFile(file)
+
+		if err != nil {
+			return err
+		}
+
+		families = append(families, annotations...)
+	}
+
+	n, err := build.AddFamilies(out, families)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("annotated %d motifs with families in %s\n", n, out)
+
+	return nil
+}
+
+func scanCmd(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+
+	dbFile := fs.String("db", "motifs.db", "motif database")
+	genomeFile := fs.String("genome", "", "indexed FASTA (.fa with .fa.fai) or .2bit file to scan")
+	ids := fs.String("ids", "", "comma separated motif ids")
+	datasets := fs.String("datasets", "", "comma separated dataset ids")
+	q := fs.String("q", "", "comma separated searches for motifs")
+	pvalue := fs.Float64("p", motifs.DefaultScanPValue, "p-value threshold")
+	strand := fs.String("strand", "", "+ or - to scan one strand, otherwise both")
+	skipSoftMasked := fs.Bool("skip-soft-masked", false, "do not report hits overlapping lowercase bases")
+	bedFile := fs.String("bed", "", "BED or narrowPeak file of regions to scan")
+	width := fs.Int("width", 0, "resize regions to this width around their summits or midpoints")
+	extend := fs.Int("extend", 0, "extend regions by this many bases on each side")
+	format := fs.String("format", tracks.FormatTSV, "output format, tsv (as FIMO), bed or gff3")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: motifs scan -genome hg38.2bit [-db motifs.db] [-ids ...] [-datasets ...] [-q ...] [-bed peaks.bed] [chr|chr:start-end ...]\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the hits of the selected motifs in the given regions, or the whole\n")
+		fmt.Fprintf(os.Stderr, "genome, to stdout in chromosome coordinates.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if *genomeFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	selection := motifs.MotifSelection{Ids: splitList(*ids),
+		Datasets: splitList(*datasets),
+		Queries:  splitList(*q)}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		return fmt.Errorf("no motifs selected, use -ids, -datasets or -q")
+	}
+
+	regions := make([]*genome.Region, 0, fs.NArg())
+
+	for _, location := range fs.Args() {
+		region, err := genome.ParseRegion(location)
+
+		if err != nil {
+			return err
+		}
+
+		regions = append(regions, region)
+	}
+
+	if *bedFile != "" {
+		bedRegions, err := genome.ReadBedFile(*bedFile)
+
+		if err != nil {
+			return err
+		}
+
+		regions = append(regions, bedRegions...)
+	}
+
+	hw, err := tracks.NewHitWriter(os.Stdout, *format)
+
+	if err != nil {
+		return err
+	}
+
+	reader, err := genome.Open(*genomeFile)
+
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	if *width > 0 || *extend > 0 {
+		regions, err = genome.ResizeRegions(regions, *width, *extend, reader.Chromosomes())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := motifs.NewScanOptions()
+	opts.PValue = *pvalue
+	opts.Strand = *strand
+	opts.SkipSoftMasked = *skipSoftMasked
+
+	mdb := motifs.NewMotifDB(*dbFile)
+
+	err = mdb.ScanGenome(reader, regions, &selection, opts, hw.Write)
+
+	if err != nil {
+		return err
+	}
+
+	return hw.Flush()
+}
+
+func enrichCmd(args []string) error {
+	fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+
+	dbFile := fs.String("db", "motifs.db", "motif database")
+	genomeFile := fs.String("genome", "", "indexed FASTA or .2bit file, needed for BED files and -control gc")
+	fastaFile := fs.String("fasta", "", "FASTA file of target sequences")
+	bedFile := fs.String("bed", "", "BED or narrowPeak file of target regions")
+	bgFastaFile := fs.String("bg-fasta", "", "FASTA file of background sequences")
+	bgBedFile := fs.String("bg-bed", "", "BED file of background regions")
+	control := fs.String("control", "shuffle", "background if none is given, shuffle (dinucleotide shuffled targets) or gc (GC matched genomic regions)")
+	times := fs.Int("times", 1, "background sequences generated per target")
+	k := fs.Int("k", background.DefaultShuffleK, "k-mer size kept by -control shuffle")
+	seed := fs.Uint64("seed", 0, "seed for generating backgrounds reproducibly, random if 0")
+	excludeFile := fs.String("exclude", "", "BED file of regions -control gc must not sample, e.g. a blacklist. BED targets are always excluded")
+	gcTolerance := fs.Float64("gc-tolerance", background.DefaultGCTolerance, "GC fraction sampled regions must be within of their target")
+	width := fs.Int("width", 0, "resize regions to this width around their summits or midpoints")
+	ids := fs.String("ids", "", "comma separated motif ids")
+	datasets := fs.String("datasets", "", "comma separated dataset ids")
+	q := fs.String("q", "", "comma separated searches for motifs")
+	pvalue := fs.Float64("p", motifs.DefaultScanPValue, "p-value threshold for a sequence to have a motif")
+	test := fs.String("test", string(motifs.EnrichTestFisher), "fisher or binomial")
+	maxQValue := fs.Float64("max-q", 0, "only report motifs with a q-value at or below this")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: motifs enrich [-db motifs.db] [-genome hg38.2bit] -fasta targets.fa|-bed peaks.bed [-bg-fasta bg.fa|-bg-bed bg.bed|-control shuffle|gc] [-ids ...] [-datasets ...] [-q ...]\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the enrichment of each selected motif in the targets to stdout as a\n")
+		fmt.Fprintf(os.Stderr, "tab separated table sorted by p-value.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if *fastaFile == "" && *bedFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	selection := motifs.MotifSelection{Ids: splitList(*ids),
+		Datasets: splitList(*datasets),
+		Queries:  splitList(*q)}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		return fmt.Errorf("no motifs selected, use -ids, -datasets or -q")
+	}
+
+	var reader genome.Reader
+
+	if *genomeFile != "" {
+		var err error
+
+		reader, err = genome.Open(*genomeFile)
+
+		if err != nil {
+			return err
+		}
+
+		defer reader.Close()
+	}
+
+	targets, err := readSequences(*fastaFile, *bedFile, reader, *width)
+
+	if err != nil {
+		return err
+	}
+
+	rng := background.NewRand(*seed)
+
+	var backgroundSeqs []*motifs.Sequence
+
+	switch {
+	case *bgFastaFile != "" || *bgBedFile != "":
+		backgroundSeqs, err = readSequences(*bgFastaFile, *bgBedFile, reader, *width)
+	case *control == "gc":
+		if reader == nil {
+			return fmt.Errorf("-control gc needs a -genome")
+		}
+
+		sampleOpts := background.NewSampleOptions()
+		sampleOpts.Times = *times
+		sampleOpts.Tolerance = *gcTolerance
+
+		sampleOpts.Exclude, err = excludedRegions(*bedFile, *excludeFile)
+
+		if err != nil {
+			return err
+		}
+
+		backgroundSeqs, err = background.GCMatched(reader, targets, sampleOpts, rng)
+	case *control == "shuffle":
+		backgroundSeqs, err = background.Shuffled(targets, *k, *times, rng)
+	default:
+		return fmt.Errorf("-control must be shuffle or gc")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	opts := motifs.NewEnrichOptions()
+	opts.PValue = *pvalue
+	opts.Test = motifs.EnrichTest(*test)
+	opts.MaxQValue = *maxQValue
+
+	mdb := motifs.NewMotifDB(*dbFile)
+
+	results, err := mdb.Enrich(targets, backgroundSeqs, &selection, opts)
+
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "id\tmotif_id\tname\ttarget_hits\ttargets\ttarget_fraction\tbackground_hits\tbackgrounds\tbackground_fraction\todds_ratio\tp-value\tq-value")
+
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%.4g\t%d\t%d\t%.4g\t%.4g\t%.3g\t%.3g\n",
+			result.Id,
+			result.MotifId,
+			result.Name,
+			result.TargetHits,
+			result.Targets,
+			result.TargetFraction,
+			result.BackgroundHits,
+			result.Backgrounds,
+			result.BackgroundFraction,
+			result.OddsRatio,
+			result.PValue,
+			result.QValue)
+	}
+
+	return nil
+}
+
+func centralCmd(args []string) error {
+	fs := flag.NewFlagSet("central", flag.ExitOnError)
+
+	dbFile := fs.String("db", "motifs.db", "motif database")
+	genomeFile := fs.String("genome", "", "indexed FASTA or .2bit file, needed for BED files")
+	fastaFile := fs.String("fasta", "", "FASTA file of equal length sequences")
+	bedFile := fs.String("bed", "", "BED or narrowPeak file of peaks")
+	width := fs.Int("width", DefaultCentralWidth, "width of the regions read around the summits or midpoints of BED peaks")
+	ids := fs.String("ids", "", "comma separated motif ids")
+	datasets := fs.String("datasets", "", "comma separated dataset ids")
+	q := fs.String("q", "", "comma separated searches for motifs")
+	pvalue := fs.Float64("p", motifs.DefaultScanPValue, "p-value threshold for the best site in a sequence")
+	maxEValue := fs.Float64("max-e", 0, "only report motifs with an E-value at or below this")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: motifs central [-db motifs.db] [-genome hg38.2bit] -fasta peaks.fa|-bed peaks.narrowPeak [-width 500] [-ids ...] [-datasets ...] [-q ...]\n\n")
+		fmt.Fprintf(os.Stderr, "Writes the central enrichment of each selected motif, with its best window\n")
+		fmt.Fprintf(os.Stderr, "and site histogram, to stdout as JSON sorted by p-value.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if *fastaFile == "" && *bedFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	selection := motifs.MotifSelection{Ids: splitList(*ids),
+		Datasets: splitList(*datasets),
+		Queries:  splitList(*q)}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		return fmt.Errorf("no motifs selected, use -ids, -datasets or -q")
+	}
+
+	// peaks are only made equal length by resizing them
+	if *bedFile != "" && *width <= 0 {
+		return fmt.Errorf("-width must be positive for BED files")
+	}
+
+	var reader genome.Reader
+
+	if *genomeFile != "" {
+		var err error
+
+		reader, err = genome.Open(*genomeFile)
+
+		if err != nil {
+			return err
+		}
+
+		defer reader.Close()
+	}
+
+	seqs, err := readSequences(*fastaFile, *bedFile, reader, *width)
+
+	if err != nil {
+		return err
+	}
+
+	// regions clipped at the ends of chromosomes are shorter than the
+	// rest so are left out
+	if *bedFile != "" {
+		seqs = slices.DeleteFunc(seqs, func(seq *motifs.Sequence) bool {
+			return len(seq.Seq) != *width
+		})
+	}
+
+	opts := motifs.NewCentralOptions()
+	opts.PValue = *pvalue
+	opts.MaxEValue = *maxEValue
+
+	mdb := motifs.NewMotifDB(*dbFile)
+
+	results, err := mdb.CentralEnrichment(seqs, &selection, opts)
+
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(results)
+}
+
+// read sequences from a FASTA file or the regions of a BED file, which
+// need a genome
+func readSequences(fastaFile string, bedFile string, reader genome.Reader, width int) ([]*motifs.Sequence, error) {
+	if fastaFile != "" {
+		return motifs.ReadFastaFile(fastaFile)
+	}
+
+	if reader == nil {
+		return nil, fmt.Errorf("BED files need a -genome to read sequences from")
+	}
+
+	regions, err := genome.ReadBedFile(bedFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if width > 0 {
+		regions, err = genome.ResizeRegions(regions, width, 0, reader.Chromosomes())
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return motifs.RegionSequences(reader, regions)
+}
+
+// regions GC matched backgrounds must avoid, the targets and any
+// regions in an exclusion file
+func excludedRegions(files ...string) ([]*genome.Region, error) {
+	ret := make([]*genome.Region, 0, 1000)
+
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+
+		regions, err := genome.ReadBedFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, regions...)
+	}
+
+	return ret, nil
+}
+
+// split a comma separated flag into its non empty values
+func splitList(s string) []string {
+	values := make([]string, 0, 10)
+
+	for _, value := range strings.Split(s, ",") {
+		value = strings.TrimSpace(value)
+
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
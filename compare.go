@@ -0,0 +1,526 @@
+package motifs
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+type (
+	// How two motif columns are compared. All metrics are
+	// similarities so higher is better
+	CompareMetric string
+
+	CompareOptions struct {
+		// defaults to Pearson correlation
+		Metric CompareMetric `json:"metric"`
+		// minimum number of overlapping columns, or the length of
+		// the shorter motif if less
+		MinOverlap int `json:"minOverlap"`
+		// only report matches with an E-value at or below this
+		MaxEValue float64 `json:"maxEValue"`
+		// keep at most this many of the best matches
+		MaxMatches int `json:"maxMatches"`
+	}
+
+	// How well a target motif matches a query. Offset is the
+	// position of the first query column relative to the first
+	// target column in the given orientation of the target, so a
+	// negative offset means the query starts before the target
+	MotifMatch struct {
+		Motif   *Motif  `json:"motif"`
+		Strand  string  `json:"strand"`
+		Offset  int     `json:"offset"`
+		Overlap int     `json:"overlap"`
+		Score   float64 `json:"score"`
+		PValue  float64 `json:"pvalue"`
+		EValue  float64 `json:"evalue"`
+		QValue  float64 `json:"qvalue"`
+	}
+)
+
+const (
+	MetricPearson            CompareMetric = "pearson"
+	MetricEuclidean          CompareMetric = "euclidean"
+	MetricSandelinWasserman  CompareMetric = "sw"
+	DefaultMinOverlap                      = 5
+	DefaultCompareMaxEValue                = 10
+	DefaultCompareMaxMatches               = 100
+
+	// resolution of the empirical column score distributions
+	compareScoreBins = 100
+
+	// null distributions are kept for every range of query columns so
+	// their memory grows with the cube of the query width. Real motifs
+	// are rarely more than 30 columns.
+	MaxCompareQueryWidth = 50
+)
+
+var (
+	ErrCompareMetric = errors.New("unknown comparison metric")
+	ErrNoTargets     = errors.New("no motifs to compare against")
+	ErrQueryTooWide  = fmt.Errorf("query motif must have at most %d positions", MaxCompareQueryWidth)
+)
+
+func NewCompareOptions() *CompareOptions {
+	return &CompareOptions{Metric: MetricPearson,
+		MinOverlap: DefaultMinOverlap,
+		MaxEValue:  DefaultCompareMaxEValue,
+		MaxMatches: DefaultCompareMaxMatches}
+}
+
+// fill in defaults and check options are sensible
+func (opts *CompareOptions) validate() error {
+	if opts.Metric == "" {
+		opts.Metric = MetricPearson
+	}
+
+	if columnScorer(opts.Metric) == nil {
+		return fmt.Errorf("%w: %s", ErrCompareMetric, opts.Metric)
+	}
+
+	if opts.MinOverlap <= 0 {
+		opts.MinOverlap = DefaultMinOverlap
+	}
+
+	if opts.MaxEValue <= 0 {
+		opts.MaxEValue = DefaultCompareMaxEValue
+	}
+
+	if opts.MaxMatches <= 0 {
+		opts.MaxMatches = DefaultCompareMaxMatches
+	}
+
+	return nil
+}
+
+func columnScorer(metric CompareMetric) func(a, b []float64) float64 {
+	switch metric {
+	case MetricPearson:
+		return pearsonColumns
+	case MetricEuclidean:
+		return euclideanColumns
+	case MetricSandelinWasserman:
+		return sandelinWassermanColumns
+	default:
+		return nil
+	}
+}
+
+func pearsonColumns(a, b []float64) float64 {
+	n := float64(len(a))
+	ma := 0.0
+	mb := 0.0
+
+	for i := range a {
+		ma += a[i]
+		mb += b[i]
+	}
+
+	ma /= n
+	mb /= n
+
+	cov := 0.0
+	va := 0.0
+	vb := 0.0
+
+	for i := range a {
+		da := a[i] - ma
+		db := b[i] - mb
+		cov += da * db
+		va += da * da
+		vb += db * db
+	}
+
+	// a uniform column carries no information to correlate
+	if va == 0 || vb == 0 {
+		return 0
+	}
+
+	return cov / math.Sqrt(va*vb)
+}
+
+func euclideanColumns(a, b []float64) float64 {
+	d := 0.0
+
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+
+	return -math.Sqrt(d)
+}
+
+func sandelinWassermanColumns(a, b []float64) float64 {
+	d := 0.0
+
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+
+	return 2 - d
+}
+
+// NormalizeWeights scales each row of a matrix to sum to 1 so that
+// counts can be used in place of probabilities
+func NormalizeWeights(weights [][]float64) ([][]float64, error) {
+	if len(weights) == 0 {
+		return nil, ErrEmptyMotif
+	}
+
+	ret := make([][]float64, len(weights))
+
+	for i, row := range weights {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("%w: position %d", ErrWeights, i+1)
+		}
+
+		sum := 0.0
+
+		for _, v := range row {
+			if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("%w: position %d", ErrWeights, i+1)
+			}
+
+			sum += v
+		}
+
+		if sum == 0 {
+			return nil, fmt.Errorf("%w: position %d", ErrWeights, i+1)
+		}
+
+		ret[i] = make([]float64, 4)
+
+		for b, v := range row {
+			ret[i][b] = v / sum
+		}
+	}
+
+	return ret, nil
+}
+
+// one orientation of a target motif
+type compareTarget struct {
+	motif  *Motif
+	strand string
+	// index of the first column in the column pool
+	start  int
+	length int
+}
+
+// CompareMotifs ranks targets by their similarity to the query in
+// the style of Tomtom. Column scores between each query column and
+// every target column, on both strands, form an empirical null
+// distribution. The p-value of an alignment is the chance of a
+// score at least as high from the convolved null distributions of
+// the overlapping query columns. Each target is reported at its
+// best offset and strand, corrected for the number of alignments
+// tried, with an E-value over all targets and a Benjamini-Hochberg
+// q-value.
+func CompareMotifs(query *Motif, targets []*Motif, opts *CompareOptions) ([]*MotifMatch, error) {
+	if opts == nil {
+		opts = NewCompareOptions()
+	}
+
+	err := opts.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(query.Weights) > MaxCompareQueryWidth {
+		return nil, ErrQueryTooWide
+	}
+
+	qw, err := NormalizeWeights(query.Weights)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	scorer := columnScorer(opts.Metric)
+
+	// gather every column of every target orientation
+	pool := make([][]float64, 0, len(targets)*30)
+	orientations := make([]*compareTarget, 0, len(targets)*2)
+
+	for _, target := range targets {
+		tw, err := NormalizeWeights(target.Weights)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", target.MotifId, err)
+		}
+
+		orientations = append(orientations, &compareTarget{motif: target,
+			strand: StrandPlus,
+			start:  len(pool),
+			length: len(tw)})
+		pool = append(pool, tw...)
+
+		rc := cloneMatrix(tw)
+		RevCompWeights(rc)
+
+		orientations = append(orientations, &compareTarget{motif: target,
+			strand: StrandMinus,
+			start:  len(pool),
+			length: len(rc)})
+		pool = append(pool, rc...)
+	}
+
+	m := len(qw)
+
+	// score and discretized score of each query column against
+	// each pooled column
+	scores := make([][]float64, m)
+	bins := make([][]int, m)
+	hists := make([][]float64, m)
+
+	for i, qc := range qw {
+		scores[i] = make([]float64, len(pool))
+		lo := math.Inf(1)
+		hi := math.Inf(-1)
+
+		for j, tc := range pool {
+			s := scorer(qc, tc)
+			scores[i][j] = s
+			lo = min(lo, s)
+			hi = max(hi, s)
+		}
+
+		width := (hi - lo) / compareScoreBins
+
+		bins[i] = make([]int, len(pool))
+		hists[i] = make([]float64, compareScoreBins)
+
+		for j, s := range scores[i] {
+			b := 0
+
+			if width > 0 {
+				b = min(int((s-lo)/width), compareScoreBins-1)
+			}
+
+			bins[i][j] = b
+			hists[i][b] += 1 / float64(len(pool))
+		}
+	}
+
+	nulls := newOverlapNulls(hists)
+
+	matches := make(map[*Motif]*MotifMatch, len(targets))
+	alignments := make(map[*Motif]int, len(targets))
+
+	for _, target := range orientations {
+		n := target.length
+		minOverlap := min(opts.MinOverlap, m, n)
+
+		for offset := -(m - 1); offset < n; offset++ {
+			// query columns that overlap the target
+			a := max(0, -offset)
+			b := min(m, n-offset)
+
+			if b-a < minOverlap {
+				continue
+			}
+
+			alignments[target.motif]++
+
+			score := 0.0
+			binned := 0
+
+			for i := a; i < b; i++ {
+				j := target.start + i + offset
+				score += scores[i][j]
+				binned += bins[i][j]
+			}
+
+			p := nulls.pvalue(a, b, binned)
+
+			best, found := matches[target.motif]
+
+			if !found || p < best.PValue || (p == best.PValue && score > best.Score) {
+				matches[target.motif] = &MotifMatch{Motif: target.motif,
+					Strand:  target.strand,
+					Offset:  offset,
+					Overlap: b - a,
+					Score:   score,
+					PValue:  p}
+			}
+		}
+	}
+
+	ret := make([]*MotifMatch, 0, len(matches))
+
+	for _, target := range targets {
+		match, found := matches[target]
+
+		if !found {
+			continue
+		}
+
+		// correct for picking the best of several alignments
+		match.PValue = -math.Expm1(float64(alignments[target]) * math.Log1p(-min(match.PValue, 1)))
+		match.EValue = match.PValue * float64(len(targets))
+
+		ret = append(ret, match)
+	}
+
+	slices.SortStableFunc(ret, func(a, b *MotifMatch) int {
+		return cmp.Or(cmp.Compare(a.PValue, b.PValue), cmp.Compare(b.Score, a.Score))
+	})
+
+	qvalues := BenjaminiHochberg(matchPValues(ret))
+
+	filtered := make([]*MotifMatch, 0, min(len(ret), opts.MaxMatches))
+
+	for i, match := range ret {
+		match.QValue = qvalues[i]
+
+		if match.EValue <= opts.MaxEValue && len(filtered) < opts.MaxMatches {
+			filtered = append(filtered, match)
+		}
+	}
+
+	return filtered, nil
+}
+
+func matchPValues(matches []*MotifMatch) []float64 {
+	ret := make([]float64, len(matches))
+
+	for i, match := range matches {
+		ret[i] = match.PValue
+	}
+
+	return ret
+}
+
+// BenjaminiHochberg converts p-values to q-values controlling the
+// false discovery rate. The q-values are in the same order as the
+// p-values.
+func BenjaminiHochberg(pvalues []float64) []float64 {
+	n := len(pvalues)
+	order := make([]int, n)
+
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(pvalues[a], pvalues[b])
+	})
+
+	qvalues := make([]float64, n)
+	prev := 1.0
+
+	// work down from the largest p-value so q-values are monotone
+	for rank := n; rank >= 1; rank-- {
+		i := order[rank-1]
+		q := min(prev, pvalues[i]*float64(n)/float64(rank))
+		qvalues[i] = q
+		prev = q
+	}
+
+	return qvalues
+}
+
+// null distributions of the summed discrete scores of every
+// contiguous range of query columns
+type overlapNulls struct {
+	// tails[a][b-a-1][s] is the probability that columns a to b
+	// (exclusive) score at least s
+	tails [][][]float64
+}
+
+func newOverlapNulls(hists [][]float64) *overlapNulls {
+	m := len(hists)
+	tails := make([][][]float64, m)
+
+	for a := range m {
+		tails[a] = make([][]float64, 0, m-a)
+		dist := []float64{1}
+
+		for b := a; b < m; b++ {
+			dist = convolve(dist, hists[b])
+			tails[a] = append(tails[a], tailProbabilities(dist))
+		}
+	}
+
+	return &overlapNulls{tails: tails}
+}
+
+func (nulls *overlapNulls) pvalue(a, b, score int) float64 {
+	tail := nulls.tails[a][b-a-1]
+
+	if score <= 0 {
+		return 1
+	}
+
+	if score >= len(tail) {
+		return 0
+	}
+
+	return tail[score]
+}
+
+func convolve(x, y []float64) []float64 {
+	ret := make([]float64, len(x)+len(y)-1)
+
+	for i, a := range x {
+		if a == 0 {
+			continue
+		}
+
+		for j, b := range y {
+			ret[i+j] += a * b
+		}
+	}
+
+	return ret
+}
+
+func tailProbabilities(dist []float64) []float64 {
+	tail := make([]float64, len(dist))
+	sum := 0.0
+
+	for s := len(dist) - 1; s >= 0; s-- {
+		sum += dist[s]
+		tail[s] = min(sum, 1)
+	}
+
+	return tail
+}
+
+// CompareMotif ranks motifs in the selected datasets by similarity
+// to the query. If nothing is selected, every dataset is searched.
+func (mdb *MotifDB) CompareMotif(query *Motif,
+	selection *MotifSelection,
+	opts *CompareOptions) ([]*MotifMatch, error) {
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		datasets, err := mdb.Datasets()
+
+		if err != nil {
+			return nil, err
+		}
+
+		all := MotifSelection{Datasets: make([]string, 0, len(datasets))}
+
+		for _, dataset := range datasets {
+			all.Datasets = append(all.Datasets, dataset.PublicId)
+		}
+
+		selection = &all
+	}
+
+	targets, err := mdb.SelectMotifs(selection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return CompareMotifs(query, targets, opts)
+}
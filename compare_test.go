@@ -0,0 +1,107 @@
+package motifs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// random motifs for the query to be compared against
+func randomMotifs(n int, w int) []*Motif {
+	r := rand.New(rand.NewPCG(1, 2))
+	ret := make([]*Motif, 0, n)
+
+	for i := range n {
+		motif := Motif{MotifId: fmt.Sprintf("R%d", i), Weights: make([][]float64, w)}
+
+		for p := range w {
+			motif.Weights[p] = []float64{r.Float64(), r.Float64(), r.Float64(), r.Float64()}
+		}
+
+		ret = append(ret, &motif)
+	}
+
+	return ret
+}
+
+func TestCompareMotifs(t *testing.T) {
+	target := testMotif()
+
+	// padded copy of GATA so the best offset is not 0
+	padded := Motif{MotifId: "PADDED", Weights: [][]float64{
+		{0.25, 0.25, 0.25, 0.25},
+		{0.25, 0.25, 0.25, 0.25},
+	}}
+
+	padded.Weights = append(padded.Weights, target.Weights...)
+
+	for _, metric := range []CompareMetric{MetricPearson, MetricEuclidean, MetricSandelinWasserman} {
+		opts := NewCompareOptions()
+		opts.Metric = metric
+		opts.MaxEValue = math.Inf(1)
+
+		targets := append(randomMotifs(200, 8), &padded)
+
+		matches, err := CompareMotifs(target, targets, opts)
+
+		if err != nil {
+			t.Fatalf("%s: %s", metric, err)
+		}
+
+		best := matches[0]
+
+		if best.Motif.MotifId != "PADDED" || best.Strand != StrandPlus || best.Offset != 2 || best.Overlap != 4 {
+			t.Fatalf("%s: best match %s %s offset %d overlap %d", metric,
+				best.Motif.MotifId, best.Strand, best.Offset, best.Overlap)
+		}
+
+		if best.EValue > 0.1 {
+			t.Fatalf("%s: exact match has E-value %f", metric, best.EValue)
+		}
+
+		// the reverse complement should match on the other strand
+		rc := Motif{Weights: cloneMatrix(target.Weights)}
+		RevCompWeights(rc.Weights)
+
+		matches, err = CompareMotifs(&rc, targets, opts)
+
+		if err != nil {
+			t.Fatalf("%s: %s", metric, err)
+		}
+
+		best = matches[0]
+
+		if best.Motif.MotifId != "PADDED" || best.Strand != StrandMinus || best.Offset != 0 {
+			t.Fatalf("%s: reverse best match %s %s offset %d", metric,
+				best.Motif.MotifId, best.Strand, best.Offset)
+		}
+	}
+
+	_, err := CompareMotifs(target, []*Motif{target}, &CompareOptions{Metric: "kl"})
+
+	if err == nil {
+		t.Fatalf("expected unknown metric error")
+	}
+
+	wide := randomMotifs(1, MaxCompareQueryWidth+1)[0]
+
+	_, err = CompareMotifs(wide, []*Motif{target}, nil)
+
+	if !errors.Is(err, ErrQueryTooWide) {
+		t.Fatalf("expected query too wide error, got %v", err)
+	}
+}
+
+func TestBenjaminiHochberg(t *testing.T) {
+	q := BenjaminiHochberg([]float64{0.04, 0.01, 0.03, 0.5})
+	// 0.03 is raised to the q-value of the larger 0.04
+	expected := []float64{0.04 * 4 / 3, 0.04, 0.04 * 4 / 3, 0.5}
+
+	for i := range q {
+		if math.Abs(q[i]-expected[i]) > 1e-12 {
+			t.Fatalf("q-value %d is %f not %f", i, q[i], expected[i])
+		}
+	}
+}
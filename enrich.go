@@ -0,0 +1,345 @@
+package motifs
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+type (
+	// Statistical test of whether more target than background
+	// sequences have a motif
+	EnrichTest string
+
+	EnrichOptions struct {
+		// how sequences are scanned. A sequence has a motif if it
+		// has at least one hit at or below the p-value threshold
+		ScanOptions
+
+		// defaults to Fisher's exact test
+		Test EnrichTest `json:"test"`
+		// only report motifs with a q-value at or below this, all
+		// motifs if 0
+		MaxQValue float64 `json:"maxQValue"`
+	}
+
+	// How often a motif occurs in the target sequences compared to
+	// the background
+	MotifEnrichment struct {
+		Id      string `json:"id"`
+		MotifId string `json:"motifId"`
+		Name    string `json:"name"`
+		// sequences in each set with at least one hit
+		TargetHits     int `json:"targetHits"`
+		BackgroundHits int `json:"backgroundHits"`
+		// sequences in each set
+		Targets            int     `json:"targets"`
+		Backgrounds        int     `json:"backgrounds"`
+		TargetFraction     float64 `json:"targetFraction"`
+		BackgroundFraction float64 `json:"backgroundFraction"`
+		OddsRatio          float64 `json:"oddsRatio"`
+		PValue             float64 `json:"pvalue"`
+		QValue             float64 `json:"qvalue"`
+	}
+)
+
+const (
+	// one-sided Fisher's exact test of the 2x2 table of sequences
+	// with and without hits, as AME uses
+	EnrichTestFisher EnrichTest = "fisher"
+	// binomial test of target hits given the background fraction,
+	// as HOMER uses for known motifs
+	EnrichTestBinomial EnrichTest = "binomial"
+)
+
+var (
+	ErrEnrichTest   = errors.New("enrichment test must be fisher or binomial")
+	ErrNoBackground = errors.New("no background sequences")
+
+	// returned by scan callbacks to stop at the first hit
+	errStopScan = errors.New("stop scan")
+)
+
+func NewEnrichOptions() *EnrichOptions {
+	return &EnrichOptions{ScanOptions: *NewScanOptions(),
+		Test: EnrichTestFisher}
+}
+
+// fill in defaults and check options are sensible
+func (opts *EnrichOptions) validate() error {
+	if opts.Test == "" {
+		opts.Test = EnrichTestFisher
+	}
+
+	if opts.Test != EnrichTestFisher && opts.Test != EnrichTestBinomial {
+		return fmt.Errorf("%w: %s", ErrEnrichTest, opts.Test)
+	}
+
+	return opts.ScanOptions.validate()
+}
+
+// Enrich tests each motif for enrichment in the target sequences
+// relative to the background sequences, counting the sequences in each
+// set with at least one hit. Results are sorted by p-value and have
+// Benjamini-Hochberg q-values over all the motifs tested.
+func Enrich(targets []*Sequence,
+	background []*Sequence,
+	motifs []*Motif,
+	opts *EnrichOptions) ([]*MotifEnrichment, error) {
+
+	if opts == nil {
+		opts = NewEnrichOptions()
+	}
+
+	err := opts.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(targets) == 0 {
+		return nil, ErrNoSequences
+	}
+
+	if len(background) == 0 {
+		return nil, ErrNoBackground
+	}
+
+	scanner, err := NewScanner(motifs, &opts.ScanOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	targetWindows := scanner.sequenceWindows(targets)
+	backgroundWindows := scanner.sequenceWindows(background)
+
+	results := make([]*MotifEnrichment, len(motifs))
+
+	// motifs are independent so are tested in parallel
+	parallel(len(motifs), func(i int) {
+		results[i] = scanner.enrichMotif(i, targetWindows, backgroundWindows, opts.Test)
+	})
+
+	pvalues := make([]float64, len(results))
+
+	for i, result := range results {
+		pvalues[i] = result.PValue
+	}
+
+	for i, q := range BenjaminiHochberg(pvalues) {
+		results[i].QValue = q
+	}
+
+	if opts.MaxQValue > 0 {
+		results = slices.DeleteFunc(results, func(result *MotifEnrichment) bool {
+			return result.QValue > opts.MaxQValue
+		})
+	}
+
+	slices.SortStableFunc(results, func(a, b *MotifEnrichment) int {
+		return cmp.Compare(a.PValue, b.PValue)
+	})
+
+	return results, nil
+}
+
+// parallel calls fn for 0 to n - 1 using every cpu
+func parallel(n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range min(runtime.NumCPU(), n) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := range n {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// number of windows with at least one hit of a motif
+func (scanner *Scanner) countSequences(i int, windows []*scanWindow) int {
+	n := 0
+
+	stop := func(hit *ScanHit) error {
+		return errStopScan
+	}
+
+	for _, window := range windows {
+		if scanner.scanMotif(i, window, stop) == errStopScan {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (scanner *Scanner) enrichMotif(i int,
+	targets []*scanWindow,
+	background []*scanWindow,
+	test EnrichTest) *MotifEnrichment {
+
+	motif := scanner.motifs[i]
+
+	a := scanner.countSequences(i, targets)
+	c := scanner.countSequences(i, background)
+	n1 := len(targets)
+	n2 := len(background)
+
+	result := MotifEnrichment{Id: motif.PublicId,
+		MotifId:            motif.MotifId,
+		Name:               motif.Name,
+		TargetHits:         a,
+		BackgroundHits:     c,
+		Targets:            n1,
+		Backgrounds:        n2,
+		TargetFraction:     float64(a) / float64(n1),
+		BackgroundFraction: float64(c) / float64(n2),
+		OddsRatio:          OddsRatio(a, n1-a, c, n2-c)}
+
+	switch test {
+	case EnrichTestBinomial:
+		// the background fraction is estimated with a pseudocount
+		// so that motifs absent from the background can be tested
+		result.PValue = BinomialUpperTail(a, n1, (float64(c)+0.5)/(float64(n2)+1))
+	default:
+		result.PValue = FisherUpperTail(a, n1-a, c, n2-c)
+	}
+
+	return &result
+}
+
+// OddsRatio of the 2x2 table [[a, b], [c, d]]. 0.5 is added to every
+// cell if any is zero so the ratio is always finite.
+func OddsRatio(a, b, c, d int) float64 {
+	fa, fb, fc, fd := float64(a), float64(b), float64(c), float64(d)
+
+	if a == 0 || b == 0 || c == 0 || d == 0 {
+		fa += 0.5
+		fb += 0.5
+		fc += 0.5
+		fd += 0.5
+	}
+
+	return (fa * fd) / (fb * fc)
+}
+
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+
+	return a - b - c
+}
+
+// sum of probabilities given as logs, avoiding underflow
+func sumLogs(logs []float64) float64 {
+	if len(logs) == 0 {
+		return 0
+	}
+
+	m := slices.Max(logs)
+
+	if math.IsInf(m, -1) {
+		return 0
+	}
+
+	sum := 0.0
+
+	for _, l := range logs {
+		sum += math.Exp(l - m)
+	}
+
+	return math.Min(math.Exp(m)*sum, 1)
+}
+
+// FisherUpperTail is the one-sided p-value of Fisher's exact test that
+// the first row of the 2x2 table [[a, b], [c, d]] has a greater
+// proportion in its first column than the second row, i.e. the
+// probability of seeing a or more in the top left cell given the
+// margins.
+func FisherUpperTail(a, b, c, d int) float64 {
+	row1 := a + b
+	col1 := a + c
+	n := a + b + c + d
+
+	logs := make([]float64, 0, min(row1, col1)-a+1)
+	denom := logChoose(n, col1)
+
+	for x := a; x <= min(row1, col1); x++ {
+		logs = append(logs, logChoose(row1, x)+logChoose(n-row1, col1-x)-denom)
+	}
+
+	return sumLogs(logs)
+}
+
+// BinomialUpperTail is the probability of k or more successes in n
+// trials with success probability p
+func BinomialUpperTail(k, n int, p float64) float64 {
+	if k <= 0 {
+		return 1
+	}
+
+	if p <= 0 {
+		return 0
+	}
+
+	if p >= 1 {
+		return 1
+	}
+
+	lp := math.Log(p)
+	lq := math.Log1p(-p)
+
+	logs := make([]float64, 0, n-k+1)
+
+	for x := k; x <= n; x++ {
+		logs = append(logs, logChoose(n, x)+float64(x)*lp+float64(n-x)*lq)
+	}
+
+	return sumLogs(logs)
+}
+
+// Enrich tests motifs chosen from the database for enrichment in the
+// target sequences relative to the background
+func (mdb *MotifDB) Enrich(targets []*Sequence,
+	background []*Sequence,
+	selection *MotifSelection,
+	opts *EnrichOptions) ([]*MotifEnrichment, error) {
+
+	motifs, err := mdb.SelectMotifs(selection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil {
+		scanOpts, err := mdb.withDatasetBackgrounds(motifs, &opts.ScanOptions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filled := *opts
+		filled.ScanOptions = *scanOpts
+		opts = &filled
+	}
+
+	return Enrich(targets, background, motifs, opts)
+}
@@ -0,0 +1,104 @@
+package motifs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFisherUpperTail(t *testing.T) {
+	// (C(4,3)C(4,1) + C(4,4)C(4,0)) / C(8,4)
+	p := FisherUpperTail(3, 1, 1, 3)
+
+	if math.Abs(p-17.0/70) > 1e-9 {
+		t.Fatalf("expected %g, found %g", 17.0/70, p)
+	}
+
+	if p := FisherUpperTail(0, 4, 4, 0); math.Abs(p-1) > 1e-9 {
+		t.Fatalf("expected 1, found %g", p)
+	}
+}
+
+func TestBinomialUpperTail(t *testing.T) {
+	if p := BinomialUpperTail(2, 3, 0.5); math.Abs(p-0.5) > 1e-9 {
+		t.Fatalf("expected 0.5, found %g", p)
+	}
+
+	// far in the tail without underflowing to 0
+	if p := BinomialUpperTail(250, 1000, 0.1); p <= 0 || p > 1e-30 {
+		t.Fatalf("unexpected tail %g", p)
+	}
+}
+
+func TestOddsRatio(t *testing.T) {
+	if r := OddsRatio(3, 1, 1, 3); r != 9 {
+		t.Fatalf("expected 9, found %g", r)
+	}
+
+	if r := OddsRatio(4, 0, 0, 4); math.IsInf(r, 0) || r != 81 {
+		t.Fatalf("expected 81, found %g", r)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	targets := make([]*Sequence, 0, 20)
+	background := make([]*Sequence, 0, 20)
+
+	for i := range 20 {
+		targets = append(targets, &Sequence{Name: fmt.Sprintf("t%d", i), Seq: "CCCCCGATACCCCC"})
+		background = append(background, &Sequence{Name: fmt.Sprintf("b%d", i), Seq: "CCCCCCCCCCCCCC"})
+	}
+
+	// one background sequence has the motif
+	background[0].Seq = "CCCCCGATACCCCC"
+
+	opts := NewEnrichOptions()
+	opts.PValue = 0.01
+
+	results, err := Enrich(targets, background, []*Motif{testMotif()}, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, found %d", len(results))
+	}
+
+	result := results[0]
+
+	if result.TargetHits != 20 || result.BackgroundHits != 1 || result.TargetFraction != 1 || result.BackgroundFraction != 0.05 {
+		t.Fatalf("unexpected counts %+v", result)
+	}
+
+	if result.PValue > 1e-8 || result.QValue != result.PValue {
+		t.Fatalf("unexpected p-value %g, q-value %g", result.PValue, result.QValue)
+	}
+
+	opts.Test = EnrichTestBinomial
+
+	results, err = Enrich(targets, background, []*Motif{testMotif()}, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].PValue > 1e-8 {
+		t.Fatalf("unexpected binomial p-value %g", results[0].PValue)
+	}
+
+	opts.Test = "t-test"
+
+	_, err = Enrich(targets, background, []*Motif{testMotif()}, opts)
+
+	if !errors.Is(err, ErrEnrichTest) {
+		t.Fatalf("expected test error, found %v", err)
+	}
+
+	_, err = Enrich(targets, nil, []*Motif{testMotif()}, nil)
+
+	if !errors.Is(err, ErrNoBackground) {
+		t.Fatalf("expected background error, found %v", err)
+	}
+}
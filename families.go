@@ -0,0 +1,195 @@
+package motifs
+
+import (
+	"database/sql"
+
+	"github.com/antonybholmes/go-sys/db"
+)
+
+type (
+	// A level in the TFClass style hierarchy of transcription factor
+	// classes and families, e.g. the C2H2 zinc finger class
+	Family struct {
+		db.Entity
+		// see FamilyLevels
+		Level string `json:"level"`
+		// public id of the enclosing class or family, empty at the
+		// top level
+		Parent     string `json:"parent,omitempty"`
+		MotifCount int    `json:"motifCount,omitempty"`
+	}
+)
+
+const (
+	FamilyLevelSuperclass = "superclass"
+	FamilyLevelClass      = "class"
+	FamilyLevelFamily     = "family"
+	FamilyLevelSubfamily  = "subfamily"
+
+	// motifs are linked to every level of their hierarchy so that
+	// counts and filters for a class include its families
+	FamiliesSql = `SELECT
+		f.public_id,
+		f.name,
+		f.level,
+		COALESCE(p.public_id, ''),
+		COUNT(mf.motif_id)
+		FROM families f
+		LEFT JOIN families p ON f.parent_id = p.id
+		LEFT JOIN motif_families mf ON f.id = mf.family_id
+		GROUP BY f.id
+		ORDER BY
+			f.depth,
+			f.name`
+
+	PageFamiliesSql = `SELECT
+		tpm.id,
+		f.public_id,
+		f.name,
+		f.level,
+		COALESCE(p.public_id, '')
+		FROM temp_page_motifs tpm
+		JOIN motif_families mf ON tpm.id = mf.motif_id
+		JOIN families f ON mf.family_id = f.id
+		LEFT JOIN families p ON f.parent_id = p.id
+		ORDER BY
+			tpm.id,
+			f.depth,
+			f.name`
+
+	MotifFamiliesSql = `SELECT
+		f.public_id,
+		f.name,
+		f.level,
+		COALESCE(p.public_id, '')
+		FROM motifs m
+		JOIN motif_families mf ON m.id = mf.motif_id
+		JOIN families f ON mf.family_id = f.id
+		LEFT JOIN families p ON f.parent_id = p.id
+		WHERE m.public_id = :id
+		ORDER BY
+			f.depth,
+			f.name`
+
+	TempFamiliesTableSql = `CREATE TEMP TABLE IF NOT EXISTS temp_families (id INTEGER PRIMARY KEY);`
+
+	ClearTempFamiliesSql = `DELETE FROM temp_families;`
+
+	InsertTempFamiliesSql = `INSERT INTO temp_families (id)
+		SELECT id FROM families
+		WHERE public_id = :family OR LOWER(name) = LOWER(:family)
+		ON CONFLICT DO NOTHING;`
+
+	FamilyFilterSql = `(:all_families OR m.id IN (
+		SELECT mf.motif_id FROM motif_families mf
+		JOIN temp_families tf ON mf.family_id = tf.id))`
+)
+
+var (
+	// from most to least general
+	FamilyLevels = []string{FamilyLevelSuperclass,
+		FamilyLevelClass,
+		FamilyLevelFamily,
+		FamilyLevelSubfamily}
+)
+
+// Families lists the transcription factor classes and families with
+// the number of motifs in each, most general first
+func (mdb *MotifDB) Families() ([]*Family, error) {
+	families := make([]*Family, 0, 100)
+
+	schema, err := readSchema(mdb.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// not loaded into this database
+	if !schema.families {
+		return families, nil
+	}
+
+	rows, err := mdb.db.Query(FamiliesSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var family Family
+
+		err := rows.Scan(&family.PublicId,
+			&family.Name,
+			&family.Level,
+			&family.Parent,
+			&family.MotifCount)
+
+		if err != nil {
+			return nil, err
+		}
+
+		families = append(families, &family)
+	}
+
+	return families, rows.Err()
+}
+
+// addTempFamilies fills temp_families with the families to filter by
+// and returns true if there are none. Families that do not exist, or
+// any if the database has none, match no motifs.
+func addTempFamilies(tx *sql.Tx, families []string, schema *schema) (bool, error) {
+	_, err := tx.Exec(TempFamiliesTableSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ClearTempFamiliesSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(families) == 0 {
+		return true, nil
+	}
+
+	if !schema.families {
+		return false, nil
+	}
+
+	stmt, err := tx.Prepare(InsertTempFamiliesSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	for _, family := range families {
+		_, err := stmt.Exec(sql.Named("family", family))
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// scan the public id, name, level and parent of a family
+func scanFamily(rows *sql.Rows, extra ...any) (*Family, error) {
+	var family Family
+
+	dest := append(extra, &family.PublicId, &family.Name, &family.Level, &family.Parent)
+
+	err := rows.Scan(dest...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &family, nil
+}
@@ -0,0 +1,59 @@
+package motifs
+
+import (
+	"database/sql"
+)
+
+type (
+	// Restricts searches to motifs with certain annotations. Fields
+	// that are empty do not filter and a nil filter returns all
+	// motifs.
+	MotifFilter struct {
+		// species names or taxonomy ids, see ParseSpecies
+		Species []string `json:"species"`
+		// family public ids or names at any level, e.g. a class
+		// matches all motifs in its families
+		Families []string `json:"families"`
+		// HOCOMOCO quality grades, e.g. A and B. Motifs from other
+		// collections have no grade so are excluded when filtering
+		Grades []string `json:"grades"`
+	}
+)
+
+const (
+	// added to the where clause of queries over motifs m
+	MotifFilterSql = `(` + SpeciesFilterSql + ` AND
+		` + FamilyFilterSql + ` AND
+		` + GradeFilterSql + `)`
+)
+
+// addTempFilter fills the temp tables MotifFilterSql uses and returns
+// the named args it needs. Queries must be adapted to the schema with
+// schema.filterSql.
+func addTempFilter(tx *sql.Tx, filter *MotifFilter, schema *schema) ([]any, error) {
+	if filter == nil {
+		filter = &MotifFilter{}
+	}
+
+	allSpecies, err := addTempSpecies(tx, filter.Species)
+
+	if err != nil {
+		return nil, err
+	}
+
+	allFamilies, err := addTempFamilies(tx, filter.Families, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	allGrades, err := addTempGrades(tx, filter.Grades)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{sql.Named("all_species", allSpecies),
+		sql.Named("all_families", allFamilies),
+		sql.Named("all_grades", allGrades)}, nil
+}
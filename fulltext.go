@@ -0,0 +1,336 @@
+package motifs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/antonybholmes/go-sys/db"
+)
+
+type (
+	// Where a full text search matched a motif. Matching terms are
+	// wrapped in HighlightStart and HighlightEnd and only fields
+	// that matched are set.
+	FullTextMatch struct {
+		// BM25 relevance, higher is better
+		Score   float64 `json:"score"`
+		MotifId string  `json:"motifId,omitempty"`
+		Name    string  `json:"name,omitempty"`
+		Genes   string  `json:"genes,omitempty"`
+		Dataset string  `json:"dataset,omitempty"`
+	}
+)
+
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+
+	HasFullTextSql = `SELECT COUNT(*) FROM sqlite_master WHERE name = 'motifs_fts'`
+
+	FullTextCountSql = `SELECT
+		COUNT(*)
+		FROM motifs_fts
+		JOIN motifs m ON motifs_fts.rowid = m.id
+		JOIN datasets d ON m.dataset_id = d.id
+		JOIN temp_datasets td ON d.public_id = td.id
+		WHERE motifs_fts MATCH :q AND
+			` + MotifFilterSql
+
+	// ids and names are weighted above genes, which are weighted
+	// above dataset names
+	FullTextSearchSql = `SELECT
+		m.id,
+		d.public_id,
+		d.name,
+		m.public_id,
+		m.motif_id,
+		m.motif_name,
+		-bm25(motifs_fts, 10.0, 10.0, 5.0, 1.0) AS score,
+		highlight(motifs_fts, 0, '` + HighlightStart + `', '` + HighlightEnd + `'),
+		highlight(motifs_fts, 1, '` + HighlightStart + `', '` + HighlightEnd + `'),
+		highlight(motifs_fts, 2, '` + HighlightStart + `', '` + HighlightEnd + `'),
+		highlight(motifs_fts, 3, '` + HighlightStart + `', '` + HighlightEnd + `')
+		FROM motifs_fts
+		JOIN motifs m ON motifs_fts.rowid = m.id
+		JOIN datasets d ON m.dataset_id = d.id
+		JOIN temp_datasets td ON d.public_id = td.id
+		WHERE motifs_fts MATCH :q AND
+			` + MotifFilterSql + `
+		ORDER BY
+			score DESC,
+			d.public_id,
+			m.motif_id
+		LIMIT :limit
+		OFFSET :offset`
+)
+
+var (
+	ErrNoFullTextIndex = errors.New("database has no full text index")
+	ErrFullTextQuery   = errors.New("full text query has no terms")
+)
+
+// FullTextQuery converts a user search into an FTS5 query. Words
+// must all match, words ending in * match as prefixes, quoted text
+// matches as a phrase and commas separate alternatives, e.g.
+//
+//	sox2 "pou5f1 sox2", klf*
+//
+// Every term is quoted so user input cannot inject FTS5 syntax.
+func FullTextQuery(q string) (string, error) {
+	alternatives := make([]string, 0, 5)
+
+	for _, alt := range splitOutsideQuotes(q) {
+		terms := fullTextTerms(alt)
+
+		if len(terms) > 0 {
+			alternatives = append(alternatives, "("+strings.Join(terms, " ")+")")
+		}
+	}
+
+	if len(alternatives) == 0 {
+		return "", ErrFullTextQuery
+	}
+
+	return strings.Join(alternatives, " OR "), nil
+}
+
+// split on commas that are not inside quotes
+func splitOutsideQuotes(q string) []string {
+	ret := make([]string, 0, 5)
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(q); i++ {
+		switch q[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				ret = append(ret, q[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(ret, q[start:])
+}
+
+func fullTextTerms(q string) []string {
+	terms := make([]string, 0, 5)
+	i := 0
+
+	for i < len(q) {
+		switch {
+		case q[i] == ' ' || q[i] == '\t':
+			i++
+		case q[i] == '"':
+			// phrase, which may be unterminated
+			end := strings.IndexByte(q[i+1:], '"')
+
+			var phrase string
+
+			if end == -1 {
+				phrase = q[i+1:]
+				i = len(q)
+			} else {
+				phrase = q[i+1 : i+1+end]
+				i += end + 2
+			}
+
+			prefix := i < len(q) && q[i] == '*'
+
+			if prefix {
+				i++
+			}
+
+			terms = appendTerm(terms, phrase, prefix)
+		default:
+			end := strings.IndexAny(q[i:], " \t\"")
+
+			if end == -1 {
+				end = len(q) - i
+			}
+
+			word := q[i : i+end]
+			i += end
+
+			prefix := strings.HasSuffix(word, "*")
+
+			terms = appendTerm(terms, strings.TrimRight(word, "*"), prefix)
+		}
+	}
+
+	return terms
+}
+
+func appendTerm(terms []string, term string, prefix bool) []string {
+	term = strings.TrimSpace(term)
+
+	if term == "" {
+		return terms
+	}
+
+	term = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+
+	if prefix {
+		term += "*"
+	}
+
+	return append(terms, term)
+}
+
+// only keep highlights that actually mark a match
+func highlighted(s string) string {
+	if strings.Contains(s, HighlightStart) {
+		return s
+	}
+
+	return ""
+}
+
+// FullTextSearch ranks motifs in the given datasets that pass the
+// filter and whose id, name, genes or dataset match the query, using
+// the motifs_fts index made by the builder. See FullTextQuery for the query syntax.
+func (mdb *MotifDB) FullTextSearch(q string,
+	datasets []string,
+	filter *MotifFilter,
+	paging *Paging,
+	revComp bool) (*MotifSearchResult, error) {
+
+	// clamp page number
+	paging.Page = max(paging.Page, 1)
+
+	// clamp page size
+	paging.PageSize = min(max(paging.PageSize, MinPageSize), MaxRecords)
+
+	match, err := FullTextQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := MotifSearchResult{Total: 0,
+		Paging: paging,
+		Motifs: make([]*Motif, 0, 20)}
+
+	tx, err := mdb.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var n int
+
+	err = tx.QueryRow(HasFullTextSql).Scan(&n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, ErrNoFullTextIndex
+	}
+
+	err = addTempDatasets(tx, datasets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := readSchema(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filterArgs, err := addTempFilter(tx, filter, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(schema.filterSql(FullTextCountSql),
+		append(filterArgs, sql.Named("q", match))...).Scan(&result.Total)
+
+	if err != nil {
+		// the index exists but this build of sqlite cannot read it
+		if strings.Contains(err.Error(), "no such module") {
+			return nil, fmt.Errorf("%w: %s", ErrNoFullTextIndex, err)
+		}
+
+		return nil, err
+	}
+
+	paging.Pages = (result.Total + paging.PageSize - 1) / paging.PageSize
+
+	if result.Total == 0 {
+		// suggest for each word since the syntax is not part of a name
+		words := strings.FieldsFunc(q, func(r rune) bool {
+			return strings.ContainsRune(" \t,\"*", r)
+		})
+
+		result.Suggestions, err = mdb.Suggest(words, MaxSuggestions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &result, nil
+	}
+
+	rows, err := tx.Query(schema.filterSql(FullTextSearchSql), append(filterArgs,
+		sql.Named("q", match),
+		sql.Named("offset", paging.PageSize*(paging.Page-1)),
+		sql.Named("limit", paging.PageSize))...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	page := make([]*pageMotif, 0, paging.PageSize)
+
+	for rows.Next() {
+		motif := Motif{Dataset: &db.Entity{}, Match: &FullTextMatch{}}
+
+		var id int
+
+		err := rows.Scan(&id,
+			&motif.Dataset.PublicId,
+			&motif.Dataset.Name,
+			&motif.PublicId,
+			&motif.MotifId,
+			&motif.Name,
+			&motif.Match.Score,
+			&motif.Match.MotifId,
+			&motif.Match.Name,
+			&motif.Match.Genes,
+			&motif.Match.Dataset)
+
+		if err != nil {
+			return nil, err
+		}
+
+		motif.Match.MotifId = highlighted(motif.Match.MotifId)
+		motif.Match.Name = highlighted(motif.Match.Name)
+		motif.Match.Genes = highlighted(motif.Match.Genes)
+		motif.Match.Dataset = highlighted(motif.Match.Dataset)
+
+		page = append(page, &pageMotif{id: id, motif: &motif})
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	return finishPage(tx, page, revComp, &result)
+}
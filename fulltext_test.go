@@ -0,0 +1,39 @@
+package motifs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFullTextQuery(t *testing.T) {
+	tests := []struct {
+		q        string
+		expected string
+	}{
+		{"sox2", `("sox2")`},
+		{"sox* pou5f1", `("sox"* "pou5f1")`},
+		{`"pou5f1 sox2", klf4`, `("pou5f1 sox2") OR ("klf4")`},
+		{`"pou5f1 so"*`, `("pou5f1 so"*)`},
+		// unbalanced quotes and operators are just text
+		{`sox2 OR "klf`, `("sox2" "OR" "klf")`},
+		{`a,"b,c"`, `("a") OR ("b,c")`},
+	}
+
+	for _, test := range tests {
+		q, err := FullTextQuery(test.q)
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.q, err)
+		}
+
+		if q != test.expected {
+			t.Fatalf("%s: got %s not %s", test.q, q, test.expected)
+		}
+	}
+
+	_, err := FullTextQuery(` , * ""`)
+
+	if !errors.Is(err, ErrFullTextQuery) {
+		t.Fatalf("expected empty query error not %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package genome
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type (
+	// BedError reports the line in the file where parsing failed
+	BedError struct {
+		Line int
+		Err  error
+	}
+)
+
+var (
+	ErrBedLine = errors.New("BED lines need chrom, chromStart and chromEnd columns")
+	ErrResize  = errors.New("width and extension must not be negative")
+)
+
+func (e *BedError) Error() string {
+	return fmt.Sprintf("bed: line %d: %s", e.Line, e.Err)
+}
+
+func (e *BedError) Unwrap() error {
+	return e.Err
+}
+
+// ReadBedFile reads regions from a BED file, which may be gzipped if
+// it ends in .gz
+func ReadBedFile(file string) ([]*Region, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var r io.Reader = f
+
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		defer gz.Close()
+
+		r = gz
+	}
+
+	regions, err := ReadBed(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return regions, nil
+}
+
+// ReadBed reads regions from BED3 or longer lines, e.g. BED6 or
+// narrowPeak. The fourth column, if there is one and it is not ".",
+// names a region. Lines with ten columns are read as narrowPeak, whose
+// last column is the summit offset from the start, or -1 if there is
+// no summit. Track, browser and comment lines are skipped.
+func ReadBed(r io.Reader) ([]*Region, error) {
+	scanner := bufio.NewScanner(r)
+
+	regions := make([]*Region, 0, 1000)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" ||
+			strings.HasPrefix(text, "#") ||
+			strings.HasPrefix(text, "track") ||
+			strings.HasPrefix(text, "browser") {
+			continue
+		}
+
+		tokens := strings.Split(text, "\t")
+
+		if len(tokens) < 3 {
+			// some tools write space separated BED
+			tokens = strings.Fields(text)
+		}
+
+		if len(tokens) < 3 {
+			return nil, &BedError{Line: line, Err: ErrBedLine}
+		}
+
+		start, err := strconv.Atoi(tokens[1])
+
+		if err != nil || start < 0 {
+			return nil, &BedError{Line: line, Err: fmt.Errorf("%w: bad start %s", ErrBedLine, tokens[1])}
+		}
+
+		end, err := strconv.Atoi(tokens[2])
+
+		if err != nil || end < start {
+			return nil, &BedError{Line: line, Err: fmt.Errorf("%w: bad end %s", ErrBedLine, tokens[2])}
+		}
+
+		region := Region{Chr: tokens[0], Start: start, End: end}
+
+		if len(tokens) > 3 && tokens[3] != "." {
+			region.Name = tokens[3]
+		}
+
+		if len(tokens) == 10 {
+			offset, err := strconv.Atoi(tokens[9])
+
+			if err != nil {
+				return nil, &BedError{Line: line, Err: fmt.Errorf("%w: bad summit %s", ErrBedLine, tokens[9])}
+			}
+
+			if offset >= 0 && start+offset < end {
+				region.Summit = start + offset
+			}
+		}
+
+		regions = append(regions, &region)
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return regions, nil
+}
+
+// Center returns the position a region is resized around, its summit
+// if it has one, otherwise its midpoint
+func (r *Region) Center() int {
+	if r.Summit > 0 {
+		return r.Summit
+	}
+
+	return (r.Start + r.End) / 2
+}
+
+// ResizeRegions adjusts regions before scanning. If width is positive
+// each region is replaced by one of that width centred on its summit,
+// or midpoint, which trims or extends it. Regions are then extended by
+// extend bases on both sides. Regions are clipped to their
+// chromosomes, whose lengths are given by chrs, and dropped if they
+// are on unknown chromosomes or nothing is left of them.
+func ResizeRegions(regions []*Region, width int, extend int, chrs []*Chromosome) ([]*Region, error) {
+	if width < 0 || extend < 0 {
+		return nil, ErrResize
+	}
+
+	lengths := ChrLengths(chrs)
+
+	ret := make([]*Region, 0, len(regions))
+
+	for _, region := range regions {
+		length, found := lengths[region.Chr]
+
+		if !found {
+			continue
+		}
+
+		resized := *region
+
+		// a whole chromosome, as given by ParseRegion
+		if resized.End == 0 {
+			resized.End = length
+		}
+
+		if width > 0 {
+			resized.Start = resized.Center() - width/2
+			resized.End = resized.Start + width
+		}
+
+		resized.Start = max(resized.Start-extend, 0)
+		resized.End = min(resized.End+extend, length)
+
+		if resized.Start >= resized.End {
+			continue
+		}
+
+		// regions without names are named after where they were
+		// before being resized so hits can be traced back to them
+		if resized.Name == "" {
+			resized.Name = region.Location()
+		}
+
+		ret = append(ret, &resized)
+	}
+
+	return ret, nil
+}
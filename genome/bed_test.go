@@ -0,0 +1,95 @@
+package genome
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testBed = `track name=peaks
+# comment
+chr1	100	200	peak1	500	.	8.2	10.5	9.1	30
+chr1	1000	1100
+chr2	0	50	.	0	+
+`
+
+func TestReadBed(t *testing.T) {
+	regions, err := ReadBed(strings.NewReader(testBed))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(regions) != 3 {
+		t.Fatalf("expected 3 regions, found %d", len(regions))
+	}
+
+	if regions[0].Name != "peak1" || regions[0].Summit != 130 || regions[0].Center() != 130 {
+		t.Fatalf("unexpected narrowPeak region %+v", regions[0])
+	}
+
+	if regions[1].Summit != 0 || regions[1].Center() != 1050 {
+		t.Fatalf("unexpected BED3 region %+v", regions[1])
+	}
+
+	if regions[2].Name != "" || regions[2].Chr != "chr2" {
+		t.Fatalf("unexpected BED6 region %+v", regions[2])
+	}
+
+	_, err = ReadBed(strings.NewReader("chr1\t100\n"))
+
+	var bedErr *BedError
+
+	if !errors.As(err, &bedErr) || bedErr.Line != 1 || !errors.Is(err, ErrBedLine) {
+		t.Fatalf("expected line error, found %v", err)
+	}
+}
+
+func TestResizeRegions(t *testing.T) {
+	regions, err := ReadBed(strings.NewReader(testBed))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chrs := []*Chromosome{{Name: "chr1", Length: 1080}, {Name: "chr2", Length: 500}}
+
+	resized, err := ResizeRegions(regions, 40, 0, chrs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// around the summit of the narrowPeak and the midpoints of the
+	// others
+	expected := []Region{{Chr: "chr1", Start: 110, End: 150},
+		{Chr: "chr1", Start: 1030, End: 1070},
+		{Chr: "chr2", Start: 5, End: 45}}
+
+	for i, region := range resized {
+		if region.Chr != expected[i].Chr || region.Start != expected[i].Start || region.End != expected[i].End {
+			t.Fatalf("expected %+v, found %+v", expected[i], region)
+		}
+	}
+
+	if resized[0].Name != "peak1" || resized[1].Name != "chr1:1001-1100" {
+		t.Fatalf("unexpected names %s %s", resized[0].Name, resized[1].Name)
+	}
+
+	// extended regions are clipped to chromosomes
+	resized, err = ResizeRegions(regions, 0, 10, chrs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resized[1].Start != 990 || resized[1].End != 1080 || resized[2].Start != 0 || resized[2].End != 60 {
+		t.Fatalf("unexpected extended regions %+v %+v", resized[1], resized[2])
+	}
+
+	_, err = ResizeRegions(regions, -1, 0, chrs)
+
+	if !errors.Is(err, ErrResize) {
+		t.Fatalf("expected resize error, found %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package genome
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type (
+	// FastaReader reads sequence from a FASTA file using its samtools
+	// faidx index to seek to the bases needed
+	FastaReader struct {
+		f     *os.File
+		chrs  []*Chromosome
+		index map[string]*faiEntry
+	}
+
+	// a line of a .fai index
+	faiEntry struct {
+		length int
+		// byte offset of the first base
+		offset int64
+		// bases on each full line
+		lineBases int
+		// bytes on each full line including the newline
+		lineWidth int
+	}
+)
+
+// OpenFasta opens a FASTA file and its index, file.fai
+func OpenFasta(file string) (*FastaReader, error) {
+	chrs, index, err := readFai(file + ".fai")
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FastaReader{f: f, chrs: chrs, index: index}, nil
+}
+
+func readFai(file string) ([]*Chromosome, map[string]*faiEntry, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer f.Close()
+
+	chrs := make([]*Chromosome, 0, 100)
+	index := make(map[string]*faiEntry, 100)
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			continue
+		}
+
+		tokens := strings.Split(text, "\t")
+
+		if len(tokens) < 5 {
+			return nil, nil, fmt.Errorf("%w: %s line %d", ErrGenomeIndex, file, line)
+		}
+
+		values := make([]int64, 4)
+
+		for i, token := range tokens[1:5] {
+			values[i], err = strconv.ParseInt(token, 10, 64)
+
+			if err != nil || values[i] < 0 {
+				return nil, nil, fmt.Errorf("%w: %s line %d", ErrGenomeIndex, file, line)
+			}
+		}
+
+		entry := faiEntry{length: int(values[0]),
+			offset:    values[1],
+			lineBases: int(values[2]),
+			lineWidth: int(values[3])}
+
+		if entry.lineBases == 0 || entry.lineWidth < entry.lineBases {
+			return nil, nil, fmt.Errorf("%w: %s line %d", ErrGenomeIndex, file, line)
+		}
+
+		index[tokens[0]] = &entry
+		chrs = append(chrs, &Chromosome{Name: tokens[0], Length: entry.length})
+	}
+
+	return chrs, index, scanner.Err()
+}
+
+func (fr *FastaReader) Chromosomes() []*Chromosome {
+	return fr.chrs
+}
+
+// byte offset in the file of a 0-based position
+func (entry *faiEntry) pos(p int) int64 {
+	return entry.offset +
+		int64(p/entry.lineBases)*int64(entry.lineWidth) +
+		int64(p%entry.lineBases)
+}
+
+func (fr *FastaReader) Seq(chr string, start int, end int) (string, error) {
+	entry, found := fr.index[chr]
+
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrUnknownChr, chr)
+	}
+
+	err := checkRange(chr, start, end, entry.length)
+
+	if err != nil {
+		return "", err
+	}
+
+	if start == end {
+		return "", nil
+	}
+
+	from := entry.pos(start)
+	// read up to and including the last base
+	buf := make([]byte, entry.pos(end-1)-from+1)
+
+	_, err = fr.f.ReadAt(buf, from)
+
+	if err != nil {
+		return "", err
+	}
+
+	// drop the line endings between bases
+	seq := make([]byte, 0, end-start)
+
+	for _, b := range buf {
+		if b != '\n' && b != '\r' {
+			seq = append(seq, b)
+		}
+	}
+
+	if len(seq) != end-start {
+		return "", fmt.Errorf("%w: %s does not match the FASTA file", ErrGenomeIndex, chr)
+	}
+
+	return string(seq), nil
+}
+
+func (fr *FastaReader) Close() error {
+	return fr.f.Close()
+}
@@ -0,0 +1,121 @@
+package genome
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	Chromosome struct {
+		Name   string `json:"name"`
+		Length int    `json:"length"`
+	}
+
+	// A genomic interval. Start is 0-based and End exclusive, as in
+	// BED files
+	Region struct {
+		Chr   string `json:"chr"`
+		Start int    `json:"start"`
+		End   int    `json:"end"`
+		// optional, e.g. a peak id
+		Name string `json:"name,omitempty"`
+		// 0-based position of the peak summit, e.g. from a narrowPeak
+		// file, or 0 if unknown
+		Summit int `json:"summit,omitempty"`
+	}
+
+	// Reader reads sequence from an assembly by coordinates without
+	// loading whole chromosomes. Lowercase bases are soft-masked.
+	Reader interface {
+		// chromosomes in file order
+		Chromosomes() []*Chromosome
+		// Seq reads the bases of chr from the 0-based start up to,
+		// but not including, end
+		Seq(chr string, start int, end int) (string, error)
+		Close() error
+	}
+)
+
+var (
+	ErrUnknownChr  = errors.New("unknown chromosome")
+	ErrRange       = errors.New("coordinates are outside the chromosome")
+	ErrRegion      = errors.New("region must be chr:start-end")
+	ErrGenomeIndex = errors.New("malformed genome index")
+)
+
+// Open opens a UCSC .2bit file or an indexed FASTA file, which needs
+// a samtools faidx .fai index alongside it
+func Open(file string) (Reader, error) {
+	if strings.ToLower(filepath.Ext(file)) == ".2bit" {
+		return OpenTwoBit(file)
+	}
+
+	return OpenFasta(file)
+}
+
+// ParseRegion parses a 1-based inclusive location such as
+// chr1:1000-2000, as shown by genome browsers, or a whole chromosome
+// such as chr1, in which case End is 0. Commas in numbers are ignored.
+func ParseRegion(location string) (*Region, error) {
+	location = strings.TrimSpace(location)
+
+	chr, span, found := strings.Cut(location, ":")
+
+	if chr == "" {
+		return nil, fmt.Errorf("%w: %s", ErrRegion, location)
+	}
+
+	if !found {
+		return &Region{Chr: chr, Name: chr}, nil
+	}
+
+	startText, endText, found := strings.Cut(strings.ReplaceAll(span, ",", ""), "-")
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrRegion, location)
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(startText))
+
+	if err != nil || start < 1 {
+		return nil, fmt.Errorf("%w: %s", ErrRegion, location)
+	}
+
+	end, err := strconv.Atoi(strings.TrimSpace(endText))
+
+	if err != nil || end < start {
+		return nil, fmt.Errorf("%w: %s", ErrRegion, location)
+	}
+
+	return &Region{Chr: chr, Start: start - 1, End: end, Name: location}, nil
+}
+
+// Location formats a region as chr:start-end using 1-based inclusive
+// coordinates
+func (r *Region) Location() string {
+	return fmt.Sprintf("%s:%d-%d", r.Chr, r.Start+1, r.End)
+}
+
+// ChrLengths returns chromosome lengths keyed by name
+func ChrLengths(chrs []*Chromosome) map[string]int {
+	ret := make(map[string]int, len(chrs))
+
+	for _, chr := range chrs {
+		ret[chr.Name] = chr.Length
+	}
+
+	return ret
+}
+
+// checkRange checks 0-based, end exclusive coordinates are within a
+// chromosome of the given length
+func checkRange(chr string, start int, end int, length int) error {
+	if start < 0 || end > length || start > end {
+		return fmt.Errorf("%w: %s:%d-%d, length %d", ErrRange, chr, start+1, end, length)
+	}
+
+	return nil
+}
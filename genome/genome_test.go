@@ -0,0 +1,232 @@
+package genome
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testChrs = []struct {
+	name string
+	seq  string
+}{
+	{"chr1", "ACGTACGTNNNNacgtGATTACAcc"},
+	{"chr2", "TTTTGGGGCCCCAAAAT"},
+}
+
+// write the test chromosomes as FASTA with short lines and an index
+func writeTestFasta(t *testing.T) string {
+	file := filepath.Join(t.TempDir(), "test.fa")
+
+	var fa, fai strings.Builder
+	lineBases := 7
+
+	for _, chr := range testChrs {
+		fmt.Fprintf(&fa, ">%s description\n", chr.name)
+		fmt.Fprintf(&fai, "%s\t%d\t%d\t%d\t%d\n", chr.name, len(chr.seq), fa.Len(), lineBases, lineBases+1)
+
+		for i := 0; i < len(chr.seq); i += lineBases {
+			fmt.Fprintln(&fa, chr.seq[i:min(i+lineBases, len(chr.seq))])
+		}
+	}
+
+	err := os.WriteFile(file, []byte(fa.String()), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(file+".fai", []byte(fai.String()), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// runs of bases matching fn as starts and sizes
+func testBlocks(seq string, fn func(b byte) bool) ([]uint32, []uint32) {
+	starts := []uint32{}
+	sizes := []uint32{}
+
+	for i := 0; i < len(seq); i++ {
+		if !fn(seq[i]) {
+			continue
+		}
+
+		j := i
+
+		for j < len(seq) && fn(seq[j]) {
+			j++
+		}
+
+		starts = append(starts, uint32(i))
+		sizes = append(sizes, uint32(j-i))
+		i = j
+	}
+
+	return starts, sizes
+}
+
+// write the test chromosomes as a little endian version 0 2bit file
+func writeTestTwoBit(t *testing.T) string {
+	file := filepath.Join(t.TempDir(), "test.2bit")
+
+	le := binary.LittleEndian
+
+	index := []byte{}
+	records := []byte{}
+
+	headerSize := 16
+
+	for _, chr := range testChrs {
+		headerSize += 1 + len(chr.name) + 4
+	}
+
+	for _, chr := range testChrs {
+		index = append(index, byte(len(chr.name)))
+		index = append(index, chr.name...)
+		index = le.AppendUint32(index, uint32(headerSize+len(records)))
+
+		nStarts, nSizes := testBlocks(chr.seq, func(b byte) bool { return b == 'N' })
+		maskStarts, maskSizes := testBlocks(chr.seq, func(b byte) bool { return b >= 'a' })
+
+		records = le.AppendUint32(records, uint32(len(chr.seq)))
+		records = le.AppendUint32(records, uint32(len(nStarts)))
+
+		for _, v := range append(nStarts, nSizes...) {
+			records = le.AppendUint32(records, v)
+		}
+
+		records = le.AppendUint32(records, uint32(len(maskStarts)))
+
+		for _, v := range append(maskStarts, maskSizes...) {
+			records = le.AppendUint32(records, v)
+		}
+
+		records = le.AppendUint32(records, 0)
+
+		packed := make([]byte, (len(chr.seq)+3)/4)
+
+		for i, b := range strings.ToUpper(chr.seq) {
+			// N is stored as T, code 0
+			code := max(strings.IndexRune(twoBitBases, b), 0)
+			packed[i/4] |= byte(code) << (6 - 2*(i%4))
+		}
+
+		records = append(records, packed...)
+	}
+
+	data := le.AppendUint32(nil, TwoBitSignature)
+	data = le.AppendUint32(data, 0)
+	data = le.AppendUint32(data, uint32(len(testChrs)))
+	data = le.AppendUint32(data, 0)
+	data = append(data, index...)
+	data = append(data, records...)
+
+	err := os.WriteFile(file, data, 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func testReader(t *testing.T, reader Reader) {
+	defer reader.Close()
+
+	chrs := reader.Chromosomes()
+
+	if len(chrs) != len(testChrs) {
+		t.Fatalf("expected %d chromosomes, found %d", len(testChrs), len(chrs))
+	}
+
+	for i, chr := range testChrs {
+		if chrs[i].Name != chr.name || chrs[i].Length != len(chr.seq) {
+			t.Fatalf("unexpected chromosome %+v", chrs[i])
+		}
+
+		// every sub sequence, crossing lines and packed bytes
+		for start := 0; start <= len(chr.seq); start++ {
+			for end := start; end <= len(chr.seq); end++ {
+				seq, err := reader.Seq(chr.name, start, end)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if seq != chr.seq[start:end] {
+					t.Fatalf("%s:%d-%d: expected %s, found %s", chr.name, start, end, chr.seq[start:end], seq)
+				}
+			}
+		}
+	}
+
+	_, err := reader.Seq("chr1", 20, 100)
+
+	if !errors.Is(err, ErrRange) {
+		t.Fatalf("expected range error, found %v", err)
+	}
+
+	_, err = reader.Seq("chrX", 0, 1)
+
+	if !errors.Is(err, ErrUnknownChr) {
+		t.Fatalf("expected unknown chromosome error, found %v", err)
+	}
+}
+
+func TestFasta(t *testing.T) {
+	reader, err := Open(writeTestFasta(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReader(t, reader)
+}
+
+func TestTwoBit(t *testing.T) {
+	reader, err := Open(writeTestTwoBit(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReader(t, reader)
+}
+
+func TestParseRegion(t *testing.T) {
+	region, err := ParseRegion("chr1:1,001-2,000")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if region.Chr != "chr1" || region.Start != 1000 || region.End != 2000 {
+		t.Fatalf("unexpected region %+v", region)
+	}
+
+	if region.Location() != "chr1:1001-2000" {
+		t.Fatalf("unexpected location %s", region.Location())
+	}
+
+	region, err = ParseRegion("chrX")
+
+	if err != nil || region.Chr != "chrX" || region.End != 0 {
+		t.Fatalf("unexpected region %+v %v", region, err)
+	}
+
+	for _, location := range []string{"", "chr1:100", "chr1:200-100", "chr1:0-10"} {
+		_, err = ParseRegion(location)
+
+		if !errors.Is(err, ErrRegion) {
+			t.Fatalf("%s: expected region error, found %v", location, err)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package genome
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+type (
+	// TwoBitReader reads sequence from a UCSC .2bit file. Only the
+	// blocks of N and soft-masked bases of a chromosome are kept in
+	// memory, and only once it has been read from.
+	TwoBitReader struct {
+		f     *os.File
+		order binary.ByteOrder
+		chrs  []*Chromosome
+		// file offset of each sequence record
+		offsets map[string]int64
+		lock    sync.Mutex
+		records map[string]*twoBitRecord
+	}
+
+	twoBitRecord struct {
+		length     int
+		nBlocks    *twoBitBlocks
+		maskBlocks *twoBitBlocks
+		// file offset of the packed bases
+		dnaOffset int64
+	}
+
+	// sorted, non overlapping runs of bases
+	twoBitBlocks struct {
+		starts []int
+		sizes  []int
+	}
+)
+
+const (
+	TwoBitSignature = 0x1A412743
+
+	// bases in the order of their 2 bit codes
+	twoBitBases = "TCAG"
+)
+
+var (
+	ErrTwoBit = errors.New("not a 2bit file")
+)
+
+// OpenTwoBit opens a .2bit file and reads its index
+func OpenTwoBit(file string) (*TwoBitReader, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tr, err := readTwoBitIndex(f)
+
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return tr, nil
+}
+
+func readTwoBitIndex(f *os.File) (*TwoBitReader, error) {
+	header := make([]byte, 16)
+
+	_, err := f.ReadAt(header, 0)
+
+	if err != nil {
+		return nil, ErrTwoBit
+	}
+
+	// files may be written in either byte order, which the signature
+	// tells us
+	var order binary.ByteOrder
+
+	switch {
+	case binary.LittleEndian.Uint32(header) == TwoBitSignature:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == TwoBitSignature:
+		order = binary.BigEndian
+	default:
+		return nil, ErrTwoBit
+	}
+
+	version := order.Uint32(header[4:])
+
+	if version > 1 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrTwoBit, version)
+	}
+
+	n := int(order.Uint32(header[8:]))
+
+	tr := TwoBitReader{f: f,
+		order:   order,
+		chrs:    make([]*Chromosome, 0, n),
+		offsets: make(map[string]int64, n),
+		records: make(map[string]*twoBitRecord, 10)}
+
+	r := io.NewSectionReader(f, 16, 1<<62)
+
+	// version 1 uses 64 bit offsets for files over 4Gb
+	offsetSize := 4
+
+	if version == 1 {
+		offsetSize = 8
+	}
+
+	buf := make([]byte, 256)
+
+	for range n {
+		_, err := io.ReadFull(r, buf[:1])
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: truncated index", ErrTwoBit)
+		}
+
+		nameSize := int(buf[0])
+
+		_, err = io.ReadFull(r, buf[:nameSize+offsetSize])
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: truncated index", ErrTwoBit)
+		}
+
+		name := string(buf[:nameSize])
+
+		var offset int64
+
+		if offsetSize == 8 {
+			offset = int64(order.Uint64(buf[nameSize:]))
+		} else {
+			offset = int64(order.Uint32(buf[nameSize:]))
+		}
+
+		// each record starts with its length
+		_, err = f.ReadAt(buf[:4], offset)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad offset for %s", ErrTwoBit, name)
+		}
+
+		tr.offsets[name] = offset
+		tr.chrs = append(tr.chrs, &Chromosome{Name: name, Length: int(order.Uint32(buf))})
+	}
+
+	return &tr, nil
+}
+
+func (tr *TwoBitReader) Chromosomes() []*Chromosome {
+	return tr.chrs
+}
+
+// record of a chromosome, reading its N and mask blocks the first
+// time it is needed
+func (tr *TwoBitReader) record(chr string) (*twoBitRecord, error) {
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+
+	if rec, found := tr.records[chr]; found {
+		return rec, nil
+	}
+
+	offset, found := tr.offsets[chr]
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChr, chr)
+	}
+
+	r := io.NewSectionReader(tr.f, offset, 1<<62)
+
+	values, err := tr.readUint32s(r, 2)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec := twoBitRecord{length: int(values[0])}
+
+	rec.nBlocks, err = tr.readBlocks(r, int(values[1]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	values, err = tr.readUint32s(r, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec.maskBlocks, err = tr.readBlocks(r, int(values[0]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the reserved word
+	pos, err := r.Seek(4, io.SeekCurrent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec.dnaOffset = offset + pos
+
+	tr.records[chr] = &rec
+
+	return &rec, nil
+}
+
+func (tr *TwoBitReader) readUint32s(r io.Reader, n int) ([]int, error) {
+	buf := make([]byte, 4*n)
+
+	_, err := io.ReadFull(r, buf)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: truncated record", ErrTwoBit)
+	}
+
+	ret := make([]int, n)
+
+	for i := range n {
+		ret[i] = int(tr.order.Uint32(buf[4*i:]))
+	}
+
+	return ret, nil
+}
+
+// read a block count's worth of starts then sizes
+func (tr *TwoBitReader) readBlocks(r io.Reader, n int) (*twoBitBlocks, error) {
+	starts, err := tr.readUint32s(r, n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, err := tr.readUint32s(r, n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &twoBitBlocks{starts: starts, sizes: sizes}, nil
+}
+
+// apply calls fn with the part of each block overlapping [start, end)
+func (blocks *twoBitBlocks) apply(start int, end int, fn func(from int, to int)) {
+	// first block ending after start
+	i := sort.Search(len(blocks.starts), func(i int) bool {
+		return blocks.starts[i]+blocks.sizes[i] > start
+	})
+
+	for ; i < len(blocks.starts) && blocks.starts[i] < end; i++ {
+		fn(max(blocks.starts[i], start), min(blocks.starts[i]+blocks.sizes[i], end))
+	}
+}
+
+func (tr *TwoBitReader) Seq(chr string, start int, end int) (string, error) {
+	rec, err := tr.record(chr)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = checkRange(chr, start, end, rec.length)
+
+	if err != nil {
+		return "", err
+	}
+
+	if start == end {
+		return "", nil
+	}
+
+	// four bases are packed into each byte, first base in the high
+	// bits
+	first := start / 4
+	packed := make([]byte, (end-1)/4-first+1)
+
+	_, err = tr.f.ReadAt(packed, rec.dnaOffset+int64(first))
+
+	if err != nil {
+		return "", fmt.Errorf("%w: truncated sequence %s", ErrTwoBit, chr)
+	}
+
+	seq := make([]byte, end-start)
+
+	for p := start; p < end; p++ {
+		shift := 6 - 2*(p%4)
+		seq[p-start] = twoBitBases[(packed[p/4-first]>>shift)&3]
+	}
+
+	rec.nBlocks.apply(start, end, func(from int, to int) {
+		for p := from; p < to; p++ {
+			seq[p-start] = 'N'
+		}
+	})
+
+	rec.maskBlocks.apply(start, end, func(from int, to int) {
+		for p := from; p < to; p++ {
+			seq[p-start] += 'a' - 'A'
+		}
+	})
+
+	return string(seq), nil
+}
+
+func (tr *TwoBitReader) Close() error {
+	return tr.f.Close()
+}
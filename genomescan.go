@@ -0,0 +1,119 @@
+package motifs
+
+import (
+	"fmt"
+
+	"github.com/antonybholmes/go-motifs/genome"
+)
+
+const (
+	// bases read from a genome at a time when scanning
+	DefaultGenomeChunkSize = 1000000
+)
+
+// ScanGenome scans regions of an assembly, reading them in chunks so
+// that whole chromosomes can be scanned without loading them. A region
+// with an End of 0, as returned by genome.ParseRegion for a bare
+// chromosome name, is scanned to the end of the chromosome, and if no
+// regions are given every chromosome is scanned. Hits are passed to fn
+// in the order they are found rather than sorted, with 1-based
+// chromosome coordinates and the region name as their sequence.
+func (scanner *Scanner) ScanGenome(reader genome.Reader,
+	regions []*genome.Region,
+	fn func(hit *ScanHit) error) error {
+
+	if len(regions) == 0 {
+		regions = make([]*genome.Region, 0, len(reader.Chromosomes()))
+
+		for _, chr := range reader.Chromosomes() {
+			regions = append(regions, &genome.Region{Chr: chr.Name, End: chr.Length, Name: chr.Name})
+		}
+	}
+
+	lengths := genome.ChrLengths(reader.Chromosomes())
+
+	for _, region := range regions {
+		err := scanner.scanRegion(reader, region, lengths, fn)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (scanner *Scanner) scanRegion(reader genome.Reader,
+	region *genome.Region,
+	lengths map[string]int,
+	fn func(hit *ScanHit) error) error {
+
+	end := region.End
+
+	if end == 0 {
+		length, found := lengths[region.Chr]
+
+		if !found {
+			return fmt.Errorf("%w: %s", genome.ErrUnknownChr, region.Chr)
+		}
+
+		end = length
+	}
+
+	name := region.Name
+
+	if name == "" {
+		name = region.Location()
+	}
+
+	// chunks overlap by a motif width less one base so hits spanning
+	// the boundary are found once, in the chunk they start in
+	overlap := max(scanner.maxWidth-1, 0)
+
+	for start := region.Start; start < end; start += DefaultGenomeChunkSize {
+		limit := min(start+DefaultGenomeChunkSize, end)
+
+		seq, err := reader.Seq(region.Chr, start, min(limit+overlap, end))
+
+		if err != nil {
+			return err
+		}
+
+		err = scanner.ScanChr(name, region.Chr, seq, start, limit, fn)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScanGenome scans regions of an assembly with motifs chosen from the
+// database, passing each hit to fn
+func (mdb *MotifDB) ScanGenome(reader genome.Reader,
+	regions []*genome.Region,
+	selection *MotifSelection,
+	opts *ScanOptions,
+	fn func(hit *ScanHit) error) error {
+
+	motifs, err := mdb.SelectMotifs(selection)
+
+	if err != nil {
+		return err
+	}
+
+	opts, err = mdb.withDatasetBackgrounds(motifs, opts)
+
+	if err != nil {
+		return err
+	}
+
+	scanner, err := NewScanner(motifs, opts)
+
+	if err != nil {
+		return err
+	}
+
+	return scanner.ScanGenome(reader, regions, fn)
+}
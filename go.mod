@@ -40,7 +40,7 @@ require (
 
 require (
 	github.com/antonybholmes/go-web v0.0.0-20251215211100-5555b69aa3c0
-	github.com/google/uuid v1.6.0 // indirect
+	github.com/google/uuid v1.6.0
 	github.com/matoous/go-nanoid/v2 v2.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.15 // indirect
 	github.com/mattn/go-isatty v0.0.22 // indirect
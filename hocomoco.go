@@ -0,0 +1,224 @@
+package motifs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// What a HOCOMOCO v12 or v13 motif id, e.g. ADNP.H12CORE.0.P.B,
+	// says about the motif
+	HocomocoInfo struct {
+		Gene string `json:"gene"`
+		// e.g. H12CORE
+		Collection string `json:"collection"`
+		// 0 for the main motif of a factor, then 1, 2...
+		Subtype int `json:"subtype"`
+		// experiments supporting the motif, see HocomocoSources
+		Sources []string `json:"sources"`
+		// A is the most reliable and D the least
+		Grade string `json:"grade"`
+	}
+)
+
+const (
+	// grades ordered from best to worst
+	HocomocoGrades = "ABCD"
+
+	PageHocomocoSql = `SELECT
+		tpm.id,
+		hm.gene,
+		hm.collection,
+		hm.subtype,
+		hm.sources,
+		hm.grade
+		FROM temp_page_motifs tpm
+		JOIN hocomoco_motifs hm ON tpm.id = hm.motif_id`
+
+	MotifHocomocoSql = `SELECT
+		hm.gene,
+		hm.collection,
+		hm.subtype,
+		hm.sources,
+		hm.grade
+		FROM motifs m
+		JOIN hocomoco_motifs hm ON m.id = hm.motif_id
+		WHERE m.public_id = :id`
+
+	TempGradesTableSql = `CREATE TEMP TABLE IF NOT EXISTS temp_grades (grade TEXT PRIMARY KEY);`
+
+	ClearTempGradesSql = `DELETE FROM temp_grades;`
+
+	InsertTempGradeSql = `INSERT INTO temp_grades (grade) VALUES (:grade) ON CONFLICT DO NOTHING;`
+
+	GradeFilterSql = `(:all_grades OR m.id IN (
+		SELECT hm.motif_id FROM hocomoco_motifs hm
+		JOIN temp_grades tg ON hm.grade = tg.grade))`
+)
+
+var (
+	ErrHocomocoId = errors.New("not a HOCOMOCO motif id")
+	ErrGrade      = errors.New("quality grade must be one of A, B, C or D")
+
+	// the experiment each letter in the sources part of an id stands
+	// for
+	HocomocoSources = map[byte]string{
+		'P': "ChIP-Seq",
+		'S': "HT-SELEX",
+		'M': "Methyl-HT-SELEX",
+		'G': "GHT-SELEX",
+		'I': "SMiLE-Seq",
+		'B': "PBM",
+	}
+)
+
+// ParseHocomocoId splits an id of the form
+// gene.collection.subtype.sources.grade, e.g. ADNP.H12CORE.0.P.B,
+// into its parts
+func ParseHocomocoId(id string) (*HocomocoInfo, error) {
+	parts := strings.Split(id, ".")
+
+	if len(parts) != 5 || parts[0] == "" || !strings.HasPrefix(parts[1], "H") {
+		return nil, fmt.Errorf("%w: %s", ErrHocomocoId, id)
+	}
+
+	subtype, err := strconv.Atoi(parts[2])
+
+	if err != nil || subtype < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrHocomocoId, id)
+	}
+
+	sources, err := hocomocoSources(parts[3])
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHocomocoId, id)
+	}
+
+	grade, err := ParseGrade(parts[4])
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHocomocoId, id)
+	}
+
+	return &HocomocoInfo{Gene: parts[0],
+		Collection: parts[1],
+		Subtype:    subtype,
+		Sources:    sources,
+		Grade:      grade}, nil
+}
+
+// SourceCodes turns the sources back into the letters used in ids,
+// e.g. PSM
+func (info *HocomocoInfo) SourceCodes() string {
+	var codes strings.Builder
+
+	for _, source := range info.Sources {
+		for code, name := range HocomocoSources {
+			if name == source {
+				codes.WriteByte(code)
+			}
+		}
+	}
+
+	return codes.String()
+}
+
+func hocomocoSources(codes string) ([]string, error) {
+	if codes == "" {
+		return nil, ErrHocomocoId
+	}
+
+	ret := make([]string, 0, len(codes))
+
+	for i := range len(codes) {
+		source, found := HocomocoSources[codes[i]]
+
+		if !found {
+			return nil, ErrHocomocoId
+		}
+
+		ret = append(ret, source)
+	}
+
+	return ret, nil
+}
+
+// ParseGrade checks a quality grade, ignoring case
+func ParseGrade(grade string) (string, error) {
+	grade = strings.ToUpper(strings.TrimSpace(grade))
+
+	if len(grade) != 1 || !strings.Contains(HocomocoGrades, grade) {
+		return "", fmt.Errorf("%w: %s", ErrGrade, grade)
+	}
+
+	return grade, nil
+}
+
+// scan gene, collection, subtype, sources and grade
+func scanHocomoco(scanner interface{ Scan(...any) error }, extra ...any) (*HocomocoInfo, error) {
+	var info HocomocoInfo
+	var codes string
+
+	dest := append(extra, &info.Gene, &info.Collection, &info.Subtype, &codes, &info.Grade)
+
+	err := scanner.Scan(dest...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info.Sources, err = hocomocoSources(codes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// addTempGrades fills temp_grades with the grades to filter by and
+// returns true if there are none
+func addTempGrades(tx *sql.Tx, grades []string) (bool, error) {
+	_, err := tx.Exec(TempGradesTableSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ClearTempGradesSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(grades) == 0 {
+		return true, nil
+	}
+
+	stmt, err := tx.Prepare(InsertTempGradeSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	for _, grade := range grades {
+		grade, err := ParseGrade(grade)
+
+		if err != nil {
+			return false, err
+		}
+
+		_, err = stmt.Exec(sql.Named("grade", grade))
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,83 @@
+package motifs
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseHocomocoId(t *testing.T) {
+	info, err := ParseHocomocoId("ADNP.H12CORE.1.PSM.A")
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if info.Gene != "ADNP" || info.Collection != "H12CORE" || info.Subtype != 1 || info.Grade != "A" ||
+		!slices.Equal(info.Sources, []string{"ChIP-Seq", "HT-SELEX", "Methyl-HT-SELEX"}) {
+		t.Fatalf("unexpected info %v", info)
+	}
+
+	if info.SourceCodes() != "PSM" {
+		t.Fatalf("source codes are %s", info.SourceCodes())
+	}
+
+	for _, id := range []string{"ADNP_IRX_SIX_ZHX.p2", "MA0001.1", "ADNP.H12CORE.x.P.A", "ADNP.H12CORE.0.Q.A", "ADNP.H12CORE.0.P.E"} {
+		_, err := ParseHocomocoId(id)
+
+		if !errors.Is(err, ErrHocomocoId) {
+			t.Fatalf("%s: expected id error not %v", id, err)
+		}
+	}
+}
+
+func TestSearchGrades(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	datasets, err := db.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	page := Paging{Page: 1, PageSize: 100}
+
+	res, err := db.Search([]string{"FOX"}, ids, &MotifFilter{Grades: []string{"a"}}, &page, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if res.Total == 0 {
+		t.Fatalf("no grade A motifs")
+	}
+
+	for _, motif := range res.Motifs {
+		if motif.Hocomoco == nil || motif.Hocomoco.Grade != "A" {
+			t.Fatalf("motif %s is not grade A", motif.MotifId)
+		}
+	}
+
+	motif, err := db.Motif(res.Motifs[0].PublicId)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if motif.Hocomoco == nil || motif.Hocomoco.Gene == "" {
+		t.Fatalf("motif %s has no HOCOMOCO info", motif.MotifId)
+	}
+
+	_, err = db.Search([]string{"FOX"}, ids, &MotifFilter{Grades: []string{"E"}}, &page, false)
+
+	if !errors.Is(err, ErrGrade) {
+		t.Fatalf("expected grade error not %v", err)
+	}
+}
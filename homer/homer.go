@@ -0,0 +1,343 @@
+package homer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+type (
+	// A motif in HOMER format along with the header fields HOMER
+	// uses to find it in sequences
+	Motif struct {
+		*motifs.Motif
+		Consensus string `json:"consensus"`
+		// natural log odds score a site must reach to be reported
+		Threshold float64 `json:"threshold"`
+		// natural log of the enrichment p-value, 0 if not given
+		LogPValue float64 `json:"logPValue,omitempty"`
+		// occurrence statistics from motif finding, e.g.
+		// T:17311.0(44.36%),B:2181.5(5.80%),P:1e-10317
+		Stats string `json:"stats,omitempty"`
+	}
+
+	// ParseError reports the line in the file where parsing failed
+	ParseError struct {
+		Line int
+		Err  error
+	}
+)
+
+const (
+	DNAAlphabet = "ACGT"
+
+	// p-value used to pick a threshold for motifs that do not have
+	// one, the same as the FIMO default
+	ThresholdPValue = 1e-4
+)
+
+var (
+	ErrHeader       = errors.New("malformed motif header")
+	ErrMissingMotif = errors.New("matrix row found outside of a > header block")
+	ErrMatrixRow    = errors.New("malformed matrix row")
+	ErrNoMatrix     = errors.New("motif has no matrix")
+)
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("homer: line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func ReadFile(file string) ([]*Motif, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read parses HOMER .motif files. Each motif has a tab separated
+// header of consensus, name, log odds threshold and optionally the
+// log p-value, a placeholder and statistics, followed by a row of
+// a, c, g, t probabilities per position, e.g.
+//
+//	>GATA	GATA/Example	6.2	-150.2	0	T:100.0(10.00%),B:10.0(1.00%),P:1e-65
+//	0.1	0.1	0.7	0.1
+//
+// HOMER motifs have no ids so the name is used as both MotifId and
+// Name. Rows are normalized since they are often rounded.
+func Read(r io.Reader) ([]*Motif, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	ret := make([]*Motif, 0, 100)
+
+	var currentMotif *Motif = nil
+	motifLine := 0
+	line := 0
+
+	// normalize the rows of the previous motif
+	finishMotif := func() error {
+		if currentMotif == nil {
+			return nil
+		}
+
+		if len(currentMotif.Weights) == 0 {
+			return &ParseError{Line: motifLine, Err: fmt.Errorf("%w: %s", ErrNoMatrix, currentMotif.MotifId)}
+		}
+
+		weights, err := motifs.NormalizeWeights(currentMotif.Weights)
+
+		if err != nil {
+			return &ParseError{Line: motifLine, Err: fmt.Errorf("%s: %w", currentMotif.MotifId, err)}
+		}
+
+		currentMotif.Weights = weights
+
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, ">") {
+			err := finishMotif()
+
+			if err != nil {
+				return nil, err
+			}
+
+			currentMotif, err = parseHeader(text[1:])
+
+			if err != nil {
+				return nil, &ParseError{Line: line, Err: err}
+			}
+
+			motifLine = line
+
+			ret = append(ret, currentMotif)
+
+			continue
+		}
+
+		if currentMotif == nil {
+			return nil, &ParseError{Line: line, Err: ErrMissingMotif}
+		}
+
+		tokens := strings.Fields(text)
+
+		if len(tokens) != len(DNAAlphabet) {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("%w: expected %d columns, found %d", ErrMatrixRow, len(DNAAlphabet), len(tokens))}
+		}
+
+		row := make([]float64, len(DNAAlphabet))
+
+		for i, token := range tokens {
+			v, err := strconv.ParseFloat(token, 64)
+
+			if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, &ParseError{Line: line, Err: fmt.Errorf("%w: bad probability %s", ErrMatrixRow, token)}
+			}
+
+			row[i] = v
+		}
+
+		currentMotif.Weights = append(currentMotif.Weights, row)
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = finishMotif()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func parseHeader(header string) (*Motif, error) {
+	tokens := strings.Split(header, "\t")
+
+	if len(tokens) < 3 {
+		return nil, fmt.Errorf("%w: expected consensus, name and threshold", ErrHeader)
+	}
+
+	name := strings.TrimSpace(tokens[1])
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: no name", ErrHeader)
+	}
+
+	motif := Motif{Motif: &motifs.Motif{MotifId: name,
+		Weights: make([][]float64, 0, 20)},
+		Consensus: strings.TrimSpace(tokens[0])}
+
+	motif.Name = name
+
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(tokens[2]), 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad threshold %s", ErrHeader, tokens[2])
+	}
+
+	motif.Threshold = threshold
+
+	if len(tokens) > 3 && strings.TrimSpace(tokens[3]) != "" {
+		motif.LogPValue, err = strconv.ParseFloat(strings.TrimSpace(tokens[3]), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad log p-value %s", ErrHeader, tokens[3])
+		}
+	}
+
+	if len(tokens) > 5 {
+		motif.Stats = strings.TrimSpace(tokens[5])
+	}
+
+	return &motif, nil
+}
+
+// NewMotif converts a motif to HOMER format, working out its
+// consensus and the log odds score with a p-value of ThresholdPValue
+// on a uniform background to use as its threshold. The name is
+// written as name/motif id so the id is kept.
+func NewMotif(motif *motifs.Motif) (*Motif, error) {
+	opts := motifs.NewPWMOptions()
+	opts.LogBase = math.E
+
+	sd, err := motif.ScoreDistribution(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := sd.Threshold(ThresholdPValue)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// short motifs may never be that significant
+	if math.IsInf(threshold, 1) {
+		threshold = sd.PWM().MaxScore
+	}
+
+	named := *motif
+
+	if motif.Name != "" && motif.Name != motif.MotifId {
+		named.MotifId = motif.Name + "/" + motif.MotifId
+	}
+
+	return &Motif{Motif: &named,
+		Consensus: motif.Consensus(),
+		Threshold: math.Round(threshold*1000) / 1000}, nil
+}
+
+// Write writes motifs in HOMER format
+func Write(w io.Writer, ms []*Motif) error {
+	bw := bufio.NewWriter(w)
+
+	for _, motif := range ms {
+		err := writeMotif(bw, motif)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteMotifs writes motifs in HOMER format with thresholds chosen
+// as in NewMotif
+func WriteMotifs(w io.Writer, ms []*motifs.Motif) error {
+	homerMotifs := make([]*Motif, 0, len(ms))
+
+	for _, motif := range ms {
+		homerMotif, err := NewMotif(motif)
+
+		if err != nil {
+			return err
+		}
+
+		homerMotifs = append(homerMotifs, homerMotif)
+	}
+
+	return Write(w, homerMotifs)
+}
+
+func writeMotif(bw *bufio.Writer, motif *Motif) error {
+	if motif.MotifId == "" {
+		return fmt.Errorf("%w: no name", ErrHeader)
+	}
+
+	if len(motif.Weights) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoMatrix, motif.MotifId)
+	}
+
+	consensus := motif.Consensus
+
+	if consensus == "" {
+		consensus = motif.Motif.Consensus()
+	}
+
+	fmt.Fprintf(bw, ">%s\t%s\t%s", consensus, motif.MotifId, formatFloat(motif.Threshold))
+
+	if motif.LogPValue != 0 || motif.Stats != "" {
+		fmt.Fprintf(bw, "\t%s\t0", formatFloat(motif.LogPValue))
+
+		if motif.Stats != "" {
+			fmt.Fprintf(bw, "\t%s", motif.Stats)
+		}
+	}
+
+	bw.WriteString("\n")
+
+	for _, row := range motif.Weights {
+		if len(row) != len(DNAAlphabet) {
+			return fmt.Errorf("%w: %s", ErrMatrixRow, motif.MotifId)
+		}
+
+		for i, v := range row {
+			if i > 0 {
+				bw.WriteString("\t")
+			}
+
+			bw.WriteString(formatFloat(v))
+		}
+
+		bw.WriteString("\n")
+	}
+
+	return nil
+}
+
+// shortest representation that parses back to the same value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
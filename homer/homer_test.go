@@ -0,0 +1,145 @@
+package homer
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+const testHomer = `>GATA	GATA(Zf)/Example/Homer	6.123	-150.2	0	T:100.0(10.00%),B:10.0(1.00%),P:1e-65
+0.1	0.1	0.7	0.1
+0.9	0.0	0.05	0.05
+0.0	0.0	0.0	1.0
+0.8	0.1	0.1	0.0
+>CACGTG	Ebox	4.5
+0.001	0.997	0.001	0.001
+0.997	0.001	0.001	0.001
+`
+
+func TestRead(t *testing.T) {
+	ms, err := Read(strings.NewReader(testHomer))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms) != 2 {
+		t.Fatalf("expected 2 motifs, found %d", len(ms))
+	}
+
+	m := ms[0]
+
+	if m.MotifId != "GATA(Zf)/Example/Homer" || m.Name != m.MotifId || m.Consensus != "GATA" || len(m.Weights) != 4 {
+		t.Fatalf("bad motif %+v", m)
+	}
+
+	if m.Threshold != 6.123 || m.LogPValue != -150.2 || !strings.HasPrefix(m.Stats, "T:100.0") {
+		t.Fatalf("bad header %+v", m)
+	}
+
+	if ms[1].Threshold != 4.5 || ms[1].Stats != "" || len(ms[1].Weights) != 2 {
+		t.Fatalf("bad motif %+v", ms[1])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ms, err := Read(strings.NewReader(testHomer))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err = Write(&buf, ms)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms2, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range ms {
+		m2 := ms2[i]
+
+		if m.MotifId != m2.MotifId || m.Threshold != m2.Threshold || m.LogPValue != m2.LogPValue || m.Stats != m2.Stats {
+			t.Fatalf("motif %d differs: %+v %+v", i, m, m2)
+		}
+
+		for j, row := range m.Weights {
+			for k, v := range row {
+				if math.Abs(m2.Weights[j][k]-v) > 1e-12 {
+					t.Fatalf("motif %d weight %d,%d differs", i, j, k)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteMotifs(t *testing.T) {
+	m := motifs.Motif{MotifId: "MA0001.1",
+		Weights: [][]float64{
+			{0.1, 0.1, 0.7, 0.1},
+			{0.9, 0.0, 0.05, 0.05},
+			{0.0, 0.0, 0.0, 1.0},
+			{0.8, 0.1, 0.1, 0.0},
+			{0.1, 0.1, 0.7, 0.1}}}
+
+	m.Name = "GATA1"
+
+	var buf bytes.Buffer
+
+	err := WriteMotifs(&buf, []*motifs.Motif{&m})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms[0].MotifId != "GATA1/MA0001.1" || ms[0].Consensus != "GATAG" {
+		t.Fatalf("bad motif %+v", ms[0])
+	}
+
+	// the threshold must be reachable but not by every site
+	pwm, err := m.PWM(&motifs.PWMOptions{Background: motifs.UniformBackground,
+		Pseudocount:       motifs.PseudocountFixed,
+		PseudocountWeight: motifs.DefaultPseudocount,
+		LogBase:           math.E})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms[0].Threshold <= 0 || ms[0].Threshold > pwm.MaxScore+1e-3 {
+		t.Fatalf("bad threshold %f, max %f", ms[0].Threshold, pwm.MaxScore)
+	}
+}
+
+func TestMalformedHeader(t *testing.T) {
+	bad := strings.Replace(testHomer, "Ebox\t4.5", "Ebox\tfour", 1)
+
+	_, err := Read(strings.NewReader(bad))
+
+	var pe *ParseError
+
+	if !errors.As(err, &pe) || !errors.Is(err, ErrHeader) {
+		t.Fatalf("expected header error, found %v", err)
+	}
+
+	if pe.Line != 6 {
+		t.Fatalf("expected error on line 6, found %d", pe.Line)
+	}
+}
@@ -0,0 +1,297 @@
+package jaspar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+type (
+	// ParseError reports the line in the file where parsing failed
+	ParseError struct {
+		Line int
+		Err  error
+	}
+)
+
+const (
+	DNAAlphabet = "ACGT"
+)
+
+var (
+	ErrMissingMotif = errors.New("matrix row found outside of a > header block")
+	ErrMotifId      = errors.New("header has no matrix id")
+	ErrMatrixRow    = errors.New("malformed matrix row")
+	ErrRowLength    = errors.New("matrix rows have different lengths")
+	ErrShortMatrix  = errors.New("matrix needs a row for each of A, C, G and T")
+)
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("jaspar: line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func ReadFile(file string) ([]*motifs.Motif, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read parses the JASPAR count format, where each matrix has a
+// header line followed by a row of counts per base, e.g.
+//
+//	>MA0001.1	AGL3
+//	A  [ 0  3 79 ]
+//	C  [94 75  4 ]
+//	G  [ 1  0  3 ]
+//	T  [ 2 19 11 ]
+//
+// Rows may omit the base letter and brackets, as in .pfm files, in
+// which case they are in A, C, G, T order. Counts are kept as Counts,
+// converted to probabilities as Weights, and nsites is set to the
+// largest number of counts at a position.
+func Read(r io.Reader) ([]*motifs.Motif, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	ret := make([]*motifs.Motif, 0, 100)
+
+	var currentMotif *motifs.Motif = nil
+	// counts of each base across the positions of the current motif
+	var rows [][]float64
+	found := 0
+	motifLine := 0
+	line := 0
+
+	// turn the rows of the previous motif into a matrix
+	finishMotif := func() error {
+		if currentMotif == nil {
+			return nil
+		}
+
+		if found < len(DNAAlphabet) {
+			return &ParseError{Line: motifLine, Err: fmt.Errorf("%w: %s", ErrShortMatrix, currentMotif.MotifId)}
+		}
+
+		err := setCounts(currentMotif, rows)
+
+		if err != nil {
+			return &ParseError{Line: motifLine, Err: err}
+		}
+
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, ">") {
+			err := finishMotif()
+
+			if err != nil {
+				return nil, err
+			}
+
+			tokens := strings.Fields(text[1:])
+
+			if len(tokens) == 0 {
+				return nil, &ParseError{Line: line, Err: ErrMotifId}
+			}
+
+			currentMotif = &motifs.Motif{MotifId: tokens[0]}
+
+			// name is optional, in which case we use the id
+			if len(tokens) > 1 {
+				currentMotif.Name = strings.Join(tokens[1:], " ")
+			} else {
+				currentMotif.Name = tokens[0]
+			}
+
+			rows = make([][]float64, len(DNAAlphabet))
+			found = 0
+			motifLine = line
+
+			ret = append(ret, currentMotif)
+
+			continue
+		}
+
+		if currentMotif == nil {
+			return nil, &ParseError{Line: line, Err: ErrMissingMotif}
+		}
+
+		if found == len(DNAAlphabet) {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("%w: more than %d rows", ErrMatrixRow, len(DNAAlphabet))}
+		}
+
+		base, counts, err := parseRow(text)
+
+		if err != nil {
+			return nil, &ParseError{Line: line, Err: err}
+		}
+
+		// unlabelled rows are in alphabet order
+		if base == -1 {
+			base = found
+		}
+
+		if rows[base] != nil {
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("%w: repeated row %c", ErrMatrixRow, DNAAlphabet[base])}
+		}
+
+		rows[base] = counts
+		found++
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = finishMotif()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// parse a row such as A [ 0 3 79 ] returning the index of the base,
+// or -1 if the row has no label, and the counts
+func parseRow(text string) (int, []float64, error) {
+	base := -1
+
+	if idx := strings.IndexByte(DNAAlphabet, strings.ToUpper(text)[0]); idx != -1 {
+		base = idx
+		text = text[1:]
+	}
+
+	text = strings.NewReplacer("[", " ", "]", " ").Replace(text)
+
+	tokens := strings.Fields(text)
+
+	if len(tokens) == 0 {
+		return 0, nil, fmt.Errorf("%w: no counts", ErrMatrixRow)
+	}
+
+	counts := make([]float64, len(tokens))
+
+	for i, token := range tokens {
+		v, err := strconv.ParseFloat(token, 64)
+
+		if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, nil, fmt.Errorf("%w: bad count %s", ErrMatrixRow, token)
+		}
+
+		counts[i] = v
+	}
+
+	return base, counts, nil
+}
+
+// setCounts transposes base rows of counts into positions and
+// derives the weights and nsites from them
+func setCounts(motif *motifs.Motif, rows [][]float64) error {
+	w := len(rows[0])
+
+	for _, row := range rows {
+		if len(row) != w {
+			return fmt.Errorf("%w: %s", ErrRowLength, motif.MotifId)
+		}
+	}
+
+	counts := make([][]float64, w)
+
+	for i := range w {
+		counts[i] = make([]float64, len(DNAAlphabet))
+
+		for b, row := range rows {
+			counts[i][b] = row[i]
+		}
+	}
+
+	return motif.SetCounts(counts)
+}
+
+// Write writes motifs in JASPAR count format. Motifs without raw
+// counts have them estimated from their weights and nsites.
+func Write(w io.Writer, ms []*motifs.Motif) error {
+	bw := bufio.NewWriter(w)
+
+	for _, motif := range ms {
+		err := writeMotif(bw, motif)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeMotif(bw *bufio.Writer, motif *motifs.Motif) error {
+	if motif.MotifId == "" {
+		return ErrMotifId
+	}
+
+	if len(motif.Weights) == 0 {
+		return fmt.Errorf("%w: %s", ErrShortMatrix, motif.MotifId)
+	}
+
+	// tools such as Biopython expect a name after the id
+	name := motif.Name
+
+	if name == "" {
+		name = motif.MotifId
+	}
+
+	fmt.Fprintf(bw, ">%s\t%s\n", motif.MotifId, name)
+
+	counts := motif.CountMatrix()
+
+	for b, letter := range DNAAlphabet {
+		fmt.Fprintf(bw, "%c  [", letter)
+
+		for i, row := range counts {
+			if len(row) != len(DNAAlphabet) {
+				return fmt.Errorf("%w: %s position %d", ErrMatrixRow, motif.MotifId, i+1)
+			}
+
+			fmt.Fprintf(bw, " %5s", formatFloat(row[b]))
+		}
+
+		bw.WriteString(" ]\n")
+	}
+
+	return nil
+}
+
+// shortest representation that parses back to the same value,
+// without exponents since counts can be large
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,243 @@
+package jaspar
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+const testJaspar = `>MA0001.1	AGL3
+A  [     0      3     79 ]
+C  [    94     75      4 ]
+G  [     1      0      3 ]
+T  [     2     19     11 ]
+
+>MA0004.1 Arnt
+A  [ 4 19  0 ]
+C  [16  0 20 ]
+G  [ 0  1  0 ]
+T  [ 0  0  0 ]
+`
+
+const testPfm = `>MA0002.2 RUNX1
+287 234
+123 87
+165 44
+112 322
+`
+
+const testJSON = `{
+  "matrix_id": "MA0001.1",
+  "base_id": "MA0001",
+  "version": 1,
+  "name": "AGL3",
+  "collection": "CORE",
+  "type": "SELEX",
+  "class": ["Other Alpha-Helix"],
+  "family": ["MADS"],
+  "species": [{"tax_id": "3702", "name": "Arabidopsis thaliana"}],
+  "tax_group": "plants",
+  "uniprot_ids": ["P29383"],
+  "pubmed_ids": ["7632923"],
+  "pfm": {"A": [0, 3, 79], "C": [94, 75, 4], "G": [1, 0, 3], "T": [2, 19, 11]}
+}`
+
+func TestRead(t *testing.T) {
+	ms, err := Read(strings.NewReader(testJaspar))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms) != 2 {
+		t.Fatalf("expected 2 motifs, found %d", len(ms))
+	}
+
+	m := ms[0]
+
+	if m.MotifId != "MA0001.1" || m.Name != "AGL3" || len(m.Counts) != 3 || m.NSites != 97 {
+		t.Fatalf("bad motif %+v", m)
+	}
+
+	if m.Counts[0][1] != 94 || m.Counts[2][0] != 79 {
+		t.Fatalf("bad counts %v", m.Counts)
+	}
+
+	if m.Weights[0][1] != 94.0/97 {
+		t.Fatalf("bad weights %v", m.Weights)
+	}
+
+	// unlabelled rows without brackets
+	ms, err = Read(strings.NewReader(testPfm))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms[0].Name != "RUNX1" || ms[0].Counts[1][3] != 322 {
+		t.Fatalf("bad motif %+v", ms[0])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ms, err := Read(strings.NewReader(testJaspar))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err = Write(&buf, ms)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms2, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range ms {
+		m2 := ms2[i]
+
+		if m.MotifId != m2.MotifId || m.Name != m2.Name || m.NSites != m2.NSites {
+			t.Fatalf("motif %d differs: %+v %+v", i, m, m2)
+		}
+
+		for j, row := range m.Counts {
+			for k, v := range row {
+				if m2.Counts[j][k] != v {
+					t.Fatalf("motif %d count %d,%d differs", i, j, k)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteWeights(t *testing.T) {
+	// counts are estimated when a motif only has probabilities
+	m := motifs.Motif{MotifId: "M1",
+		NSites:  10,
+		Weights: [][]float64{{0.5, 0.5, 0, 0}, {0.1, 0.2, 0.3, 0.4}}}
+
+	var buf bytes.Buffer
+
+	err := Write(&buf, []*motifs.Motif{&m})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms[0].Name != "M1" || ms[0].Counts[0][0] != 5 || ms[0].Counts[1][3] != 4 {
+		t.Fatalf("bad motif %+v", ms[0])
+	}
+}
+
+func TestShortMatrix(t *testing.T) {
+	bad := strings.Replace(testJaspar, "T  [     2     19     11 ]\n", "", 1)
+
+	_, err := Read(strings.NewReader(bad))
+
+	var pe *ParseError
+
+	if !errors.As(err, &pe) || !errors.Is(err, ErrShortMatrix) {
+		t.Fatalf("expected short matrix error, found %v", err)
+	}
+
+	if pe.Line != 1 {
+		t.Fatalf("expected error on line 1, found %d", pe.Line)
+	}
+
+	bad = strings.Replace(testJaspar, "11 ]", "11 4 ]", 1)
+
+	_, err = Read(strings.NewReader(bad))
+
+	if !errors.Is(err, ErrRowLength) {
+		t.Fatalf("expected row length error, found %v", err)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	ms, err := ReadJSON(strings.NewReader(testJSON))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := ms[0]
+
+	if m.MotifId != "MA0001.1" || m.Name != "AGL3" || m.Counts[0][1] != 94 || m.NSites != 97 {
+		t.Fatalf("bad motif %+v", m)
+	}
+
+	if m.Species == nil || m.Species.TaxonId != 3702 || m.Species.Name != "Arabidopsis thaliana" {
+		t.Fatalf("bad species %+v", m.Species)
+	}
+
+	if len(m.Families) != 2 || m.Families[1].Level != motifs.FamilyLevelFamily || m.Families[1].Name != "MADS" {
+		t.Fatalf("bad families %+v", m.Families)
+	}
+
+	// lists and pages of results
+	for _, data := range []string{"[" + testJSON + "]", `{"count": 1, "results": [` + testJSON + "]}"} {
+		ms, err := ReadJSON(strings.NewReader(data))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ms) != 1 || ms[0].MotifId != "MA0001.1" {
+			t.Fatalf("bad motifs %v", ms)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	ms, err := ReadJSON(strings.NewReader(testJSON))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err = WriteJSON(&buf, ms)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matrices, err := ReadJSONMatrices(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := matrices[0]
+
+	if m.BaseId != "MA0001" || m.Version != 1 || m.Class[0] != "Other Alpha-Helix" || m.Species[0].TaxId != 3702 {
+		t.Fatalf("bad matrix %+v", m)
+	}
+
+	if m.Pfm["C"][0] != 94 || m.Pfm["T"][1] != 19 {
+		t.Fatalf("bad pfm %v", m.Pfm)
+	}
+
+	if m.Collection != "CORE" || m.Type != "SELEX" || m.TaxGroup != "plants" ||
+		len(m.UniprotIds) != 1 || m.UniprotIds[0] != "P29383" ||
+		len(m.PubmedIds) != 1 || m.PubmedIds[0] != "7632923" {
+		t.Fatalf("bad metadata %+v", m)
+	}
+}
@@ -0,0 +1,342 @@
+package jaspar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-sys/db"
+)
+
+type (
+	// A matrix as served by the JASPAR REST API, e.g.
+	// https://jaspar.elixir.no/api/v1/matrix/MA0001.1/?format=json
+	Matrix struct {
+		MatrixId   string `json:"matrix_id"`
+		BaseId     string `json:"base_id,omitempty"`
+		Version    int    `json:"version,omitempty"`
+		Name       string `json:"name"`
+		Collection string `json:"collection,omitempty"`
+		// experiment the matrix came from, e.g. ChIP-seq or SELEX
+		Type       string     `json:"type,omitempty"`
+		Class      []string   `json:"class,omitempty"`
+		Family     []string   `json:"family,omitempty"`
+		Species    []*Species `json:"species,omitempty"`
+		TaxGroup   string     `json:"tax_group,omitempty"`
+		UniprotIds []string   `json:"uniprot_ids,omitempty"`
+		PubmedIds  []string   `json:"pubmed_ids,omitempty"`
+		URL        string     `json:"url,omitempty"`
+		// counts for each base keyed by A, C, G and T
+		Pfm map[string][]float64 `json:"pfm"`
+	}
+
+	Species struct {
+		TaxId TaxId  `json:"tax_id"`
+		Name  string `json:"name,omitempty"`
+	}
+
+	// NCBI taxonomy id, which older JASPAR releases give as a string
+	TaxId int
+
+	// A motif with the JASPAR metadata the database does not store
+	Motif struct {
+		*motifs.Motif
+		Collection string `json:"collection,omitempty"`
+		// experiment the matrix came from, e.g. ChIP-seq or SELEX
+		DataType   string   `json:"dataType,omitempty"`
+		TaxGroup   string   `json:"taxGroup,omitempty"`
+		UniprotIds []string `json:"uniprotIds,omitempty"`
+		PubmedIds  []string `json:"pubmedIds,omitempty"`
+	}
+
+	// a page of results from a JASPAR API search
+	resultsPage struct {
+		Results []*Matrix `json:"results"`
+	}
+)
+
+var (
+	ErrJSON = errors.New("expected a JASPAR matrix, a list of matrices or a page of results")
+)
+
+func (id *TaxId) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+
+	if s == "" || s == "null" || s == "-" {
+		*id = 0
+		return nil
+	}
+
+	v, err := strconv.Atoi(s)
+
+	if err != nil {
+		return fmt.Errorf("bad tax_id %s", data)
+	}
+
+	*id = TaxId(v)
+
+	return nil
+}
+
+// NewMatrix converts a motif to its JASPAR JSON representation.
+// Counts are estimated from the weights if the motif has none and
+// the class and family are taken from its TFClass annotations.
+func NewMatrix(motif *Motif) *Matrix {
+	ret := Matrix{MatrixId: motif.MotifId,
+		Name:       motif.Name,
+		Collection: motif.Collection,
+		Type:       motif.DataType,
+		TaxGroup:   motif.TaxGroup,
+		UniprotIds: motif.UniprotIds,
+		PubmedIds:  motif.PubmedIds,
+		URL:        motif.URL,
+		Pfm:        make(map[string][]float64, len(DNAAlphabet))}
+
+	if ret.Name == "" {
+		ret.Name = motif.MotifId
+	}
+
+	// ids such as MA0001.1 are a base id and version
+	if base, version, found := strings.Cut(motif.MotifId, "."); found {
+		if v, err := strconv.Atoi(version); err == nil {
+			ret.BaseId = base
+			ret.Version = v
+		}
+	}
+
+	counts := motif.CountMatrix()
+
+	for b, letter := range DNAAlphabet {
+		row := make([]float64, len(counts))
+
+		for i, pc := range counts {
+			if b < len(pc) {
+				row[i] = pc[b]
+			}
+		}
+
+		ret.Pfm[string(letter)] = row
+	}
+
+	for _, family := range motif.Families {
+		switch family.Level {
+		case motifs.FamilyLevelClass:
+			ret.Class = append(ret.Class, family.Name)
+		case motifs.FamilyLevelFamily:
+			ret.Family = append(ret.Family, family.Name)
+		}
+	}
+
+	if motif.Species != nil {
+		ret.Species = []*Species{{TaxId: TaxId(motif.Species.TaxonId), Name: motif.Species.Name}}
+	}
+
+	return &ret
+}
+
+// Motif converts a JASPAR matrix to a motif with counts and
+// weights. The first species, if any, is used and the class and
+// family become families of the motif.
+func (m *Matrix) Motif() (*Motif, error) {
+	if m.MatrixId == "" {
+		return nil, ErrMotifId
+	}
+
+	motif := motifs.Motif{MotifId: m.MatrixId, URL: m.URL}
+
+	if m.Name != "" {
+		motif.Name = m.Name
+	} else {
+		motif.Name = m.MatrixId
+	}
+
+	rows := make([][]float64, len(DNAAlphabet))
+
+	for key, counts := range m.Pfm {
+		idx := strings.Index(DNAAlphabet, strings.ToUpper(key))
+
+		if len(key) != 1 || idx == -1 {
+			return nil, fmt.Errorf("%w: %s has unknown base %s", ErrMatrixRow, m.MatrixId, key)
+		}
+
+		rows[idx] = counts
+	}
+
+	for _, row := range rows {
+		if len(row) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrShortMatrix, m.MatrixId)
+		}
+	}
+
+	err := setCounts(&motif, rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Species) > 0 && m.Species[0].TaxId > 0 {
+		species, err := motifs.ParseSpecies(strconv.Itoa(int(m.Species[0].TaxId)))
+
+		if err != nil {
+			return nil, err
+		}
+
+		// keep the name JASPAR gives for species we do not know
+		if species.Name == "" {
+			species.Name = m.Species[0].Name
+		}
+
+		motif.Species = species
+	}
+
+	for _, name := range m.Class {
+		motif.Families = append(motif.Families, &motifs.Family{Entity: db.Entity{Name: name}, Level: motifs.FamilyLevelClass})
+	}
+
+	for _, name := range m.Family {
+		motif.Families = append(motif.Families, &motifs.Family{Entity: db.Entity{Name: name}, Level: motifs.FamilyLevelFamily})
+	}
+
+	return &Motif{Motif: &motif,
+		Collection: m.Collection,
+		DataType:   m.Type,
+		TaxGroup:   m.TaxGroup,
+		UniprotIds: m.UniprotIds,
+		PubmedIds:  m.PubmedIds}, nil
+}
+
+func ReadJSONFile(file string) ([]*Motif, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadJSON(f)
+}
+
+// ReadJSONMatrices reads a single JASPAR matrix, a list of them or a
+// page of API results with the matrices in results
+func ReadJSONMatrices(r io.Reader) ([]*Matrix, error) {
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+
+	if len(data) == 0 {
+		return nil, ErrJSON
+	}
+
+	switch data[0] {
+	case '[':
+		var ret []*Matrix
+
+		err := json.Unmarshal(data, &ret)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrJSON, err)
+		}
+
+		return ret, nil
+	case '{':
+		var fields map[string]json.RawMessage
+
+		err := json.Unmarshal(data, &fields)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrJSON, err)
+		}
+
+		if _, found := fields["results"]; found {
+			var page resultsPage
+
+			err := json.Unmarshal(data, &page)
+
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrJSON, err)
+			}
+
+			return page.Results, nil
+		}
+
+		var m Matrix
+
+		err = json.Unmarshal(data, &m)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrJSON, err)
+		}
+
+		return []*Matrix{&m}, nil
+	default:
+		return nil, ErrJSON
+	}
+}
+
+// ReadJSON reads JASPAR JSON as motifs, see ReadJSONMatrices
+func ReadJSON(r io.Reader) ([]*Motif, error) {
+	matrices, err := ReadJSONMatrices(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Motif, 0, len(matrices))
+
+	for _, m := range matrices {
+		motif, err := m.Motif()
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, motif)
+	}
+
+	return ret, nil
+}
+
+// WriteJSON writes motifs as a list of JASPAR matrices
+func WriteJSON(w io.Writer, ms []*Motif) error {
+	matrices := make([]*Matrix, 0, len(ms))
+
+	for _, motif := range ms {
+		if motif.MotifId == "" {
+			return ErrMotifId
+		}
+
+		if len(motif.Weights) == 0 {
+			return fmt.Errorf("%w: %s", ErrShortMatrix, motif.MotifId)
+		}
+
+		matrices = append(matrices, NewMatrix(motif))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(matrices)
+}
+
+// WriteJSONMotifs writes motifs without JASPAR metadata as a list of
+// JASPAR matrices
+func WriteJSONMotifs(w io.Writer, ms []*motifs.Motif) error {
+	jasparMotifs := make([]*Motif, 0, len(ms))
+
+	for _, motif := range ms {
+		jasparMotifs = append(jasparMotifs, &Motif{Motif: motif})
+	}
+
+	return WriteJSON(w, jasparMotifs)
+}
@@ -0,0 +1,603 @@
+package motifs
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type (
+	// What the height of each letter in a logo represents
+	LogoMode string
+
+	LogoOptions struct {
+		// defaults to information content
+		Mode LogoMode `json:"mode"`
+		// draw the logo of the opposite strand
+		RevComp bool `json:"revComp"`
+		// A, C, G, T colours as #rgb or #rrggbb
+		Colors []string `json:"colors"`
+		// show the y axis and position numbers
+		Axis bool `json:"axis"`
+		// width in pixels of each position
+		PositionWidth int `json:"positionWidth"`
+		// total height in pixels including the axis
+		Height int `json:"height"`
+		// A, C, G, T frequencies information content is measured
+		// against. Defaults to uniform
+		Background []float64 `json:"background"`
+	}
+
+	logoPoint struct {
+		x float64
+		y float64
+	}
+
+	// the few drawing operations a logo needs so that SVG and PNG
+	// output are laid out by the same code
+	logoCanvas interface {
+		polygons(polys [][]logoPoint, color string)
+		line(x1, y1, x2, y2 float64, color string)
+		// y is the baseline. Rotated text reads bottom to top
+		text(x, y float64, s string, size float64, anchor string, rotate bool, color string)
+	}
+)
+
+const (
+	LogoInformation LogoMode = "ic"
+	LogoProbability LogoMode = "prob"
+
+	DefaultLogoPositionWidth = 30
+	DefaultLogoHeight        = 150
+
+	// PNGs are drawn in memory for anyone who asks, so a 35 position
+	// logo is at most about 3500 x 500 pixels
+	MaxLogoPositionWidth = 100
+	MaxLogoHeight        = 500
+
+	// room left of the letters for the y axis
+	logoAxisWidth = 38
+	logoAxisColor = "#333333"
+	logoFontSize  = 10
+)
+
+var (
+	// green, blue, orange and red as used by most logo tools
+	DefaultLogoColors = []string{"#009e00", "#0000ee", "#f5a000", "#e00000"}
+
+	ErrLogoMode  = errors.New("logo mode must be ic or prob")
+	ErrLogoColor = errors.New("logo colours must be 4 hex colours for a, c, g, t")
+	ErrLogoSize  = errors.New("logo size out of range")
+
+	// letter outlines in a unit box with y pointing down. Holes are
+	// separate polygons filled with the even-odd rule
+	logoGlyphs = [][][]logoPoint{
+		// A
+		{
+			{{0, 1}, {0.38, 0}, {0.62, 0}, {1, 1}, {0.78, 1}, {0.69, 0.74}, {0.31, 0.74}, {0.22, 1}},
+			{{0.37, 0.56}, {0.63, 0.56}, {0.5, 0.2}},
+		},
+		// C
+		{
+			slices.Concat(logoArc(0.5, 0.5, 0.5, 0.5, 40, 320), logoArc(0.5, 0.5, 0.3, 0.32, 320, 40)),
+		},
+		// G
+		{
+			slices.Concat(logoArc(0.5, 0.5, 0.5, 0.5, 40, 360),
+				[]logoPoint{{0.52, 0.5}, {0.52, 0.64}, {0.8, 0.64}},
+				logoArc(0.5, 0.5, 0.3, 0.32, 332, 40)),
+		},
+		// T
+		{
+			{{0, 0}, {1, 0}, {1, 0.16}, {0.59, 0.16}, {0.59, 1}, {0.41, 1}, {0.41, 0.16}, {0, 0.16}},
+		},
+	}
+
+	// 3x5 bitmap font for axis labels in PNGs
+	logoFont = map[rune][5]string{
+		'0': {"###", "#.#", "#.#", "#.#", "###"},
+		'1': {".#.", "##.", ".#.", ".#.", "###"},
+		'2': {"###", "..#", "###", "#..", "###"},
+		'3': {"###", "..#", "###", "..#", "###"},
+		'4': {"#.#", "#.#", "###", "..#", "..#"},
+		'5': {"###", "#..", "###", "..#", "###"},
+		'6': {"###", "#..", "###", "#.#", "###"},
+		'7': {"###", "..#", "..#", "..#", "..#"},
+		'8': {"###", "#.#", "###", "#.#", "###"},
+		'9': {"###", "#.#", "###", "..#", "###"},
+		'.': {"...", "...", "...", "...", ".#."},
+		'b': {"#..", "#..", "###", "#.#", "###"},
+		'i': {".#.", "...", ".#.", ".#.", ".#."},
+		't': {".#.", "###", ".#.", ".#.", ".##"},
+		's': {"...", "###", "##.", "..#", "###"},
+		'p': {"...", "###", "#.#", "###", "#.."},
+		'r': {"...", "###", "#..", "#..", "#.."},
+		'o': {"...", "###", "#.#", "#.#", "###"},
+	}
+)
+
+// points along an elliptical arc between two angles in degrees,
+// measured anticlockwise from the right as on a page
+func logoArc(cx, cy, rx, ry, from, to float64) []logoPoint {
+	const steps = 24
+
+	ret := make([]logoPoint, 0, steps+1)
+
+	for i := range steps + 1 {
+		a := (from + (to-from)*float64(i)/steps) * math.Pi / 180
+		ret = append(ret, logoPoint{cx + rx*math.Cos(a), cy - ry*math.Sin(a)})
+	}
+
+	return ret
+}
+
+func NewLogoOptions() *LogoOptions {
+	return &LogoOptions{Mode: LogoInformation,
+		Colors:        DefaultLogoColors,
+		Axis:          true,
+		PositionWidth: DefaultLogoPositionWidth,
+		Height:        DefaultLogoHeight,
+		Background:    UniformBackground}
+}
+
+// fill in defaults and check options are sensible
+func (opts *LogoOptions) validate() error {
+	if opts.Mode == "" {
+		opts.Mode = LogoInformation
+	}
+
+	if opts.Mode != LogoInformation && opts.Mode != LogoProbability {
+		return fmt.Errorf("%w: %s", ErrLogoMode, opts.Mode)
+	}
+
+	if len(opts.Colors) == 0 {
+		opts.Colors = DefaultLogoColors
+	}
+
+	if len(opts.Colors) != 4 {
+		return ErrLogoColor
+	}
+
+	for _, c := range opts.Colors {
+		_, err := parseHexColor(c)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.PositionWidth == 0 {
+		opts.PositionWidth = DefaultLogoPositionWidth
+	}
+
+	if opts.Height == 0 {
+		opts.Height = DefaultLogoHeight
+	}
+
+	if opts.PositionWidth < 1 || opts.PositionWidth > MaxLogoPositionWidth ||
+		opts.Height < 40 || opts.Height > MaxLogoHeight {
+		return ErrLogoSize
+	}
+
+	if len(opts.Background) == 0 {
+		opts.Background = UniformBackground
+	}
+
+	return validateBackground(opts.Background)
+}
+
+func parseHexColor(s string) (color.NRGBA, error) {
+	hex, found := strings.CutPrefix(s, "#")
+
+	if !found || (len(hex) != 3 && len(hex) != 6) {
+		return color.NRGBA{}, fmt.Errorf("%w: %s", ErrLogoColor, s)
+	}
+
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("%w: %s", ErrLogoColor, s)
+	}
+
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// height of each letter at each position in axis units along with
+// the top of the axis
+func (motif *Motif) logoHeights(opts *LogoOptions) ([][]float64, float64, error) {
+	weights, err := NormalizeWeights(motif.Weights)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", motif.MotifId, err)
+	}
+
+	if opts.RevComp {
+		RevCompWeights(weights)
+	}
+
+	if opts.Mode == LogoProbability {
+		return weights, 1, nil
+	}
+
+	bg := opts.Background
+
+	for _, pw := range weights {
+		// relative entropy to the background in bits
+		ic := 0.0
+
+		for b, p := range pw {
+			if p > 0 {
+				ic += p * math.Log2(p/bg[b])
+			}
+		}
+
+		for b := range pw {
+			pw[b] *= ic
+		}
+	}
+
+	return weights, math.Log2(1 / slices.Min(bg)), nil
+}
+
+// lay out letters and axes on a canvas
+func drawLogo(canvas logoCanvas, heights [][]float64, yMax float64, opts *LogoOptions) {
+	left := 2.0
+	bottom := 2.0
+	top := 6.0
+
+	if opts.Axis {
+		left = logoAxisWidth
+		bottom = 18
+	}
+
+	plotHeight := float64(opts.Height) - top - bottom
+	baseline := top + plotHeight
+	pw := float64(opts.PositionWidth)
+	scale := plotHeight / yMax
+
+	for i, column := range heights {
+		x := left + float64(i)*pw
+
+		// stack letters with the largest on top
+		order := []int{0, 1, 2, 3}
+
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(column[a], column[b])
+		})
+
+		y := baseline
+
+		for _, b := range order {
+			h := column[b] * scale
+
+			// too small to see
+			if h < 0.5 {
+				continue
+			}
+
+			polys := make([][]logoPoint, 0, len(logoGlyphs[b]))
+
+			for _, glyph := range logoGlyphs[b] {
+				poly := make([]logoPoint, 0, len(glyph))
+
+				for _, p := range glyph {
+					// keep a small gap between positions
+					poly = append(poly, logoPoint{x + 0.5 + p.x*(pw-1), y - h + p.y*h})
+				}
+
+				polys = append(polys, poly)
+			}
+
+			canvas.polygons(polys, opts.Colors[b])
+
+			y -= h
+		}
+	}
+
+	if !opts.Axis {
+		return
+	}
+
+	axisX := left - 4
+	canvas.line(axisX, top, axisX, baseline, logoAxisColor)
+
+	ticks := []float64{0, 0.5, 1}
+	title := "prob"
+
+	if opts.Mode == LogoInformation {
+		ticks = ticks[:0]
+		title = "bits"
+
+		for t := 0.0; t <= yMax+1e-9; t++ {
+			ticks = append(ticks, t)
+		}
+	}
+
+	for _, t := range ticks {
+		y := baseline - t*scale
+		canvas.line(axisX-3, y, axisX, y, logoAxisColor)
+		canvas.text(axisX-5, y+logoFontSize/2-1, strconv.FormatFloat(t, 'f', -1, 64), logoFontSize, "end", false, logoAxisColor)
+	}
+
+	canvas.text(logoFontSize, top+plotHeight/2, title, logoFontSize, "middle", true, logoAxisColor)
+
+	for i := range heights {
+		x := left + (float64(i)+0.5)*pw
+		canvas.text(x, baseline+logoFontSize+3, strconv.Itoa(i+1), logoFontSize, "middle", false, logoAxisColor)
+	}
+}
+
+func (opts *LogoOptions) logoSize(w int) (int, int) {
+	left := 2
+	right := 2
+
+	if opts.Axis {
+		left = logoAxisWidth
+	}
+
+	return left + w*opts.PositionWidth + right, opts.Height
+}
+
+// LogoSVG writes a sequence logo of the motif as SVG
+func (motif *Motif) LogoSVG(w io.Writer, opts *LogoOptions) error {
+	if opts == nil {
+		opts = NewLogoOptions()
+	}
+
+	err := opts.validate()
+
+	if err != nil {
+		return err
+	}
+
+	heights, yMax, err := motif.logoHeights(opts)
+
+	if err != nil {
+		return err
+	}
+
+	width, height := opts.logoSize(len(heights))
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+
+	drawLogo(&svgCanvas{w: bw}, heights, yMax, opts)
+
+	bw.WriteString("</svg>\n")
+
+	return bw.Flush()
+}
+
+// LogoPNG writes a sequence logo of the motif as PNG with a
+// transparent background
+func (motif *Motif) LogoPNG(w io.Writer, opts *LogoOptions) error {
+	if opts == nil {
+		opts = NewLogoOptions()
+	}
+
+	err := opts.validate()
+
+	if err != nil {
+		return err
+	}
+
+	heights, yMax, err := motif.logoHeights(opts)
+
+	if err != nil {
+		return err
+	}
+
+	width, height := opts.logoSize(len(heights))
+
+	canvas := pngCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+
+	drawLogo(&canvas, heights, yMax, opts)
+
+	return png.Encode(w, canvas.img)
+}
+
+type svgCanvas struct {
+	w *bufio.Writer
+}
+
+func svgNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func (c *svgCanvas) polygons(polys [][]logoPoint, color string) {
+	c.w.WriteString(`<path fill-rule="evenodd" fill="` + color + `" d="`)
+
+	for _, poly := range polys {
+		for i, p := range poly {
+			if i == 0 {
+				c.w.WriteString("M")
+			} else {
+				c.w.WriteString("L")
+			}
+
+			c.w.WriteString(svgNum(p.x) + "," + svgNum(p.y))
+		}
+
+		c.w.WriteString("Z")
+	}
+
+	c.w.WriteString("\"/>\n")
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, color string) {
+	fmt.Fprintf(c.w, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`+"\n",
+		svgNum(x1), svgNum(y1), svgNum(x2), svgNum(y2), color)
+}
+
+func (c *svgCanvas) text(x, y float64, s string, size float64, anchor string, rotate bool, color string) {
+	transform := ""
+
+	if rotate {
+		transform = fmt.Sprintf(` transform="rotate(-90 %s %s)"`, svgNum(x), svgNum(y))
+	}
+
+	fmt.Fprintf(c.w, `<text x="%s" y="%s" font-family="sans-serif" font-size="%s" text-anchor="%s" fill="%s"%s>%s</text>`+"\n",
+		svgNum(x), svgNum(y), svgNum(size), anchor, color, transform, s)
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (c *pngCanvas) polygons(polys [][]logoPoint, col string) {
+	rgba, _ := parseHexColor(col)
+
+	mask := rasterize(polys, c.img.Bounds())
+
+	draw.DrawMask(c.img, mask.Rect, image.NewUniform(rgba), image.Point{}, mask, mask.Rect.Min, draw.Over)
+}
+
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, col string) {
+	// lines are only ever horizontal or vertical so draw them as
+	// thin rectangles
+	x1, x2 = min(x1, x2)-0.5, max(x1, x2)+0.5
+	y1, y2 = min(y1, y2)-0.5, max(y1, y2)+0.5
+
+	c.polygons([][]logoPoint{{{x1, y1}, {x2, y1}, {x2, y2}, {x1, y2}}}, col)
+}
+
+func (c *pngCanvas) text(x, y float64, s string, size float64, anchor string, rotate bool, col string) {
+	// each font pixel is a square, with a gap of one between
+	// characters
+	px := size / 7
+	width := float64(4*len(s)-1) * px
+
+	start := 0.0
+
+	switch anchor {
+	case "middle":
+		start = -width / 2
+	case "end":
+		start = -width
+	}
+
+	polys := make([][]logoPoint, 0, 20)
+
+	for i, ch := range s {
+		rows, found := logoFont[ch]
+
+		if !found {
+			continue
+		}
+
+		for r, row := range rows {
+			for k := range 3 {
+				if row[k] != '#' {
+					continue
+				}
+
+				// offset along the text and up from the baseline
+				u := start + float64(i*4+k)*px
+				v := float64(4-r) * px
+
+				square := make([]logoPoint, 0, 4)
+
+				for _, d := range []logoPoint{{0, 0}, {px, 0}, {px, px}, {0, px}} {
+					if rotate {
+						square = append(square, logoPoint{x - v - d.y, y - u - d.x})
+					} else {
+						square = append(square, logoPoint{x + u + d.x, y - v - d.y})
+					}
+				}
+
+				polys = append(polys, square)
+			}
+		}
+	}
+
+	if len(polys) > 0 {
+		c.polygons(polys, col)
+	}
+}
+
+// rasterize polygons with the even-odd rule into a coverage mask,
+// sampling each pixel on a 4x4 grid for anti-aliasing
+func rasterize(polys [][]logoPoint, bounds image.Rectangle) *image.Alpha {
+	const ss = 4
+
+	minX := math.Inf(1)
+	minY := math.Inf(1)
+	maxX := math.Inf(-1)
+	maxY := math.Inf(-1)
+
+	for _, poly := range polys {
+		for _, p := range poly {
+			minX = min(minX, p.x)
+			minY = min(minY, p.y)
+			maxX = max(maxX, p.x)
+			maxY = max(maxY, p.y)
+		}
+	}
+
+	rect := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(bounds)
+
+	mask := image.NewAlpha(rect)
+
+	if rect.Empty() {
+		return mask
+	}
+
+	counts := make([]int, rect.Dx())
+	crossings := make([]float64, 0, 16)
+
+	for py := rect.Min.Y; py < rect.Max.Y; py++ {
+		clear(counts)
+
+		for sy := range ss {
+			y := float64(py) + (float64(sy)+0.5)/ss
+			crossings = crossings[:0]
+
+			for _, poly := range polys {
+				for i, a := range poly {
+					b := poly[(i+1)%len(poly)]
+
+					if (a.y <= y) == (b.y <= y) {
+						continue
+					}
+
+					crossings = append(crossings, a.x+(y-a.y)*(b.x-a.x)/(b.y-a.y))
+				}
+			}
+
+			slices.Sort(crossings)
+
+			for i := 0; i+1 < len(crossings); i += 2 {
+				// sub-samples whose centres fall in the span
+				k0 := max(int(math.Ceil(crossings[i]*ss-0.5)), rect.Min.X*ss)
+				k1 := min(int(math.Ceil(crossings[i+1]*ss-0.5)), rect.Max.X*ss)
+
+				for k := k0; k < k1; k++ {
+					counts[k/ss-rect.Min.X]++
+				}
+			}
+		}
+
+		for i, n := range counts {
+			mask.SetAlpha(rect.Min.X+i, py, color.Alpha{A: uint8(n * 255 / (ss * ss))})
+		}
+	}
+
+	return mask
+}
@@ -0,0 +1,91 @@
+package motifs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLogoSVG(t *testing.T) {
+	motif := testMotif()
+
+	var buf bytes.Buffer
+
+	err := motif.LogoSVG(&buf, nil)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// must be well formed xml
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+
+	for {
+		_, err := dec.Token()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("invalid svg: %s", err)
+		}
+	}
+
+	svg := buf.String()
+
+	// the T at position 3 is certain so must be drawn in the T colour
+	if !strings.Contains(svg, `fill="#e00000"`) || !strings.Contains(svg, ">bits</text>") {
+		t.Fatalf("missing letters or axis")
+	}
+
+	opts := NewLogoOptions()
+	opts.Colors = []string{"red", "#00f", "#0f0", "#000"}
+
+	err = motif.LogoSVG(&buf, opts)
+
+	if !errors.Is(err, ErrLogoColor) {
+		t.Fatalf("expected colour error not %v", err)
+	}
+}
+
+func TestLogoPNG(t *testing.T) {
+	motif := testMotif()
+
+	opts := NewLogoOptions()
+	opts.Axis = false
+	opts.Mode = LogoProbability
+	opts.PositionWidth = 20
+	opts.Height = 100
+
+	var buf bytes.Buffer
+
+	err := motif.LogoPNG(&buf, opts)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	img, err := png.Decode(&buf)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	bounds := img.Bounds()
+
+	if bounds.Dx() != 4*20+4 || bounds.Dy() != 100 {
+		t.Fatalf("logo is %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	// middle of the stem of the T at position 3 should be solid red
+	r, g, b, a := img.At(2+2*20+10, 60).RGBA()
+
+	if r>>8 != 0xe0 || g != 0 || b != 0 || a>>8 != 0xff {
+		t.Fatalf("unexpected colour %x %x %x %x", r, g, b, a)
+	}
+}
@@ -0,0 +1,550 @@
+package meme
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+type (
+	// A parsed MEME minimal format file. Only the DNA alphabet
+	// is supported since motifs store weights as a, c, g, t
+	File struct {
+		Version  string   `json:"version"`
+		Alphabet string   `json:"alphabet"`
+		Strands  []string `json:"strands"`
+		// background letter frequencies in A, C, G, T order
+		Background []float64       `json:"background"`
+		Motifs     []*motifs.Motif `json:"motifs"`
+	}
+
+	// ParseError reports the line in the file where parsing failed
+	ParseError struct {
+		Line int
+		Err  error
+	}
+)
+
+const (
+	DefaultVersion = "4"
+	DNAAlphabet    = "ACGT"
+
+	// MEME tools assume 20 sites when nsites is not given
+	DefaultNSites = motifs.DefaultNSites
+
+	// how far a row of probabilities can stray from summing to 1
+	// before we reject it. Some published files are only given to
+	// 3 or 4 decimal places so we need a little slack
+	RowSumTolerance = 0.02
+)
+
+var (
+	ErrAlphabet     = errors.New("only the DNA alphabet ACGT is supported")
+	ErrBackground   = errors.New("malformed background letter frequencies")
+	ErrMissingMotif = errors.New("matrix found outside of a MOTIF block")
+	ErrMatrixHeader = errors.New("malformed matrix header")
+	ErrMatrixRow    = errors.New("malformed matrix row")
+	ErrNoMatrix     = errors.New("motif has no letter-probability matrix")
+	ErrShortMatrix  = errors.New("matrix has fewer rows than w")
+	ErrMotifId      = errors.New("MOTIF line has no id")
+
+	// uniform background used when a file does not specify one
+	UniformBackground = []float64{0.25, 0.25, 0.25, 0.25}
+)
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("meme: line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func NewFile(ms []*motifs.Motif) *File {
+	return &File{Version: DefaultVersion,
+		Alphabet:   DNAAlphabet,
+		Strands:    []string{"+", "-"},
+		Background: slices.Clone(UniformBackground),
+		Motifs:     ms}
+}
+
+func ReadFile(file string) (*File, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Read(f)
+}
+
+// line reader that keeps track of line numbers for
+// error reporting
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (lr *lineReader) next() (string, bool) {
+	if !lr.scanner.Scan() {
+		return "", false
+	}
+
+	lr.line++
+
+	return strings.TrimSpace(lr.scanner.Text()), true
+}
+
+func (lr *lineReader) errorf(err error, format string, args ...any) error {
+	if format != "" {
+		err = fmt.Errorf("%w: %s", err, fmt.Sprintf(format, args...))
+	}
+
+	return &ParseError{Line: lr.line, Err: err}
+}
+
+// Read parses MEME minimal motif format. Motifs are returned in
+// file order with MotifId set from the MOTIF line and Name set to
+// the alternate name if present, otherwise the id.
+func Read(r io.Reader) (*File, error) {
+	scanner := bufio.NewScanner(r)
+
+	// some URL lines and matrices with many columns can be long
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lr := &lineReader{scanner: scanner}
+
+	ret := File{Version: DefaultVersion,
+		Alphabet:   DNAAlphabet,
+		Strands:    []string{"+", "-"},
+		Background: slices.Clone(UniformBackground),
+		Motifs:     make([]*motifs.Motif, 0, 100)}
+
+	var currentMotif *motifs.Motif = nil
+	// line of the current MOTIF so we can report motifs without
+	// a matrix
+	motifLine := 0
+
+	// check the previous motif had a matrix before starting
+	// a new one
+	checkMotif := func() error {
+		if currentMotif != nil && len(currentMotif.Weights) == 0 {
+			return &ParseError{Line: motifLine, Err: fmt.Errorf("%w: %s", ErrNoMatrix, currentMotif.MotifId)}
+		}
+
+		return nil
+	}
+
+	for {
+		line, ok := lr.next()
+
+		if !ok {
+			break
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+
+		case strings.HasPrefix(line, "MEME version"):
+			ret.Version = strings.TrimSpace(strings.TrimPrefix(line, "MEME version"))
+
+		case strings.HasPrefix(line, "ALPHABET="):
+			alphabet := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(line, "ALPHABET=")))
+
+			if alphabet != DNAAlphabet {
+				return nil, lr.errorf(ErrAlphabet, "found %s", alphabet)
+			}
+
+			ret.Alphabet = alphabet
+
+		case strings.HasPrefix(line, "ALPHABET"):
+			// MEME 5 custom alphabet block. We accept DNA-like
+			// alphabets and skip the symbol definitions since
+			// the core symbols must be ACGT
+			if !strings.Contains(line, "DNA") {
+				return nil, lr.errorf(ErrAlphabet, "found %s", line)
+			}
+
+			for {
+				line, ok = lr.next()
+
+				if !ok || strings.HasPrefix(line, "END ALPHABET") {
+					break
+				}
+			}
+
+			ret.Alphabet = DNAAlphabet
+
+		case strings.HasPrefix(line, "strands:"):
+			ret.Strands = strings.Fields(strings.TrimPrefix(line, "strands:"))
+
+		case strings.HasPrefix(line, "Background letter frequencies"):
+			bg, err := readBackground(lr)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ret.Background = bg
+
+		case strings.HasPrefix(line, "MOTIF"):
+			err := checkMotif()
+
+			if err != nil {
+				return nil, err
+			}
+
+			tokens := strings.Fields(line)
+
+			if len(tokens) < 2 {
+				return nil, lr.errorf(ErrMotifId, "")
+			}
+
+			currentMotif = &motifs.Motif{MotifId: tokens[1],
+				Genes:   make([]string, 0, 5),
+				Weights: make([][]float64, 0, 20)}
+
+			// alt name is optional, in which case we use the id
+			if len(tokens) > 2 {
+				currentMotif.Name = tokens[2]
+			} else {
+				currentMotif.Name = tokens[1]
+			}
+
+			motifLine = lr.line
+
+			ret.Motifs = append(ret.Motifs, currentMotif)
+
+		case strings.HasPrefix(line, "letter-probability matrix"):
+			if currentMotif == nil {
+				return nil, lr.errorf(ErrMissingMotif, "")
+			}
+
+			err := readMatrix(lr, line, currentMotif)
+
+			if err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(line, "log-odds matrix"):
+			// we derive log odds ourselves so skip these rows
+			if currentMotif == nil {
+				return nil, lr.errorf(ErrMissingMotif, "")
+			}
+
+			header, err := parseMatrixHeader(lr, line)
+
+			if err != nil {
+				return nil, err
+			}
+
+			w := int(header["w"])
+
+			for range w {
+				_, ok := lr.next()
+
+				if !ok {
+					return nil, lr.errorf(ErrShortMatrix, "")
+				}
+			}
+
+		case strings.HasPrefix(line, "URL"):
+			if currentMotif != nil {
+				currentMotif.URL = strings.TrimSpace(strings.TrimPrefix(line, "URL"))
+			}
+
+		default:
+			// MEME output files contain lots of other sections
+			// such as sites and block diagrams which we can ignore
+		}
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkMotif()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// background frequencies follow the header as letter/value
+// pairs, possibly over several lines e.g. A 0.25 C 0.25 ...
+func readBackground(lr *lineReader) ([]float64, error) {
+	bg := make([]float64, len(DNAAlphabet))
+	found := 0
+
+	for found < len(DNAAlphabet) {
+		line, ok := lr.next()
+
+		if !ok {
+			return nil, lr.errorf(ErrBackground, "unexpected end of file")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		tokens := strings.Fields(line)
+
+		if len(tokens)%2 != 0 {
+			return nil, lr.errorf(ErrBackground, "%s", line)
+		}
+
+		for i := 0; i < len(tokens); i += 2 {
+			idx := strings.Index(DNAAlphabet, strings.ToUpper(tokens[i]))
+
+			if len(tokens[i]) != 1 || idx == -1 {
+				return nil, lr.errorf(ErrBackground, "unknown letter %s", tokens[i])
+			}
+
+			v, err := strconv.ParseFloat(tokens[i+1], 64)
+
+			if err != nil || v < 0 {
+				return nil, lr.errorf(ErrBackground, "bad frequency %s", tokens[i+1])
+			}
+
+			bg[idx] = v
+			found++
+		}
+	}
+
+	return bg, nil
+}
+
+// parse key= value pairs from a matrix header line such as
+// letter-probability matrix: alength= 4 w= 10 nsites= 97 E= 0
+func parseMatrixHeader(lr *lineReader, line string) (map[string]float64, error) {
+	ret := make(map[string]float64)
+
+	_, params, found := strings.Cut(line, ":")
+
+	if !found {
+		return ret, nil
+	}
+
+	// normalize so that both "w= 10" and "w=10" are tokenized
+	// the same way
+	tokens := strings.Fields(strings.ReplaceAll(params, "=", "= "))
+
+	for i := 0; i < len(tokens); i++ {
+		key, isKey := strings.CutSuffix(tokens[i], "=")
+
+		if !isKey {
+			return nil, lr.errorf(ErrMatrixHeader, "unexpected token %s", tokens[i])
+		}
+
+		if i+1 >= len(tokens) {
+			return nil, lr.errorf(ErrMatrixHeader, "missing value for %s", key)
+		}
+
+		v, err := strconv.ParseFloat(tokens[i+1], 64)
+
+		if err != nil {
+			return nil, lr.errorf(ErrMatrixHeader, "bad value %s for %s", tokens[i+1], key)
+		}
+
+		ret[key] = v
+		i++
+	}
+
+	return ret, nil
+}
+
+func readMatrix(lr *lineReader, line string, motif *motifs.Motif) error {
+	header, err := parseMatrixHeader(lr, line)
+
+	if err != nil {
+		return err
+	}
+
+	alength, ok := header["alength"]
+
+	if ok && int(alength) != len(DNAAlphabet) {
+		return lr.errorf(ErrAlphabet, "alength= %d", int(alength))
+	}
+
+	w, ok := header["w"]
+
+	if !ok || w < 1 || w != math.Trunc(w) {
+		return lr.errorf(ErrMatrixHeader, "w must be a positive integer")
+	}
+
+	if nsites, ok := header["nsites"]; ok {
+		motif.NSites = nsites
+	}
+
+	if e, ok := header["E"]; ok {
+		motif.EValue = e
+	}
+
+	weights := make([][]float64, 0, int(w))
+
+	for len(weights) < int(w) {
+		line, ok := lr.next()
+
+		if !ok {
+			return lr.errorf(ErrShortMatrix, "expected %d rows, found %d", int(w), len(weights))
+		}
+
+		if line == "" {
+			continue
+		}
+
+		tokens := strings.Fields(line)
+
+		if len(tokens) != len(DNAAlphabet) {
+			return lr.errorf(ErrMatrixRow, "expected %d columns, found %d", len(DNAAlphabet), len(tokens))
+		}
+
+		row := make([]float64, len(DNAAlphabet))
+		sum := 0.0
+
+		for i, token := range tokens {
+			v, err := strconv.ParseFloat(token, 64)
+
+			if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+				return lr.errorf(ErrMatrixRow, "bad probability %s", token)
+			}
+
+			row[i] = v
+			sum += v
+		}
+
+		if math.Abs(sum-1) > RowSumTolerance {
+			return lr.errorf(ErrMatrixRow, "probabilities sum to %g", sum)
+		}
+
+		weights = append(weights, row)
+	}
+
+	motif.Weights = weights
+
+	return nil
+}
+
+// Write writes motifs in MEME minimal format.
+func Write(w io.Writer, f *File) error {
+	bw := bufio.NewWriter(w)
+
+	version := f.Version
+
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	strands := f.Strands
+
+	if len(strands) == 0 {
+		strands = []string{"+", "-"}
+	}
+
+	bg := f.Background
+
+	if len(bg) != len(DNAAlphabet) {
+		bg = UniformBackground
+	}
+
+	fmt.Fprintf(bw, "MEME version %s\n\n", version)
+	fmt.Fprintf(bw, "ALPHABET= %s\n\n", DNAAlphabet)
+	fmt.Fprintf(bw, "strands: %s\n\n", strings.Join(strands, " "))
+	fmt.Fprintf(bw, "Background letter frequencies\n")
+
+	for i, letter := range DNAAlphabet {
+		if i > 0 {
+			bw.WriteString(" ")
+		}
+
+		fmt.Fprintf(bw, "%c %s", letter, formatFloat(bg[i]))
+	}
+
+	bw.WriteString("\n")
+
+	for _, motif := range f.Motifs {
+		err := writeMotif(bw, motif)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteMotifs writes motifs with a default DNA header and
+// uniform background.
+func WriteMotifs(w io.Writer, ms []*motifs.Motif) error {
+	return Write(w, NewFile(ms))
+}
+
+func writeMotif(bw *bufio.Writer, motif *motifs.Motif) error {
+	if motif.MotifId == "" {
+		return ErrMotifId
+	}
+
+	if len(motif.Weights) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoMatrix, motif.MotifId)
+	}
+
+	bw.WriteString("\nMOTIF ")
+	bw.WriteString(motif.MotifId)
+
+	if motif.Name != "" && motif.Name != motif.MotifId {
+		bw.WriteString(" ")
+		bw.WriteString(motif.Name)
+	}
+
+	bw.WriteString("\n")
+
+	nsites := motif.NSites
+
+	if nsites <= 0 {
+		nsites = DefaultNSites
+	}
+
+	fmt.Fprintf(bw, "letter-probability matrix: alength= %d w= %d nsites= %s E= %s\n",
+		len(DNAAlphabet),
+		len(motif.Weights),
+		formatFloat(nsites),
+		formatFloat(motif.EValue))
+
+	for _, row := range motif.Weights {
+		if len(row) != len(DNAAlphabet) {
+			return fmt.Errorf("%w: %s", ErrMatrixRow, motif.MotifId)
+		}
+
+		for _, v := range row {
+			fmt.Fprintf(bw, " %s", formatFloat(v))
+		}
+
+		bw.WriteString("\n")
+	}
+
+	if motif.URL != "" {
+		fmt.Fprintf(bw, "URL %s\n", motif.URL)
+	}
+
+	return nil
+}
+
+// shortest representation that parses back to the same value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,136 @@
+package meme
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMeme = `MEME version 4
+
+ALPHABET= ACGT
+
+strands: + -
+
+Background letter frequencies
+A 0.3 C 0.2 G 0.2 T 0.3
+
+MOTIF MA0001.1 AGL3
+letter-probability matrix: alength= 4 w= 3 nsites= 97 E= 0
+ 0.000000  0.969072  0.010309  0.020619
+ 0.030928  0.773196  0.000000  0.195876
+ 0.814433  0.041237  0.030928  0.113402
+URL http://jaspar2022.genereg.net/matrix/MA0001.1
+
+MOTIF ALX3_DBD
+
+letter-probability matrix: alength= 4 w= 2 nsites= 20 E= 0
+  0.256975	  0.178358	  0.418072	  0.146595
+  0.135629	  0.472732	  0.174718	  0.216920
+`
+
+func TestRead(t *testing.T) {
+	f, err := Read(strings.NewReader(testMeme))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Motifs) != 2 {
+		t.Fatalf("expected 2 motifs, found %d", len(f.Motifs))
+	}
+
+	if f.Background[0] != 0.3 || f.Background[1] != 0.2 {
+		t.Fatalf("bad background %v", f.Background)
+	}
+
+	m := f.Motifs[0]
+
+	if m.MotifId != "MA0001.1" || m.Name != "AGL3" || m.NSites != 97 || len(m.Weights) != 3 {
+		t.Fatalf("bad motif %+v", m)
+	}
+
+	if m.URL != "http://jaspar2022.genereg.net/matrix/MA0001.1" {
+		t.Fatalf("bad url %s", m.URL)
+	}
+
+	if f.Motifs[1].Name != "ALX3_DBD" || f.Motifs[1].Weights[1][1] != 0.472732 {
+		t.Fatalf("bad motif %+v", f.Motifs[1])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	f, err := Read(strings.NewReader(testMeme))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err = Write(&buf, f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range f.Motifs {
+		m2 := f2.Motifs[i]
+
+		if m.MotifId != m2.MotifId || m.Name != m2.Name || m.URL != m2.URL || m.NSites != m2.NSites {
+			t.Fatalf("motif %d differs: %+v %+v", i, m, m2)
+		}
+
+		for j, row := range m.Weights {
+			for k, v := range row {
+				if m2.Weights[j][k] != v {
+					t.Fatalf("motif %d weight %d,%d differs", i, j, k)
+				}
+			}
+		}
+	}
+}
+
+func TestMalformedRow(t *testing.T) {
+	bad := strings.Replace(testMeme, " 0.030928  0.773196  0.000000  0.195876", " 0.030928  0.773196  0.195876", 1)
+
+	_, err := Read(strings.NewReader(bad))
+
+	var pe *ParseError
+
+	if !errors.As(err, &pe) || !errors.Is(err, ErrMatrixRow) {
+		t.Fatalf("expected matrix row error, found %v", err)
+	}
+
+	if pe.Line != 13 {
+		t.Fatalf("expected error on line 13, found %d", pe.Line)
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	files, err := filepath.Glob("../scripts/meme/*.meme")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		f, err := ReadFile(file)
+
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+
+		if len(f.Motifs) == 0 {
+			t.Fatalf("%s: no motifs", file)
+		}
+	}
+}
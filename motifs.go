@@ -2,10 +2,13 @@ package motifs
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/antonybholmes/go-sys"
 	"github.com/antonybholmes/go-sys/db"
@@ -37,6 +40,35 @@ type (
 
 		Genes   []string    `json:"genes"`
 		Weights [][]float64 `json:"weights"`
+
+		// raw a, c, g, t counts for formats such as JASPAR that give
+		// them, in the same orientation as weights
+		Counts [][]float64 `json:"counts,omitempty"`
+
+		// number of positions, only filled in when fetching a
+		// single motif
+		Length int `json:"length,omitempty"`
+
+		// why the motif matched a full text search
+		Match *FullTextMatch `json:"match,omitempty"`
+
+		// search terms that found the motif through a gene alias
+		Aliases []*AliasMatch `json:"aliases,omitempty"`
+
+		// organism the motif was derived from, if known
+		Species *Species `json:"species,omitempty"`
+
+		// classes and families of the factors binding the motif,
+		// most general first
+		Families []*Family `json:"families,omitempty"`
+
+		// only for motifs from HOCOMOCO collections
+		Hocomoco *HocomocoInfo `json:"hocomoco,omitempty"`
+
+		// optional metadata carried by motif file formats
+		NSites float64 `json:"nsites,omitempty"`
+		EValue float64 `json:"evalue,omitempty"`
+		URL    string  `json:"url,omitempty"`
 	}
 
 	MotifToGeneMap map[string]Motif
@@ -45,12 +77,18 @@ type (
 		db *sql.DB
 		//cache *expirable.LRU[string, any]
 		file string
+
+		// names used for suggestions, loaded on first successful use
+		terms     []*suggestTerm
+		termsLock sync.Mutex
 	}
 
 	MotifSearchResult struct {
 		Paging *Paging  `json:"paging"`
 		Motifs []*Motif `json:"motifs"`
 		Total  int      `json:"total"`
+		// close matches when nothing was found
+		Suggestions []*Suggestion `json:"suggestions,omitempty"`
 	}
 )
 
@@ -97,6 +135,15 @@ const (
 		FROM datasets d
 		ORDER BY d.name ASC`
 
+	// only available in databases made by the builder
+	DatasetBackgroundSql = `SELECT
+		d.bg_a,
+		d.bg_c,
+		d.bg_g,
+		d.bg_t
+		FROM datasets d
+		WHERE d.public_id = :id`
+
 	// SearchNumRecordsSql = `SELECT COUNT(m.id) AS total FROM (
 	// 		-- Direct match on motifs.id
 	// 		SELECT m.id
@@ -143,7 +190,22 @@ const (
 			JOIN temp_queries tq ON 
 				d.public_id = tq.query OR 
 				d.name LIKE tq.search
+
+			UNION
+
+			-- search gene aliases
+			SELECT 
+			d.public_id AS dataset_public_id,
+			d.name AS dataset_name, 
+			m.id
+			FROM motifs m
+			JOIN datasets d ON m.dataset_id = d.id
+			JOIN temp_datasets td ON d.public_id = td.id
+			JOIN motif_genes mg ON m.id = mg.motif_id
+			JOIN genes g ON mg.gene_id = g.id
+			JOIN temp_aliases ta ON LOWER(g.name) = ta.alias
 		) AS m
+		WHERE ` + MotifFilterSql + `
 		GROUP BY m.dataset_public_id;`
 
 	// SearchSql = `SELECT
@@ -170,25 +232,25 @@ const (
 	// 	LIMIT :limit
 	// 	OFFSET :offset;`
 
+	// one row per motif so that paging counts motifs. Genes and
+	// weights for the page are fetched afterwards in bulk
 	SearchSql = `SELECT DISTINCT
+		m.id,
 		m.dataset_public_id,
 		m.dataset_name,
 		m.motif_public_id,
 		m.motif_id, 
-		m.motif_name, 
-		gene
+		m.motif_name
 		FROM (
 			-- Direct match on motifs.id
 			SELECT 
+			m.id,
 			d.public_id AS dataset_public_id,
 			d.name AS dataset_name,
 			m.public_id AS motif_public_id, 
 			m.motif_id, 
-			m.motif_name, 
-			g.name AS gene
+			m.motif_name
 			FROM motifs m
-			JOIN motif_genes mg ON m.id = mg.motif_id
-			JOIN genes g ON mg.gene_id = g.id
 			JOIN datasets d ON m.dataset_id = d.id
 			JOIN temp_datasets td ON d.public_id = td.id
 			JOIN temp_queries tq ON 
@@ -200,22 +262,38 @@ const (
 
 			-- search datasets
 			SELECT 
+			m.id,
 			d.public_id AS dataset_public_id,
 			d.name AS dataset_name,
 			m.public_id AS motif_public_id, 
 			m.motif_id, 
-			m.motif_name,
-			g.name as gene
+			m.motif_name
 			FROM motifs m
-			JOIN motif_genes mg ON m.id = mg.motif_id
-			JOIN genes g ON mg.gene_id = g.id
 			JOIN datasets d ON m.dataset_id = d.id
 			JOIN temp_datasets td ON d.public_id = td.id
 			JOIN temp_queries tq ON 
 				d.public_id = tq.query OR 
 				d.name LIKE tq.search
+
+			UNION
+
+			-- search gene aliases
+			SELECT 
+			m.id,
+			d.public_id AS dataset_public_id,
+			d.name AS dataset_name,
+			m.public_id AS motif_public_id, 
+			m.motif_id, 
+			m.motif_name
+			FROM motifs m
+			JOIN datasets d ON m.dataset_id = d.id
+			JOIN temp_datasets td ON d.public_id = td.id
+			JOIN motif_genes mg ON m.id = mg.motif_id
+			JOIN genes g ON mg.gene_id = g.id
+			JOIN temp_aliases ta ON LOWER(g.name) = ta.alias
 			
 		) AS m
+		WHERE ` + MotifFilterSql + `
 		ORDER BY 
 			m.dataset_public_id, 
 			m.motif_id
@@ -224,11 +302,13 @@ const (
 
 	BoolCountSql = `SELECT DISTINCT
 		m.public_dataset_id,
+		m.dataset_name,
 		COUNT(m.id) AS total 
 		FROM (
 			-- Direct match on motifs.id
 			SELECT 
 			d.public_id AS public_dataset_id,
+			d.name AS dataset_name,
 			m.id
 			FROM motifs m
 			JOIN datasets d ON m.dataset_id = d.id
@@ -247,27 +327,26 @@ const (
 			JOIN temp_datasets td ON d.public_id = td.id
 			WHERE <<DATASETS>>
 		) AS m
+		WHERE ` + MotifFilterSql + `
 		GROUP BY m.public_dataset_id;`
 
 	BoolSearchSql = `SELECT DISTINCT
+		m.id,
 		m.dataset_public_id,
 		m.dataset_name,
 		m.motif_public_id,
 		m.motif_id, 
-		m.motif_name, 
-		gene
+		m.motif_name
 		FROM (
 			-- Direct match on motifs.id
 			SELECT 
+			m.id,
 			d.public_id AS dataset_public_id,
 			d.name AS dataset_name,
 			m.public_id AS motif_public_id,
 			m.motif_id, 
-			m.motif_name, 
-			g.name as gene
+			m.motif_name
 			FROM motifs m
-			JOIN motif_genes mg ON m.id = mg.motif_id
-			JOIN genes g ON mg.gene_id = g.id
 			JOIN datasets d ON m.dataset_id = d.id
 			JOIN temp_datasets td ON d.public_id = td.id
 			WHERE <<MOTIFS>>
@@ -276,21 +355,20 @@ const (
 
 			-- search datasets
 			SELECT 
+			m.id,
 			d.public_id AS dataset_public_id,
 			d.name AS dataset_name,
 			m.public_id motif_public_id, 
 			m.motif_id, 
-			m.motif_name, 
-			g.name as gene
+			m.motif_name
 			FROM motifs m
-			JOIN motif_genes mg ON m.id = mg.motif_id
-			JOIN genes g ON mg.gene_id = g.id
 			JOIN datasets d ON m.dataset_id = d.id
 			JOIN temp_datasets td ON d.public_id = td.id
 			WHERE <<DATASETS>>
 		) AS m
+		WHERE ` + MotifFilterSql + `
 		ORDER BY 
-			m.public_dataset_id, 
+			m.dataset_public_id, 
 			m.motif_id
 		LIMIT :limit 
 		OFFSET :offset;`
@@ -299,12 +377,108 @@ const (
 		w.a,
 		w.c,
 		w.g,
-		w.t
+		w.t,
+		w.count_a,
+		w.count_c,
+		w.count_g,
+		w.count_t
 		FROM weights w
 		JOIN motifs m ON w.motif_id = m.id
 		WHERE m.public_id = :id
 		ORDER BY w.id`
 
+	// motifs whose genes and weights are being fetched in bulk,
+	// keyed by internal id
+	TempPageMotifsTableSql = `CREATE TEMP TABLE IF NOT EXISTS temp_page_motifs (id INTEGER PRIMARY KEY);`
+
+	ClearTempPageMotifsSql = `DELETE FROM temp_page_motifs;`
+
+	InsertTempPageMotifSql = `INSERT INTO temp_page_motifs (id) VALUES (:id) ON CONFLICT DO NOTHING;`
+
+	PageGenesSql = `SELECT
+		mg.motif_id,
+		g.name
+		FROM temp_page_motifs tpm
+		JOIN motif_genes mg ON tpm.id = mg.motif_id
+		JOIN genes g ON mg.gene_id = g.id
+		ORDER BY mg.motif_id, g.name`
+
+	PageWeightsSql = `SELECT
+		w.motif_id,
+		w.a,
+		w.c,
+		w.g,
+		w.t,
+		w.count_a,
+		w.count_c,
+		w.count_g,
+		w.count_t
+		FROM temp_page_motifs tpm
+		JOIN weights w ON tpm.id = w.motif_id
+		ORDER BY w.motif_id, w.position`
+
+	PageMotifInfoSql = `SELECT
+		tpm.id,
+		m.nsites,
+		m.evalue,
+		m.url
+		FROM temp_page_motifs tpm
+		JOIN motifs m ON tpm.id = m.id`
+
+	MotifSql = `SELECT
+		d.public_id,
+		d.name,
+		m.public_id,
+		m.motif_id,
+		m.motif_name,
+		m.length,
+		m.taxon_id,
+		COALESCE(s.name, ''),
+		m.nsites,
+		m.evalue,
+		m.url
+		FROM motifs m
+		JOIN datasets d ON m.dataset_id = d.id
+		LEFT JOIN species s ON m.taxon_id = s.taxon_id
+		WHERE m.public_id = :id`
+
+	MotifGenesSql = `SELECT
+		g.name
+		FROM motif_genes mg
+		JOIN genes g ON mg.gene_id = g.id
+		JOIN motifs m ON mg.motif_id = m.id
+		WHERE m.public_id = :id
+		ORDER BY g.name`
+
+	TempMotifIdsTableSql = `CREATE TEMP TABLE IF NOT EXISTS temp_motif_ids (id TEXT PRIMARY KEY);`
+
+	InsertTempMotifIdSql = `INSERT INTO temp_motif_ids (id) VALUES (:id) ON CONFLICT DO NOTHING;`
+
+	// Motifs can be selected explicitly by public id or as sets
+	// using datasets and/or search queries. If only datasets are
+	// given all of their motifs are selected, if only queries are
+	// given they are matched against every dataset.
+	SelectMotifsSql = `SELECT DISTINCT
+		m.id,
+		d.public_id,
+		d.name,
+		m.public_id,
+		m.motif_id,
+		m.motif_name
+		FROM motifs m
+		JOIN datasets d ON m.dataset_id = d.id
+		WHERE m.public_id IN (SELECT id FROM temp_motif_ids) OR (
+			:use_sets AND
+			(:all_datasets OR d.public_id IN (SELECT id FROM temp_datasets)) AND
+			(:all_motifs OR EXISTS (
+				SELECT 1 FROM temp_queries tq WHERE 
+				m.public_id = tq.query OR
+				m.motif_id LIKE tq.search OR 
+				m.motif_name LIKE tq.search)))
+		ORDER BY 
+			d.public_id, 
+			m.motif_id`
+
 	MotifsToGenes = `SELECT
 			tq.id,
 			tq.query,
@@ -312,13 +486,53 @@ const (
 			g.name
 		FROM genes g
 		JOIN motif_genes mg ON g.id = mg.gene_id
-		JOIN motifs m ON mg.motif_id = m.id
-		JOIN temp_queries tq ON 
-			m.public_id = tq.query OR
-			m.motif_id LIKE tq.search OR 
-			m.motif_name LIKE tq.search
+		JOIN (
+			SELECT tq.id AS query_id, m.id AS motif_id
+			FROM motifs m
+			JOIN temp_queries tq ON 
+				m.public_id = tq.query OR
+				m.motif_id LIKE tq.search OR 
+				m.motif_name LIKE tq.search
+
+			UNION
+
+			-- motifs of genes the query is an alias of
+			SELECT tq.id AS query_id, amg.motif_id
+			FROM temp_queries tq
+			JOIN temp_aliases ta ON tq.query = ta.query
+			JOIN genes ag ON LOWER(ag.name) = ta.alias
+			JOIN motif_genes amg ON ag.id = amg.gene_id
+		) AS qm ON mg.motif_id = qm.motif_id
+		JOIN temp_queries tq ON qm.query_id = tq.id
 		ORDER BY 
 			tq.id, g.name`
+
+	// genes are matched case-insensitively by storing the lowercase
+	// gene as the search term
+	GenesToMotifsSql = `SELECT
+			tq.search,
+			m.id,
+			d.public_id,
+			d.name,
+			m.public_id,
+			m.motif_id,
+			m.motif_name
+		FROM temp_queries tq
+		JOIN genes g ON LOWER(g.name) = tq.search
+		JOIN motif_genes mg ON g.id = mg.gene_id
+		JOIN motifs m ON mg.motif_id = m.id
+		JOIN datasets d ON m.dataset_id = d.id
+		WHERE (:all_datasets OR d.public_id IN (SELECT id FROM temp_datasets)) AND
+			` + MotifFilterSql + `
+		ORDER BY
+			tq.id,
+			d.name,
+			m.motif_id`
+)
+
+var (
+	ErrMotifNotFound = errors.New("motif not found")
+	ErrTooManyMotifs = errors.New("too many motifs selected")
 )
 
 func NewMotifDB(file string) *MotifDB {
@@ -362,8 +576,39 @@ func (mdb *MotifDB) Datasets() ([]*Dataset, error) {
 	return datasets, nil
 }
 
+// DatasetBackground returns the A, C, G, T background frequencies
+// recorded in the header of the file a dataset was built from
+func (mdb *MotifDB) DatasetBackground(publicId string) ([]float64, error) {
+	schema, err := readSchema(mdb.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// older databases do not record backgrounds
+	if !schema.builder {
+		return slices.Clone(UniformBackground), nil
+	}
+
+	bg := make([]float64, 4)
+
+	err = mdb.db.QueryRow(DatasetBackgroundSql, sql.Named("id", publicId)).
+		Scan(&bg[0], &bg[1], &bg[2], &bg[3])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bg, nil
+}
+
+// Search finds motifs whose id or name starts with one of the
+// queries, that are in a dataset named by a query, or that bind a
+// gene a query is an alias of. Only motifs that pass the filter are
+// returned.
 func (mdb *MotifDB) Search(queries []string,
 	datasets []string,
+	filter *MotifFilter,
 	paging *Paging,
 	revComp bool) (*MotifSearchResult, error) {
 	// clamp page number
@@ -436,6 +681,24 @@ func (mdb *MotifDB) Search(queries []string,
 		return nil, err
 	}
 
+	err = addTempAliases(tx, queries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := readSchema(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filterArgs, err := addTempFilter(tx, filter, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
 	log.Debug().Msgf("queries inserted")
 
 	// for full text search, we append wildcard to search term
@@ -449,7 +712,7 @@ func (mdb *MotifDB) Search(queries []string,
 	// 	sql.Named("id", search),
 	// 	sql.Named("q", q))
 
-	rows, err := tx.Query(SearchNumRecordsSql)
+	rows, err := tx.Query(schema.filterSql(SearchNumRecordsSql), filterArgs...)
 
 	// records in total
 
@@ -475,6 +738,16 @@ func (mdb *MotifDB) Search(queries []string,
 
 	paging.Pages = (result.Total + paging.PageSize - 1) / paging.PageSize
 
+	if result.Total == 0 {
+		result.Suggestions, err = mdb.Suggest(queries, MaxSuggestions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &result, nil
+	}
+
 	// rows, err := tx.Query(SearchSql,
 	// 	sql.Named("id", search),
 	// 	sql.Named("q", q),
@@ -482,10 +755,10 @@ func (mdb *MotifDB) Search(queries []string,
 	// 	sql.Named("limit", pageSize),
 	// )
 
-	rows, err = tx.Query(SearchSql,
+	rows, err = tx.Query(schema.filterSql(SearchSql), append(filterArgs,
 		sql.Named("offset", paging.PageSize*(paging.Page-1)),
 		sql.Named("limit", paging.PageSize),
-	)
+	)...)
 
 	if err != nil {
 		return nil, err
@@ -493,12 +766,25 @@ func (mdb *MotifDB) Search(queries []string,
 
 	defer rows.Close()
 
-	return mdb.processRows(tx, rows, revComp, &result)
+	_, err = mdb.processRows(tx, rows, revComp, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = addPageAliases(tx, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
 }
 
-// More complex boolean search
+// More complex boolean search, filtered as in Search
 func (mdb *MotifDB) BoolSearch(q string,
 	datasets []string,
+	filter *MotifFilter,
 	paging *Paging,
 	revComp bool) (*MotifSearchResult, error) {
 
@@ -542,12 +828,26 @@ func (mdb *MotifDB) BoolSearch(q string,
 		return nil, err
 	}
 
+	schema, err := readSchema(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filterArgs, err := addTempFilter(tx, filter, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
 	tree, err := query.SqlBoolTree(q)
 
 	if err != nil {
 		return nil, err
 	}
 
+	terms := make([]string, 0, 10)
+
 	motifIdWhere, err := query.SqlBoolQueryFromTree(tree, func(placeholderIndex int, value string, addParens bool) string {
 		// for slqlite
 		ph := query.IndexedParam(placeholderIndex)
@@ -556,8 +856,15 @@ func (mdb *MotifDB) BoolSearch(q string,
 		// 	return "(m.id NOT LIKE " + ph + " AND m.motif_id NOT LIKE " + ph + " AND m.motif_name NOT LIKE " + ph + ")"
 		// }
 
+		// terms are also matched against the aliases of genes, which
+		// are looked up once the tree has been walked
+		alias := fmt.Sprintf(":alias%d", len(terms))
+		terms = append(terms, value)
+
 		// we use like even for exact matches to allow for case insensitivity
-		return query.AddParens("m.public_id = "+ph+" OR m.motif_id LIKE "+ph+" OR m.motif_name LIKE "+ph, addParens)
+		return query.AddParens("m.public_id = "+ph+" OR m.motif_id LIKE "+ph+" OR m.motif_name LIKE "+ph+
+			" OR m.id IN (SELECT amg.motif_id FROM motif_genes amg JOIN genes ag ON amg.gene_id = ag.id"+
+			" JOIN temp_aliases ta ON LOWER(ag.name) = ta.alias WHERE ta.query = "+alias+")", addParens)
 
 	})
 
@@ -582,18 +889,28 @@ func (mdb *MotifDB) BoolSearch(q string,
 	motifIdSql := motifIdWhere.Sql
 	datasetIdSql := datasetIdWhere.Sql
 
-	args := []any{sql.Named("limit", paging.PageSize),
-		sql.Named("offset", paging.PageSize*(paging.Page-1))}
+	args := append(filterArgs, sql.Named("limit", paging.PageSize),
+		sql.Named("offset", paging.PageSize*(paging.Page-1)))
 
 	args = append(args, query.IndexedNamedArgs(motifIdWhere.Args)...)
 
+	for i, term := range terms {
+		args = append(args, sql.Named(fmt.Sprintf("alias%d", i), term))
+	}
+
+	err = addTempAliases(tx, terms)
+
+	if err != nil {
+		return nil, err
+	}
+
 	// append query args as named parameters to match
 
 	// countSql := fmt.Sprintf(BoolCountSql,
 	// 	motifIdSql,
 	// 	datasetIdSql)
 
-	query := strings.Replace(BoolCountSql, "<<MOTIFS>>", motifIdSql, 1)
+	query := strings.Replace(schema.filterSql(BoolCountSql), "<<MOTIFS>>", motifIdSql, 1)
 	query = strings.Replace(query, "<<DATASETS>>", datasetIdSql, 1)
 
 	//log.Debug().Msgf("count sql: %s", countSql)
@@ -625,7 +942,7 @@ func (mdb *MotifDB) BoolSearch(q string,
 	// calculate total pages
 	paging.Pages = (result.Total + paging.PageSize - 1) / paging.PageSize
 
-	query = strings.Replace(BoolSearchSql, "<<MOTIFS>>", motifIdSql, 1)
+	query = strings.Replace(schema.filterSql(BoolSearchSql), "<<MOTIFS>>", motifIdSql, 1)
 	query = strings.Replace(query, "<<DATASETS>>", datasetIdSql, 1)
 
 	//log.Debug().Msgf("search sql: %s", searchSql)
@@ -640,89 +957,83 @@ func (mdb *MotifDB) BoolSearch(q string,
 
 	defer rows.Close()
 
-	return mdb.processRows(tx, rows, revComp, &result)
+	_, err = mdb.processRows(tx, rows, revComp, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = addPageAliases(tx, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
 }
 
-// both search methods use this to process rows and fetch weights
+// search and selection use this to turn rows of motifs into
+// motifs with their genes and weights
 func (mdb *MotifDB) processRows(
 	tx *sql.Tx,
 	rows *sql.Rows,
 	revComp bool,
 	result *MotifSearchResult) (*MotifSearchResult, error) {
-	var gene string
-	// we ignore dataset name here since we fetch it in the main query
-	// but it is part of the query for sorting
-	//var datasetName string
 
-	var currentMotif *Motif = nil
+	page := make([]*pageMotif, 0, 100)
 
 	for rows.Next() {
-		var motif Motif
-		motif.Dataset = &db.Entity{}
+		motif := Motif{Dataset: &db.Entity{}}
+
+		var id int
 
-		err := rows.Scan(&motif.Dataset.PublicId,
+		err := rows.Scan(&id,
+			&motif.Dataset.PublicId,
 			&motif.Dataset.Name,
 			&motif.PublicId,
 			&motif.MotifId,
-			&motif.Name,
-			&gene)
-
-		log.Debug().Msgf("processing motif: %v", motif)
+			&motif.Name)
 
 		if err != nil {
 			return nil, err
 		}
 
-		if currentMotif == nil || motif.PublicId != currentMotif.PublicId {
-			// new motif, create a new Motif object
-			currentMotif = &motif
-			currentMotif.Genes = make([]string, 0, 10)
-			currentMotif.Weights = make([][]float64, 0, 20)
-
-			// Add the motifs weights
-			weightRows, err := tx.Query(WeightsSql,
-				sql.Named("id", currentMotif.PublicId))
-
-			if err != nil {
-				return nil, err
-			}
-
-			defer weightRows.Close()
+		page = append(page, &pageMotif{id: id, motif: &motif})
+	}
 
-			var a, c, g, t float64
+	err := rows.Err()
 
-			for weightRows.Next() {
-				err := weightRows.Scan(&a, &c, &g, &t)
+	if err != nil {
+		return nil, err
+	}
 
-				if err != nil {
-					return nil, err
-				}
+	// finish with the page rows before running the bulk queries
+	rows.Close()
 
-				currentMotif.Weights = append(currentMotif.Weights, []float64{a, c, g, t})
-			}
+	return finishPage(tx, page, revComp, result)
+}
 
-			// weight are stored as a string of floats in database
-			// which we can parse as json
-			//json.Unmarshal([]byte(weights), &motif.Weights)
+// add genes and weights to a page of motifs and append them to
+// the result
+func finishPage(tx *sql.Tx,
+	page []*pageMotif,
+	revComp bool,
+	result *MotifSearchResult) (*MotifSearchResult, error) {
 
-			// reverse position order
-			if revComp {
-				// reverse order of weights
-				slices.Reverse(currentMotif.Weights)
+	err := addGenesAndWeights(tx, page, true)
 
-				// reverse order of values in each position
-				// to complement so A becomes T and C becomes G
-				for _, pw := range currentMotif.Weights {
-					slices.Reverse(pw)
-				}
-			}
+	if err != nil {
+		return nil, err
+	}
 
-			result.Motifs = append(result.Motifs, currentMotif)
+	for _, pm := range page {
+		// reverse position order
+		if revComp {
+			RevCompWeights(pm.motif.Weights)
+			RevCompWeights(pm.motif.Counts)
 		}
 
-		// Add the genes
-		currentMotif.Genes = append(currentMotif.Genes, gene)
-
+		result.Motifs = append(result.Motifs, pm.motif)
 	}
 
 	// if useCache {
@@ -733,92 +1044,816 @@ func (mdb *MotifDB) processRows(
 
 }
 
-type MotifToGene struct {
-	Q     string   `json:"q"`
-	Genes []string `json:"genes"`
-	Id    int      `json:"-"`
+// a motif along with its internal id for bulk lookups
+type pageMotif struct {
+	id    int
+	motif *Motif
 }
 
-func (mdb *MotifDB) MotifsToGenes(ids []string) ([]*MotifToGene, error) {
+// addGenesAndWeights fills in the species, nsites, E-value, URL,
+// families, HOCOMOCO info, genes, and optionally weights and counts, of
+// a page of motifs using one query for each
+// rather than one per motif. The same motif may appear more than
+// once, e.g. under different genes, in which case each copy is
+// filled in.
+func addGenesAndWeights(tx *sql.Tx, page []*pageMotif, weights bool) error {
+	index := make(map[int][]*Motif, len(page))
 
-	// rows, err := mdb.db.Query(SearchSql,
-	// 	sql.Named("id", search),
-	// 	sql.Named("q", fmt.Sprintf("%%%s%%", search)))
+	for _, pm := range page {
+		pm.motif.Genes = make([]string, 0, 10)
 
-	tx, err := mdb.db.Begin()
+		if weights {
+			pm.motif.Weights = make([][]float64, 0, 20)
+		}
 
-	if err != nil {
-		return nil, err
+		index[pm.id] = append(index[pm.id], pm.motif)
 	}
 
-	defer tx.Rollback()
-
-	log.Debug().Msgf("creating temp table")
+	if len(page) == 0 {
+		return nil
+	}
 
-	_, err = tx.Exec(TempQueriesTableSql)
+	_, err := tx.Exec(TempPageMotifsTableSql)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	stmt, err := tx.Prepare(InsertTempQueriesSql)
+	// the table may still hold a previous page in this transaction
+	_, err = tx.Exec(ClearTempPageMotifsSql)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for _, q := range ids {
-		_, err := stmt.Exec(sql.Named("query", q),
-			sql.Named("search", q+"%"))
+	stmt, err := tx.Prepare(InsertTempPageMotifSql)
 
-		log.Debug().Msgf("motif insert temp %s", q)
+	if err != nil {
+		return err
+	}
+
+	for id := range index {
+		_, err := stmt.Exec(sql.Named("id", id))
 
 		if err != nil {
-			log.Debug().Msgf("motif insert temp %s", err)
-			return nil, err
+			stmt.Close()
+			return err
 		}
 	}
 
 	stmt.Close()
 
-	rows, err := tx.Query(MotifsToGenes)
+	schema, err := readSchema(tx)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	var rows *sql.Rows
+	var id int
 
-	ret := make([]*MotifToGene, 0, 20)
+	// older databases have no species, metadata or HOCOMOCO ids
+	if schema.builder {
+		rows, err = tx.Query(PageSpeciesSql)
 
-	var id int
-	var query string
-	var publicId string
-	var name string
+		if err != nil {
+			return err
+		}
 
-	var currentGene *MotifToGene = nil
+		defer rows.Close()
 
-	for rows.Next() {
+		for rows.Next() {
+			var species Species
 
-		err := rows.Scan(&id, &query, &publicId, &name)
+			err := rows.Scan(&id, &species.TaxonId, &species.Name)
+
+			if err != nil {
+				return err
+			}
+
+			for _, motif := range index[id] {
+				motif.Species = &species
+			}
+		}
+
+		err = rows.Err()
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		log.Debug().Msgf("processing motif to gene: %v, id: %d, query: %s", name, id, query)
+		rows.Close()
 
-		if currentGene == nil || id != currentGene.Id {
-			currentGene = &MotifToGene{Id: id, Q: query, Genes: make([]string, 0, 10)}
-			ret = append(ret, currentGene)
+		rows, err = tx.Query(PageMotifInfoSql)
+
+		if err != nil {
+			return err
 		}
 
-		currentGene.Genes = append(currentGene.Genes, name)
+		defer rows.Close()
 
-	}
+		for rows.Next() {
+			var nsites, evalue float64
+			var url string
 
-	return ret, nil
-	//return mdb.processRows(tx, rows, revComp, &result)
+			err := rows.Scan(&id, &nsites, &evalue, &url)
+
+			if err != nil {
+				return err
+			}
+
+			for _, motif := range index[id] {
+				motif.NSites = nsites
+				motif.EValue = evalue
+				motif.URL = url
+			}
+		}
+
+		err = rows.Err()
+
+		if err != nil {
+			return err
+		}
+
+		rows.Close()
+	}
+
+	if schema.families {
+		rows, err = tx.Query(PageFamiliesSql)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			family, err := scanFamily(rows, &id)
+
+			if err != nil {
+				return err
+			}
+
+			for _, motif := range index[id] {
+				motif.Families = append(motif.Families, family)
+			}
+		}
+
+		err = rows.Err()
+
+		if err != nil {
+			return err
+		}
+
+		rows.Close()
+	}
+
+	if schema.builder {
+		rows, err = tx.Query(PageHocomocoSql)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			info, err := scanHocomoco(rows, &id)
+
+			if err != nil {
+				return err
+			}
+
+			for _, motif := range index[id] {
+				motif.Hocomoco = info
+			}
+		}
+
+		err = rows.Err()
+
+		if err != nil {
+			return err
+		}
+
+		rows.Close()
+	}
+
+	rows, err = tx.Query(PageGenesSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var gene string
+
+	for rows.Next() {
+		err := rows.Scan(&id, &gene)
+
+		if err != nil {
+			return err
+		}
+
+		for _, motif := range index[id] {
+			motif.Genes = append(motif.Genes, gene)
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return err
+	}
+
+	rows.Close()
+
+	if !weights {
+		return nil
+	}
+
+	weightsSql := PageWeightsSql
+
+	if !schema.builder {
+		weightsSql = LegacyPageWeightsSql
+	}
+
+	rows, err = tx.Query(weightsSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		pw, pc, err := scanWeights(rows, &id)
+
+		if err != nil {
+			return err
+		}
+
+		// copies are reverse complemented separately so cannot
+		// share rows
+		for _, motif := range index[id] {
+			motif.Weights = append(motif.Weights, slices.Clone(pw))
+
+			if pc != nil {
+				motif.Counts = append(motif.Counts, slices.Clone(pc))
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+// RevCompWeights reverse complements a weight matrix in place so
+// that it describes the motif on the opposite strand
+func RevCompWeights(weights [][]float64) {
+	// reverse order of weights
+	slices.Reverse(weights)
+
+	// reverse order of values in each position
+	// to complement so A becomes T and C becomes G
+	for _, pw := range weights {
+		slices.Reverse(pw)
+	}
+}
+
+// Which motifs to use for analyses such as scanning
+type MotifSelection struct {
+	// motif public ids
+	Ids []string `json:"ids"`
+	// dataset public ids
+	Datasets []string `json:"datasets"`
+	// search terms matched as in Search
+	Queries []string `json:"queries"`
+	// more motifs than this is an error, no limit if 0
+	MaxMotifs int `json:"-"`
+}
+
+// SelectMotifs returns the motifs, with weights, chosen by a selection.
+// Motifs are returned in the forward orientation.
+func (mdb *MotifDB) SelectMotifs(selection *MotifSelection) ([]*Motif, error) {
+	tx, err := mdb.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(TempMotifIdsTableSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(InsertTempMotifIdSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range selection.Ids {
+		_, err := stmt.Exec(sql.Named("id", id))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stmt.Close()
+
+	_, err = tx.Exec(TempQueriesTableSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err = tx.Prepare(InsertTempQueriesSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range selection.Queries {
+		_, err := stmt.Exec(sql.Named("query", q),
+			sql.Named("search", q+"%"))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stmt.Close()
+
+	err = addTempDatasets(tx, selection.Datasets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(SelectMotifsSql,
+		sql.Named("use_sets", len(selection.Datasets) > 0 || len(selection.Queries) > 0),
+		sql.Named("all_datasets", len(selection.Datasets) == 0),
+		sql.Named("all_motifs", len(selection.Queries) == 0))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	result := MotifSearchResult{Motifs: make([]*Motif, 0, 100)}
+
+	_, err = mdb.processRows(tx, rows, false, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if selection.MaxMotifs > 0 && len(result.Motifs) > selection.MaxMotifs {
+		return nil, fmt.Errorf("%w: %d, at most %d can be used",
+			ErrTooManyMotifs,
+			len(result.Motifs),
+			selection.MaxMotifs)
+	}
+
+	return result.Motifs, nil
+}
+
+// Motif fetches one motif by public id with its dataset, genes,
+// weights and metadata
+func (mdb *MotifDB) Motif(publicId string) (*Motif, error) {
+	schema, err := readSchema(mdb.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	motifSql := MotifSql
+
+	if !schema.builder {
+		motifSql = LegacyMotifSql
+	}
+
+	motif := Motif{Dataset: &db.Entity{}}
+
+	var species Species
+
+	err = mdb.db.QueryRow(motifSql, sql.Named("id", publicId)).
+		Scan(&motif.Dataset.PublicId,
+			&motif.Dataset.Name,
+			&motif.PublicId,
+			&motif.MotifId,
+			&motif.Name,
+			&motif.Length,
+			&species.TaxonId,
+			&species.Name,
+			&motif.NSites,
+			&motif.EValue,
+			&motif.URL)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrMotifNotFound, publicId)
+		}
+
+		return nil, err
+	}
+
+	// 0 means the species is unknown
+	if species.TaxonId != 0 {
+		motif.Species = &species
+	}
+
+	motif.Genes = make([]string, 0, 10)
+
+	rows, err := mdb.db.Query(MotifGenesSql, sql.Named("id", publicId))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var gene string
+
+		err := rows.Scan(&gene)
+
+		if err != nil {
+			return nil, err
+		}
+
+		motif.Genes = append(motif.Genes, gene)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	if schema.families {
+		rows, err = mdb.db.Query(MotifFamiliesSql, sql.Named("id", publicId))
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			family, err := scanFamily(rows)
+
+			if err != nil {
+				return nil, err
+			}
+
+			motif.Families = append(motif.Families, family)
+		}
+
+		err = rows.Err()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if schema.builder {
+		motif.Hocomoco, err = scanHocomoco(mdb.db.QueryRow(MotifHocomocoSql, sql.Named("id", publicId)))
+
+		// most motifs are not from HOCOMOCO
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	motif.Weights, motif.Counts, err = motifWeights(mdb.db, publicId, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &motif, nil
+}
+
+type MotifToGene struct {
+	Q     string   `json:"q"`
+	Genes []string `json:"genes"`
+	// genes the query is an alias of, if any
+	Aliases []string `json:"aliases,omitempty"`
+	Id      int      `json:"-"`
+}
+
+func (mdb *MotifDB) MotifsToGenes(ids []string) ([]*MotifToGene, error) {
+
+	// rows, err := mdb.db.Query(SearchSql,
+	// 	sql.Named("id", search),
+	// 	sql.Named("q", fmt.Sprintf("%%%s%%", search)))
+
+	tx, err := mdb.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	log.Debug().Msgf("creating temp table")
+
+	_, err = tx.Exec(TempQueriesTableSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(InsertTempQueriesSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range ids {
+		_, err := stmt.Exec(sql.Named("query", q),
+			sql.Named("search", q+"%"))
+
+		log.Debug().Msgf("motif insert temp %s", q)
+
+		if err != nil {
+			log.Debug().Msgf("motif insert temp %s", err)
+			return nil, err
+		}
+	}
+
+	stmt.Close()
+
+	err = addTempAliases(tx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := queryAliases(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(MotifsToGenes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ret := make([]*MotifToGene, 0, 20)
+
+	var id int
+	var query string
+	var publicId string
+	var name string
+
+	var currentGene *MotifToGene = nil
+
+	for rows.Next() {
+
+		err := rows.Scan(&id, &query, &publicId, &name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		log.Debug().Msgf("processing motif to gene: %v, id: %d, query: %s", name, id, query)
+
+		if currentGene == nil || id != currentGene.Id {
+			currentGene = &MotifToGene{Id: id, Q: query, Genes: make([]string, 0, 10), Aliases: aliases[query]}
+			ret = append(ret, currentGene)
+		}
+
+		currentGene.Genes = append(currentGene.Genes, name)
+
+	}
+
+	return ret, nil
+	//return mdb.processRows(tx, rows, revComp, &result)
+}
+
+type GeneToMotifs struct {
+	// the gene as it was queried
+	Gene string `json:"gene"`
+	// empty if the gene has no motifs
+	Motifs []*Motif `json:"motifs"`
+}
+
+// GenesToMotifs finds the motifs of each gene, ignoring case, in the
+// given datasets, or all datasets if none are given, that pass the
+// filter. Since case is ignored a human symbol also finds the motifs
+// of its mouse ortholog when they share a symbol, e.g. SOX2 and Sox2.
+// Every gene is reported in the order given, even if it has no
+// motifs. Weights are only fetched if requested.
+func (mdb *MotifDB) GenesToMotifs(genes []string, datasets []string, filter *MotifFilter, weights bool) ([]*GeneToMotifs, error) {
+	ret := make([]*GeneToMotifs, 0, len(genes))
+
+	// genes may be repeated with different case so report each once
+	index := make(map[string]*GeneToMotifs, len(genes))
+
+	for _, gene := range genes {
+		gene = strings.TrimSpace(gene)
+		key := strings.ToLower(gene)
+
+		if key == "" {
+			continue
+		}
+
+		if _, found := index[key]; found {
+			continue
+		}
+
+		entry := GeneToMotifs{Gene: gene, Motifs: make([]*Motif, 0, 10)}
+		index[key] = &entry
+		ret = append(ret, &entry)
+	}
+
+	if len(ret) == 0 {
+		return ret, nil
+	}
+
+	tx, err := mdb.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(TempQueriesTableSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(InsertTempQueriesSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range ret {
+		_, err := stmt.Exec(sql.Named("query", entry.Gene),
+			sql.Named("search", strings.ToLower(entry.Gene)))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stmt.Close()
+
+	err = addTempDatasets(tx, datasets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := readSchema(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filterArgs, err := addTempFilter(tx, filter, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(schema.filterSql(GenesToMotifsSql),
+		append(filterArgs, sql.Named("all_datasets", len(datasets) == 0))...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	page := make([]*pageMotif, 0, 100)
+
+	var key string
+
+	for rows.Next() {
+		motif := Motif{Dataset: &db.Entity{}}
+
+		var id int
+
+		err := rows.Scan(&key,
+			&id,
+			&motif.Dataset.PublicId,
+			&motif.Dataset.Name,
+			&motif.PublicId,
+			&motif.MotifId,
+			&motif.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry := index[key]
+		entry.Motifs = append(entry.Motifs, &motif)
+
+		page = append(page, &pageMotif{id: id, motif: &motif})
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	err = addGenesAndWeights(tx, page, weights)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// either a database or a transaction
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// weights of one motif in position order, and its counts if it has
+// them
+func motifWeights(q querier, publicId string, schema *schema) ([][]float64, [][]float64, error) {
+	query := WeightsSql
+
+	if !schema.builder {
+		query = LegacyWeightsSql
+	}
+
+	rows, err := q.Query(query, sql.Named("id", publicId))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	weights := make([][]float64, 0, 20)
+	var counts [][]float64
+
+	for rows.Next() {
+		pw, pc, err := scanWeights(rows)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		weights = append(weights, pw)
+
+		if pc != nil {
+			counts = append(counts, pc)
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return weights, counts, nil
+}
+
+// scan a, c, g, t weights then counts after any extra columns. Counts
+// are nil if the motif has none.
+func scanWeights(scanner interface{ Scan(...any) error }, extra ...any) ([]float64, []float64, error) {
+	pw := make([]float64, 4)
+	var pc [4]sql.NullFloat64
+
+	dest := append(extra, &pw[0], &pw[1], &pw[2], &pw[3], &pc[0], &pc[1], &pc[2], &pc[3])
+
+	err := scanner.Scan(dest...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !pc[0].Valid {
+		return pw, nil, nil
+	}
+
+	return pw, []float64{pc[0].Float64, pc[1].Float64, pc[2].Float64, pc[3].Float64}, nil
 }
 
 func addTempDatasets(tx *sql.Tx, datasets []string) error {
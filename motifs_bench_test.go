@@ -0,0 +1,38 @@
+package motifs
+
+import (
+	"fmt"
+	"testing"
+)
+
+// latency of a search page against the fixture database for
+// increasing page sizes
+func BenchmarkSearch(b *testing.B) {
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	datasets, err := db.Datasets()
+
+	if err != nil {
+		b.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	for _, pageSize := range []int{10, 50, 100} {
+		b.Run(fmt.Sprintf("page%d", pageSize), func(b *testing.B) {
+			for b.Loop() {
+				page := Paging{Page: 1, PageSize: pageSize}
+
+				_, err := db.Search([]string{"SOX"}, ids, nil, &page, false)
+
+				if err != nil {
+					b.Fatalf("%s", err)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package motifs
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -14,7 +16,7 @@ func TestGenes(t *testing.T) {
 		PageSize: 100,
 	}
 
-	res, err := db.Search([]string{"ADNP_IRX_SIX_ZHX.p2"}, []string{}, &page, false)
+	res, err := db.Search([]string{"ADNP_IRX_SIX_ZHX.p2"}, []string{}, nil, &page, false)
 
 	if err != nil {
 		fmt.Printf("%s", err)
@@ -25,3 +27,192 @@ func TestGenes(t *testing.T) {
 	}
 
 }
+
+func TestMotif(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	ms, err := db.SelectMotifs(&MotifSelection{Queries: []string{"ADNP_IRX_SIX_ZHX.p2"}})
+
+	if err != nil || len(ms) == 0 {
+		t.Fatalf("select failed: %v", err)
+	}
+
+	found := ms[0]
+
+	motif, err := db.Motif(found.PublicId)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if motif.MotifId != found.MotifId || motif.Length != len(found.Weights) || len(motif.Weights) != motif.Length {
+		t.Fatalf("motif %v does not match search result %v", motif, found)
+	}
+
+	// MEME header values are kept
+	if motif.NSites <= 0 || motif.NSites != found.NSites {
+		t.Fatalf("motif %s has nsites %f, search result %f", motif.MotifId, motif.NSites, found.NSites)
+	}
+
+	_, err = db.Motif("unknown")
+
+	if !errors.Is(err, ErrMotifNotFound) {
+		t.Fatalf("expected not found error not %v", err)
+	}
+}
+
+func TestGenesToMotifs(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	res, err := db.GenesToMotifs([]string{"adnp", "ADNP", "NOTAGENE"}, []string{}, nil, true)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// repeated genes are reported once and missing genes are kept
+	if len(res) != 2 || len(res[0].Motifs) == 0 || len(res[1].Motifs) != 0 {
+		t.Fatalf("unexpected result %v", res)
+	}
+
+	for _, motif := range res[0].Motifs {
+		if len(motif.Weights) == 0 || motif.Dataset == nil {
+			t.Fatalf("motif %s is missing weights or dataset", motif.MotifId)
+		}
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	datasets, err := db.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	page := Paging{Page: 1, PageSize: 50}
+
+	res, err := db.Search([]string{"SOX"}, ids, nil, &page, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// pages count motifs, not motif genes
+	if res.Total > 50 && len(res.Motifs) != 50 {
+		t.Fatalf("expected a full page not %d of %d motifs", len(res.Motifs), res.Total)
+	}
+
+	for _, motif := range res.Motifs {
+		if len(motif.Weights) == 0 || len(motif.Genes) == 0 {
+			t.Fatalf("motif %s is missing weights or genes", motif.MotifId)
+		}
+	}
+}
+
+func TestFullTextSearch(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	datasets, err := db.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	page := Paging{Page: 1, PageSize: 20}
+
+	// should find composite motifs such as Ahr::Arnt
+	res, err := db.FullTextSearch("arnt", ids, nil, &page, false)
+
+	if errors.Is(err, ErrNoFullTextIndex) {
+		t.Skip(err)
+	}
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(res.Motifs) == 0 {
+		t.Fatalf("no motifs found")
+	}
+
+	composite := false
+
+	for _, motif := range res.Motifs {
+		if motif.Match == nil || len(motif.Weights) == 0 {
+			t.Fatalf("motif %s is missing match or weights", motif.MotifId)
+		}
+
+		if strings.Contains(motif.Name, "::") {
+			composite = true
+		}
+	}
+
+	if !composite {
+		t.Fatalf("expected a composite motif")
+	}
+}
+
+func TestSearchSpecies(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	datasets, err := db.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	page := Paging{Page: 1, PageSize: 100}
+
+	all, err := db.Search([]string{"SOX"}, ids, nil, &page, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	res, err := db.Search([]string{"SOX"}, ids, &MotifFilter{Species: []string{"mouse"}}, &page, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if res.Total == 0 || res.Total >= all.Total {
+		t.Fatalf("expected some but not all of %d motifs, found %d", all.Total, res.Total)
+	}
+
+	for _, motif := range res.Motifs {
+		if motif.Species == nil || motif.Species.TaxonId != Mouse.TaxonId {
+			t.Fatalf("motif %s is not from mouse", motif.MotifId)
+		}
+	}
+
+	_, err = db.Search([]string{"SOX"}, ids, &MotifFilter{Species: []string{"martian"}}, &page, false)
+
+	if !errors.Is(err, ErrUnknownSpecies) {
+		t.Fatalf("expected unknown species not %v", err)
+	}
+}
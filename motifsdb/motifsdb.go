@@ -29,20 +29,75 @@ func Datasets() ([]*motifs.Dataset, error) {
 	return instance.Datasets()
 }
 
+func DatasetBackground(publicId string) ([]float64, error) {
+	return instance.DatasetBackground(publicId)
+}
+
 func Search(queries []string,
 	datasets []string,
+	filter *motifs.MotifFilter,
 	page *motifs.Paging,
 	revComp bool) (*motifs.MotifSearchResult, error) {
-	return instance.Search(queries, datasets, page, revComp)
+	return instance.Search(queries, datasets, filter, page, revComp)
 }
 
 func BoolSearch(q string,
 	datasets []string,
+	filter *motifs.MotifFilter,
 	page *motifs.Paging,
 	revComp bool) (*motifs.MotifSearchResult, error) {
-	return instance.BoolSearch(q, datasets, page, revComp)
+	return instance.BoolSearch(q, datasets, filter, page, revComp)
 }
 
 func MotifsToGenes(ids []string) ([]*motifs.MotifToGene, error) {
 	return instance.MotifsToGenes(ids)
 }
+
+func Families() ([]*motifs.Family, error) {
+	return instance.Families()
+}
+
+func Motif(publicId string) (*motifs.Motif, error) {
+	return instance.Motif(publicId)
+}
+
+func GenesToMotifs(genes []string, datasets []string, filter *motifs.MotifFilter, weights bool) ([]*motifs.GeneToMotifs, error) {
+	return instance.GenesToMotifs(genes, datasets, filter, weights)
+}
+
+func FullTextSearch(q string,
+	datasets []string,
+	filter *motifs.MotifFilter,
+	page *motifs.Paging,
+	revComp bool) (*motifs.MotifSearchResult, error) {
+	return instance.FullTextSearch(q, datasets, filter, page, revComp)
+}
+
+func SelectMotifs(selection *motifs.MotifSelection) ([]*motifs.Motif, error) {
+	return instance.SelectMotifs(selection)
+}
+
+func Scan(seqs []*motifs.Sequence,
+	selection *motifs.MotifSelection,
+	opts *motifs.ScanOptions) ([]*motifs.ScanHit, error) {
+	return instance.Scan(seqs, selection, opts)
+}
+
+func Enrich(targets []*motifs.Sequence,
+	background []*motifs.Sequence,
+	selection *motifs.MotifSelection,
+	opts *motifs.EnrichOptions) ([]*motifs.MotifEnrichment, error) {
+	return instance.Enrich(targets, background, selection, opts)
+}
+
+func CentralEnrichment(seqs []*motifs.Sequence,
+	selection *motifs.MotifSelection,
+	opts *motifs.CentralOptions) ([]*motifs.MotifCentrality, error) {
+	return instance.CentralEnrichment(seqs, selection, opts)
+}
+
+func CompareMotif(query *motifs.Motif,
+	selection *motifs.MotifSelection,
+	opts *motifs.CompareOptions) ([]*motifs.MotifMatch, error) {
+	return instance.CompareMotif(query, selection, opts)
+}
@@ -0,0 +1,369 @@
+package motifs
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"slices"
+	"sync"
+)
+
+type (
+	// Distribution of PWM scores over random sequences drawn from
+	// the background, used to convert scores to p-values and back.
+	//
+	// As in TFM-PVALUE, scores are discretized and the distribution
+	// computed exactly by dynamic programming. Rounding means a
+	// discrete score only bounds the real score, so each query starts
+	// at a coarse granularity and is refined until the bounds agree.
+	// Refinements are computed on demand and kept for reuse.
+	ScoreDistribution struct {
+		pwm *PWM
+		// sum of column minimums, which discrete scores are
+		// relative to
+		offset float64
+		// total range of achievable scores
+		span   float64
+		lock   sync.Mutex
+		levels []*scoreLevel
+	}
+
+	// Distribution at one granularity
+	scoreLevel struct {
+		// size of one discrete score step
+		eps    float64
+		matrix [][]int
+		// tail[k] is the probability of a discrete score >= k
+		tail []float64
+	}
+
+	// Bounded least recently used cache of score distributions
+	// keyed by the contents of the matrix and its background so that
+	// repeated scans do not recompute them
+	ScoreDistributionCache struct {
+		lock    sync.Mutex
+		size    int
+		order   *list.List
+		entries map[uint64]*list.Element
+	}
+
+	cacheEntry struct {
+		key  uint64
+		dist *ScoreDistribution
+	}
+)
+
+const (
+	// number of discrete steps across the score range at the
+	// coarsest level
+	initialScoreBins = 1000
+
+	// each refinement makes steps this many times smaller
+	scoreBinRefinement = 10
+
+	// finest granularity we allow, which limits memory use
+	maxScoreBins = 100000
+
+	// p-values whose bounds are within this relative difference
+	// are considered converged
+	PValueTolerance = 1e-3
+
+	// how far a score threshold can be from the true value
+	ThresholdTolerance = 1e-2
+
+	DefaultDistributionCacheSize = 500
+)
+
+var (
+	ErrPValue = errors.New("p-value must be between 0 and 1")
+
+	distributionCache = NewScoreDistributionCache(DefaultDistributionCacheSize)
+)
+
+func NewScoreDistribution(pwm *PWM) *ScoreDistribution {
+	sd := ScoreDistribution{pwm: pwm,
+		offset: pwm.MinScore,
+		span:   pwm.MaxScore - pwm.MinScore,
+		levels: make([]*scoreLevel, 0, 3)}
+
+	return &sd
+}
+
+// ScoreDistribution of the motif's PWM, reusing a cached copy if the
+// same matrix and background have been seen before.
+func (motif *Motif) ScoreDistribution(opts *PWMOptions) (*ScoreDistribution, error) {
+	pwm, err := motif.PWM(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return distributionCache.Get(pwm), nil
+}
+
+func (sd *ScoreDistribution) PWM() *PWM {
+	return sd.pwm
+}
+
+func (sd *ScoreDistribution) numLevels() int {
+	if sd.span <= 0 {
+		return 1
+	}
+
+	n := 1
+
+	for bins := initialScoreBins; bins < maxScoreBins; bins *= scoreBinRefinement {
+		n++
+	}
+
+	return n
+}
+
+// distribution at a given refinement, computed if necessary
+func (sd *ScoreDistribution) level(l int) *scoreLevel {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	for len(sd.levels) <= l {
+		bins := initialScoreBins
+
+		for range len(sd.levels) {
+			bins *= scoreBinRefinement
+		}
+
+		eps := 1.0
+
+		if sd.span > 0 {
+			eps = sd.span / float64(bins)
+		}
+
+		sd.levels = append(sd.levels, newScoreLevel(sd.pwm, eps))
+	}
+
+	return sd.levels[l]
+}
+
+func newScoreLevel(pwm *PWM, eps float64) *scoreLevel {
+	matrix := make([][]int, len(pwm.Matrix))
+	maxSum := 0
+
+	for i, row := range pwm.Matrix {
+		colMin := slices.Min(row)
+		matrix[i] = make([]int, len(row))
+
+		for b, v := range row {
+			// flooring means a discrete score never overstates
+			// the real score
+			matrix[i][b] = int(math.Floor((v - colMin) / eps))
+		}
+
+		maxSum += slices.Max(matrix[i])
+	}
+
+	bg := pwm.Background
+
+	// probability of each discrete score
+	dist := make([]float64, maxSum+1)
+	dist[0] = 1
+	next := make([]float64, maxSum+1)
+	reach := 0
+
+	for _, row := range matrix {
+		clear(next)
+
+		for s := 0; s <= reach; s++ {
+			if dist[s] == 0 {
+				continue
+			}
+
+			for b, v := range row {
+				next[s+v] += dist[s] * bg[b]
+			}
+		}
+
+		reach += slices.Max(row)
+		dist, next = next, dist
+	}
+
+	tail := make([]float64, maxSum+2)
+
+	for s := maxSum; s >= 0; s-- {
+		tail[s] = tail[s+1] + dist[s]
+	}
+
+	return &scoreLevel{eps: eps, matrix: matrix, tail: tail}
+}
+
+// probability of a discrete score >= k
+func (l *scoreLevel) tailAt(k int) float64 {
+	if k <= 0 {
+		return 1
+	}
+
+	if k >= len(l.tail) {
+		return 0
+	}
+
+	return min(l.tail[k], 1)
+}
+
+// smallest discrete score whose tail probability is <= p
+func (l *scoreLevel) threshold(p float64) int {
+	// tail is decreasing so binary search for the first index
+	// where it drops to p
+	k, _ := slices.BinarySearchFunc(l.tail, p, func(t float64, p float64) int {
+		if t > p {
+			return -1
+		}
+
+		return 1
+	})
+
+	return k
+}
+
+// PValue is the probability that a random sequence scores at
+// least score.
+func (sd *ScoreDistribution) PValue(score float64) float64 {
+	if score <= sd.pwm.MinScore {
+		return 1
+	}
+
+	if score > sd.pwm.MaxScore+1e-9 {
+		return 0
+	}
+
+	w := len(sd.pwm.Matrix)
+	levels := sd.numLevels()
+	upper := 1.0
+
+	for i := range levels {
+		l := sd.level(i)
+
+		// a real score s has discrete score in
+		// ((s - offset) / eps - w, (s - offset) / eps]
+		k := int(math.Ceil((score-sd.offset)/l.eps - 1e-9))
+
+		lower := l.tailAt(k)
+		upper = l.tailAt(k - w)
+
+		if upper-lower <= PValueTolerance*upper {
+			break
+		}
+	}
+
+	// when we cannot refine further report the conservative
+	// bound
+	return upper
+}
+
+// scores below this bound cannot have a p-value <= p so we can
+// skip them without computing their p-value
+func (sd *ScoreDistribution) minScoreForPValue(p float64) float64 {
+	l := sd.level(0)
+
+	return sd.offset + l.eps*float64(l.threshold(p)-1)
+}
+
+// Threshold returns the lowest score whose p-value is at most p.
+// Discretization means this may overstate the true threshold, but
+// never understates it, by up to w + 1 steps of the finest level
+// used for a motif of width w. Levels are refined until that is
+// within ThresholdTolerance, but for long motifs or wide score ranges
+// the finest level stops short and the error can be up to
+// (w + 1) * (MaxScore - MinScore) / 100000. If even the best score is
+// not that significant, +Inf is returned.
+func (sd *ScoreDistribution) Threshold(p float64) (float64, error) {
+	if p < 0 || p > 1 || math.IsNaN(p) {
+		return 0, ErrPValue
+	}
+
+	if sd.PValue(sd.pwm.MaxScore) > p {
+		return math.Inf(1), nil
+	}
+
+	w := len(sd.pwm.Matrix)
+	levels := sd.numLevels()
+	threshold := sd.pwm.MaxScore
+
+	for i := range levels {
+		l := sd.level(i)
+
+		// the true threshold is above step t - 1 and at step t + w
+		// even the upper bound on the p-value is <= p
+		t := l.threshold(p)
+
+		threshold = min(sd.offset+l.eps*float64(t+w), sd.pwm.MaxScore)
+
+		if l.eps*float64(w+1) <= ThresholdTolerance {
+			break
+		}
+	}
+
+	return threshold, nil
+}
+
+func NewScoreDistributionCache(size int) *ScoreDistributionCache {
+	return &ScoreDistributionCache{size: max(size, 1),
+		order:   list.New(),
+		entries: make(map[uint64]*list.Element, size)}
+}
+
+// Get returns the distribution for a PWM, creating and caching it
+// if necessary
+func (cache *ScoreDistributionCache) Get(pwm *PWM) *ScoreDistribution {
+	key := pwmKey(pwm)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if e, found := cache.entries[key]; found {
+		cache.order.MoveToFront(e)
+		return e.Value.(*cacheEntry).dist
+	}
+
+	dist := NewScoreDistribution(pwm)
+
+	cache.entries[key] = cache.order.PushFront(&cacheEntry{key: key, dist: dist})
+
+	if cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	return dist
+}
+
+func (cache *ScoreDistributionCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.order.Len()
+}
+
+// hash of the matrix and background, which are all that determine
+// the distribution
+func pwmKey(pwm *PWM) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+
+	write := func(v float64) {
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+		h.Write(buf)
+	}
+
+	for _, row := range pwm.Matrix {
+		for _, v := range row {
+			write(v)
+		}
+	}
+
+	for _, v := range pwm.Background {
+		write(v)
+	}
+
+	return h.Sum64()
+}
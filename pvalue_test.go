@@ -0,0 +1,117 @@
+package motifs
+
+import (
+	"math"
+	"testing"
+)
+
+// p-values of every 4-mer by enumeration
+func enumeratePValues(pwm *PWM) func(score float64) float64 {
+	scores := make([]float64, 0, 256)
+	probs := make([]float64, 0, 256)
+
+	for k := range 256 {
+		kmer := []int8{int8(k >> 6 & 3), int8(k >> 4 & 3), int8(k >> 2 & 3), int8(k & 3)}
+		p := 1.0
+
+		for _, b := range kmer {
+			p *= pwm.Background[b]
+		}
+
+		scores = append(scores, pwm.Score(kmer))
+		probs = append(probs, p)
+	}
+
+	return func(score float64) float64 {
+		ret := 0.0
+
+		for i, s := range scores {
+			if s >= score-1e-9 {
+				ret += probs[i]
+			}
+		}
+
+		return ret
+	}
+}
+
+func TestPValue(t *testing.T) {
+	opts := NewPWMOptions()
+	opts.Background = []float64{0.3, 0.2, 0.2, 0.3}
+
+	pwm, err := testMotif().PWM(opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dist := NewScoreDistribution(pwm)
+	exact := enumeratePValues(pwm)
+
+	for _, window := range [][]int8{{2, 0, 3, 0}, {2, 0, 3, 1}, {0, 0, 3, 0}, {1, 1, 1, 1}} {
+		score := pwm.Score(window)
+		expected := exact(score)
+		p := dist.PValue(score)
+
+		if math.Abs(p-expected) > PValueTolerance*expected+1e-12 {
+			t.Fatalf("score %f: expected p-value %g, found %g", score, expected, p)
+		}
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	pwm, err := testMotif().PWM(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dist := NewScoreDistribution(pwm)
+
+	// the best 4-mer has p-value 1/256
+	threshold, _ := dist.Threshold(0.001)
+
+	if !math.IsInf(threshold, 1) {
+		t.Fatalf("expected no achievable threshold, found %f", threshold)
+	}
+
+	for _, p := range []float64{0.005, 0.01, 0.05, 0.2} {
+		threshold, err := dist.Threshold(p)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dist.PValue(threshold) > p*(1+PValueTolerance) {
+			t.Fatalf("threshold %f for %g has p-value %g", threshold, p, dist.PValue(threshold))
+		}
+	}
+
+	_, err = dist.Threshold(2)
+
+	if err == nil {
+		t.Fatal("expected p-value error")
+	}
+}
+
+func TestDistributionCache(t *testing.T) {
+	cache := NewScoreDistributionCache(2)
+
+	pwm, _ := testMotif().PWM(nil)
+
+	if cache.Get(pwm) != cache.Get(pwm) {
+		t.Fatal("expected cached distribution")
+	}
+
+	cache.Get(pwm.RevComp())
+
+	opts := NewPWMOptions()
+	opts.LogBase = math.E
+	other, _ := testMotif().PWM(opts)
+
+	cache.Get(other)
+
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 cached distributions, found %d", cache.Len())
+	}
+}
@@ -0,0 +1,408 @@
+package motifs
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+type (
+	// How pseudocounts are added to a motif's counts before
+	// converting to log odds so that zero entries can be scored
+	PseudocountMode string
+
+	PWMOptions struct {
+		// A, C, G, T frequencies of random sequence. Use
+		// UniformBackground, a dataset background from its
+		// MEME header or supply your own. Defaults to uniform
+		Background []float64 `json:"background"`
+
+		// defaults to fixed
+		Pseudocount PseudocountMode `json:"pseudocount"`
+
+		// total pseudocount for fixed mode, distributed according
+		// to the background
+		PseudocountWeight float64 `json:"pseudocountWeight"`
+
+		// prior counts for A, C, G, T in Dirichlet mode
+		Dirichlet []float64 `json:"dirichlet"`
+
+		// defaults to 2 so scores are in bits
+		LogBase float64 `json:"logBase"`
+	}
+
+	// Log odds position weight matrix for scoring sequences.
+	// Matrix columns are a, c, g, t
+	PWM struct {
+		Matrix     [][]float64 `json:"matrix"`
+		Background []float64   `json:"background"`
+		LogBase    float64     `json:"logBase"`
+		// lowest and highest achievable scores
+		MinScore float64 `json:"minScore"`
+		MaxScore float64 `json:"maxScore"`
+	}
+)
+
+const (
+	// add a fixed number of counts, split by background
+	PseudocountFixed PseudocountMode = "fixed"
+	// add sqrt(nsites) counts, split by background
+	PseudocountSqrt PseudocountMode = "sqrt"
+	// posterior mean under a Dirichlet prior
+	PseudocountDirichlet PseudocountMode = "dirichlet"
+
+	// same as the FIMO default
+	DefaultPseudocount = 0.1
+
+	DefaultLogBase = 2
+
+	// MEME tools assume 20 sites when nsites is not given
+	DefaultNSites = 20
+)
+
+var (
+	// UniformBackground assumes each base is equally likely
+	UniformBackground = []float64{0.25, 0.25, 0.25, 0.25}
+
+	// Jeffreys prior
+	DefaultDirichlet = []float64{0.5, 0.5, 0.5, 0.5}
+
+	ErrPseudocount = errors.New("unknown pseudocount mode")
+	ErrLogBase     = errors.New("log base must be positive and not 1")
+	ErrDirichlet   = errors.New("dirichlet prior must be 4 positive counts")
+	ErrWeights     = errors.New("weights must have a, c, g, t columns")
+	ErrCounts      = errors.New("matrix position has no counts")
+
+	// IUPAC codes for pairs of bases indexed by the lower then higher
+	// of A, C, G, T
+	iupacPairs = [4][4]byte{
+		{'A', 'M', 'R', 'W'},
+		{'M', 'C', 'S', 'Y'},
+		{'R', 'S', 'G', 'K'},
+		{'W', 'Y', 'K', 'T'},
+	}
+)
+
+func NewPWMOptions() *PWMOptions {
+	return &PWMOptions{Background: UniformBackground,
+		Pseudocount:       PseudocountFixed,
+		PseudocountWeight: DefaultPseudocount,
+		LogBase:           DefaultLogBase}
+}
+
+// fill in defaults and check options are sensible
+func (opts *PWMOptions) validate() error {
+	if len(opts.Background) == 0 {
+		opts.Background = UniformBackground
+	}
+
+	err := validateBackground(opts.Background)
+
+	if err != nil {
+		return err
+	}
+
+	if opts.Pseudocount == "" {
+		opts.Pseudocount = PseudocountFixed
+	}
+
+	switch opts.Pseudocount {
+	case PseudocountFixed:
+		if opts.PseudocountWeight <= 0 {
+			opts.PseudocountWeight = DefaultPseudocount
+		}
+	case PseudocountSqrt:
+	case PseudocountDirichlet:
+		if len(opts.Dirichlet) == 0 {
+			opts.Dirichlet = DefaultDirichlet
+		}
+
+		if len(opts.Dirichlet) != 4 || slices.ContainsFunc(opts.Dirichlet, func(a float64) bool { return a <= 0 }) {
+			return ErrDirichlet
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrPseudocount, opts.Pseudocount)
+	}
+
+	if opts.LogBase == 0 {
+		opts.LogBase = DefaultLogBase
+	}
+
+	if opts.LogBase < 0 || opts.LogBase == 1 {
+		return ErrLogBase
+	}
+
+	return nil
+}
+
+func validateBackground(bg []float64) error {
+	if len(bg) != 4 {
+		return ErrBackground
+	}
+
+	sum := 0.0
+
+	for _, f := range bg {
+		if f <= 0 {
+			return ErrBackground
+		}
+
+		sum += f
+	}
+
+	if sum < 0.99 || sum > 1.01 {
+		return ErrBackground
+	}
+
+	return nil
+}
+
+// number of sites the probabilities were estimated from, used to
+// convert them back to counts
+func (motif *Motif) sites() float64 {
+	if motif.NSites > 0 {
+		return motif.NSites
+	}
+
+	return DefaultNSites
+}
+
+// SetCounts sets the motif's raw counts, given as a, c, g, t counts for
+// each position, along with the weights and nsites derived from them.
+// nsites is the largest number of counts at a position.
+func (motif *Motif) SetCounts(counts [][]float64) error {
+	nsites := 0.0
+
+	for i, row := range counts {
+		sum := 0.0
+
+		for _, v := range row {
+			sum += v
+		}
+
+		if sum <= 0 {
+			return fmt.Errorf("%w: %s position %d", ErrCounts, motif.MotifId, i+1)
+		}
+
+		nsites = max(nsites, sum)
+	}
+
+	weights, err := NormalizeWeights(counts)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", motif.MotifId, err)
+	}
+
+	motif.Counts = counts
+	motif.Weights = weights
+	motif.NSites = nsites
+
+	return nil
+}
+
+// Consensus gives the IUPAC consensus of the motif using the rules of
+// Cavener (1987): a base if it is more than half of a position and
+// twice the next, a two base code if the top two are more than three
+// quarters, and N otherwise
+func (motif *Motif) Consensus() string {
+	ret := make([]byte, 0, len(motif.Weights))
+
+	for _, pw := range motif.Weights {
+		if len(pw) != 4 {
+			ret = append(ret, 'N')
+			continue
+		}
+
+		// bases ordered by decreasing probability
+		order := []int{0, 1, 2, 3}
+
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(pw[b], pw[a])
+		})
+
+		first := pw[order[0]]
+		second := pw[order[1]]
+
+		switch {
+		case first > 0.5 && first > 2*second:
+			ret = append(ret, "ACGT"[order[0]])
+		case first+second > 0.75:
+			ret = append(ret, iupacPairs[min(order[0], order[1])][max(order[0], order[1])])
+		default:
+			ret = append(ret, 'N')
+		}
+	}
+
+	return string(ret)
+}
+
+// CountMatrix returns the motif's raw counts if it has them,
+// otherwise counts estimated from the probabilities and nsites,
+// rounded to 3 decimal places
+func (motif *Motif) CountMatrix() [][]float64 {
+	if len(motif.Counts) == len(motif.Weights) && len(motif.Counts) > 0 {
+		return cloneMatrix(motif.Counts)
+	}
+
+	n := motif.sites()
+
+	ret := make([][]float64, len(motif.Weights))
+
+	for i, pw := range motif.Weights {
+		row := make([]float64, len(pw))
+
+		for b, p := range pw {
+			row[b] = math.Round(p*n*1000) / 1000
+		}
+
+		ret[i] = row
+	}
+
+	return ret
+}
+
+// PWM converts the motif's counts into a log odds scoring matrix.
+// Motifs without counts have their probabilities turned back into
+// counts using nsites so pseudocounts have the right relative weight.
+func (motif *Motif) PWM(opts *PWMOptions) (*PWM, error) {
+	if opts == nil {
+		opts = NewPWMOptions()
+	}
+
+	err := opts.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(motif.Weights) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEmptyMotif, motif.MotifId)
+	}
+
+	bg := opts.Background
+	n := motif.sites()
+
+	// prior counts for each base
+	prior := make([]float64, 4)
+
+	switch opts.Pseudocount {
+	case PseudocountFixed:
+		for b := range prior {
+			prior[b] = opts.PseudocountWeight * bg[b]
+		}
+	case PseudocountSqrt:
+		for b := range prior {
+			prior[b] = math.Sqrt(n) * bg[b]
+		}
+	case PseudocountDirichlet:
+		copy(prior, opts.Dirichlet)
+	}
+
+	priorTotal := 0.0
+
+	for _, a := range prior {
+		priorTotal += a
+	}
+
+	// raw counts are used as is when the motif has them since
+	// positions can have different numbers of sites
+	hasCounts := len(motif.Counts) == len(motif.Weights)
+
+	logBase := math.Log(opts.LogBase)
+
+	pwm := PWM{Matrix: make([][]float64, len(motif.Weights)),
+		Background: slices.Clone(bg),
+		LogBase:    opts.LogBase}
+
+	for i, pw := range motif.Weights {
+		if len(pw) != 4 {
+			return nil, fmt.Errorf("%w: %s position %d", ErrWeights, motif.MotifId, i+1)
+		}
+
+		counts := make([]float64, 4)
+		total := n
+
+		if hasCounts && len(motif.Counts[i]) == 4 {
+			copy(counts, motif.Counts[i])
+			total = 0
+
+			for _, v := range counts {
+				total += v
+			}
+		} else {
+			for b, p := range pw {
+				counts[b] = p * n
+			}
+		}
+
+		total += priorTotal
+
+		row := make([]float64, 4)
+
+		for b, v := range counts {
+			p := (v + prior[b]) / total
+			row[b] = math.Log(p/bg[b]) / logBase
+		}
+
+		pwm.Matrix[i] = row
+		pwm.MinScore += slices.Min(row)
+		pwm.MaxScore += slices.Max(row)
+	}
+
+	return &pwm, nil
+}
+
+// Score of an encoded window, which must be the same length as the
+// matrix and contain only valid bases
+func (pwm *PWM) Score(bases []int8) float64 {
+	score := 0.0
+
+	for i, b := range bases {
+		score += pwm.Matrix[i][b]
+	}
+
+	return score
+}
+
+// RelativeScore scales a score to between 0 for the lowest and 1
+// for the highest achievable score
+func (pwm *PWM) RelativeScore(score float64) float64 {
+	if pwm.MaxScore == pwm.MinScore {
+		return 1
+	}
+
+	return (score - pwm.MinScore) / (pwm.MaxScore - pwm.MinScore)
+}
+
+// ScoreFromRelative converts a relative score back to a score
+func (pwm *PWM) ScoreFromRelative(rel float64) float64 {
+	return pwm.MinScore + rel*(pwm.MaxScore-pwm.MinScore)
+}
+
+// RevComp returns the matrix for scoring the opposite strand by
+// reading the forward sequence
+func (pwm *PWM) RevComp() *PWM {
+	rc := PWM{Matrix: cloneMatrix(pwm.Matrix),
+		Background: slices.Clone(pwm.Background),
+		LogBase:    pwm.LogBase,
+		MinScore:   pwm.MinScore,
+		MaxScore:   pwm.MaxScore}
+
+	RevCompWeights(rc.Matrix)
+
+	return &rc
+}
+
+// copy of a matrix so that it can be changed, e.g. reverse
+// complemented, without affecting the original
+func cloneMatrix(m [][]float64) [][]float64 {
+	ret := make([][]float64, len(m))
+
+	for i, row := range m {
+		ret[i] = slices.Clone(row)
+	}
+
+	return ret
+}
@@ -0,0 +1,115 @@
+package motifs
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPWM(t *testing.T) {
+	motif := testMotif()
+
+	for _, mode := range []PseudocountMode{PseudocountFixed, PseudocountSqrt, PseudocountDirichlet} {
+		opts := NewPWMOptions()
+		opts.Pseudocount = mode
+
+		pwm, err := motif.PWM(opts)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every entry must be finite even though the motif has zeros
+		for _, row := range pwm.Matrix {
+			for _, v := range row {
+				if math.IsInf(v, 0) || math.IsNaN(v) {
+					t.Fatalf("%s: non finite score %v", mode, pwm.Matrix)
+				}
+			}
+		}
+
+		best := pwm.Score([]int8{2, 0, 3, 0})
+
+		if math.Abs(best-pwm.MaxScore) > 1e-9 || pwm.RelativeScore(best) != 1 {
+			t.Fatalf("%s: GATA should score %f, found %f", mode, pwm.MaxScore, best)
+		}
+
+		if pwm.RelativeScore(pwm.MinScore) != 0 {
+			t.Fatalf("%s: min score should be 0 relative", mode)
+		}
+	}
+}
+
+func TestPWMLogBase(t *testing.T) {
+	motif := testMotif()
+
+	bits, _ := motif.PWM(nil)
+
+	opts := NewPWMOptions()
+	opts.LogBase = math.E
+
+	nats, err := motif.PWM(opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(bits.MaxScore*math.Ln2-nats.MaxScore) > 1e-9 {
+		t.Fatalf("expected %f nats, found %f", bits.MaxScore*math.Ln2, nats.MaxScore)
+	}
+
+	opts.Background = []float64{0.5, 0.5, 0.5, 0.5}
+
+	_, err = motif.PWM(opts)
+
+	if err == nil {
+		t.Fatal("expected background error")
+	}
+}
+
+func TestConsensus(t *testing.T) {
+	motif := testMotif()
+	motif.Weights = append(motif.Weights,
+		[]float64{0.45, 0.4, 0.1, 0.05},
+		[]float64{0.25, 0.25, 0.25, 0.25})
+
+	consensus := motif.Consensus()
+
+	if consensus != "GATAMN" {
+		t.Fatalf("expected GATAMN, found %s", consensus)
+	}
+}
+
+func TestPWMCounts(t *testing.T) {
+	// the second position has fewer sites so pseudocounts should
+	// count for more there than nsites alone would suggest
+	motif := Motif{MotifId: "COUNTS"}
+
+	err := motif.SetCounts([][]float64{{20, 0, 0, 0}, {5, 0, 0, 0}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pwm, err := motif.PWM(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if motif.NSites != 20 || pwm.Matrix[1][0] >= pwm.Matrix[0][0] {
+		t.Fatalf("expected fewer counts to score lower, found %v", pwm.Matrix)
+	}
+
+	// the same probabilities without counts score the same
+	motif.Counts = nil
+
+	pwm, err = motif.PWM(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pwm.Matrix[1][0] != pwm.Matrix[0][0] {
+		t.Fatalf("expected equal scores, found %v", pwm.Matrix)
+	}
+}
@@ -1,11 +1,20 @@
 package routes
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/antonybholmes/go-motifs"
+	"github.com/antonybholmes/go-motifs/background"
+	"github.com/antonybholmes/go-motifs/homer"
+	"github.com/antonybholmes/go-motifs/jaspar"
+	"github.com/antonybholmes/go-motifs/meme"
 	"github.com/antonybholmes/go-motifs/motifsdb"
+	"github.com/antonybholmes/go-motifs/tracks"
+	"github.com/antonybholmes/go-motifs/transfac"
 	"github.com/antonybholmes/go-sys/log"
 	"github.com/antonybholmes/go-sys/query"
 	"github.com/antonybholmes/go-web"
@@ -16,8 +25,15 @@ type (
 	ReqParams struct {
 		Query string `json:"q" form:"q"`
 		//Exact      bool     `json:"exact"`
-		RevComp    bool     `json:"revComp"`
-		Datasets   []string `json:"datasets"`
+		RevComp  bool     `json:"revComp" form:"revComp"`
+		Strand   string   `json:"strand" form:"strand"`
+		Datasets []string `json:"datasets"`
+		// species names or taxonomy ids, all if empty
+		Species []string `json:"species"`
+		// family public ids or names, all if empty
+		Families []string `json:"families"`
+		// HOCOMOCO quality grades, all if empty
+		Grades     []string `json:"grades"`
 		Page       int      `json:"page" form:"page"`
 		PageSize   int      `json:"pageSize" form:"pageSize"`
 		SearchMode string   `json:"searchMode" form:"searchMode"`
@@ -27,10 +43,181 @@ type (
 	MotifsToGenesReqParams struct {
 		Ids []string `json:"ids" form:"ids"`
 	}
+
+	GenesToMotifsReqParams struct {
+		Genes    []string `json:"genes" form:"genes"`
+		Datasets []string `json:"datasets"`
+		Species  []string `json:"species"`
+		Families []string `json:"families"`
+		Grades   []string `json:"grades"`
+		// include motif weights in the response
+		Weights bool   `json:"weights" form:"weights"`
+		RevComp bool   `json:"revComp" form:"revComp"`
+		Strand  string `json:"strand" form:"strand"`
+	}
+
+	// orientation of weights in a response
+	StrandReqParams struct {
+		RevComp bool   `json:"revComp" form:"revComp"`
+		Strand  string `json:"strand" form:"strand"`
+	}
+
+	ScanReqParams struct {
+		Sequences []*motifs.Sequence `json:"sequences"`
+		// motifs to scan with, either by id or as sets
+		Ids      []string `json:"ids"`
+		Datasets []string `json:"datasets"`
+		Query    string   `json:"q"`
+
+		PValue         float64   `json:"pvalue"`
+		Strand         string    `json:"strand"`
+		SkipSoftMasked bool      `json:"skipSoftMasked"`
+		Background     []float64 `json:"background"`
+		// "dataset" to score each motif against the background of
+		// its dataset instead
+		BackgroundModel string `json:"backgroundModel"`
+		Pseudocount     string `json:"pseudocount"`
+		// tsv, bed or gff3 to get hits as a track file rather than
+		// JSON
+		Format string `json:"format"`
+	}
+
+	EnrichReqParams struct {
+		Sequences []*motifs.Sequence `json:"sequences"`
+		// background sequences. If there are none, dinucleotide
+		// shuffles of the targets are used
+		Background []*motifs.Sequence `json:"background"`
+		// shuffles of each target when there is no background
+		Shuffles int `json:"shuffles"`
+		// k-mer size kept by shuffles, 2 if not given
+		ShuffleK int `json:"shuffleK"`
+		// seeds shuffles so a request always gives the same result,
+		// random if 0
+		Seed uint64 `json:"seed"`
+		// motifs to test, either by id or as sets
+		Ids      []string `json:"ids"`
+		Datasets []string `json:"datasets"`
+		Query    string   `json:"q"`
+
+		PValue         float64 `json:"pvalue"`
+		Strand         string  `json:"strand"`
+		SkipSoftMasked bool    `json:"skipSoftMasked"`
+		// "dataset" to score each motif against the background of
+		// its dataset rather than a uniform one
+		BackgroundModel string  `json:"backgroundModel"`
+		Test            string  `json:"test"`
+		MaxQValue       float64 `json:"maxQValue"`
+	}
+
+	CentralReqParams struct {
+		// equal length sequences, usually centred on peak summits
+		Sequences []*motifs.Sequence `json:"sequences"`
+		// motifs to test, either by id or as sets
+		Ids      []string `json:"ids"`
+		Datasets []string `json:"datasets"`
+		Query    string   `json:"q"`
+
+		PValue         float64 `json:"pvalue"`
+		Strand         string  `json:"strand"`
+		SkipSoftMasked bool    `json:"skipSoftMasked"`
+		// "dataset" to score each motif against the background of
+		// its dataset rather than a uniform one
+		BackgroundModel string  `json:"backgroundModel"`
+		MaxEValue       float64 `json:"maxEValue"`
+	}
+
+	CompareReqParams struct {
+		// query motif as a, c, g, t probabilities or counts, or as
+		// MEME text in which case the first motif is used
+		Weights [][]float64 `json:"weights"`
+		Meme    string      `json:"meme"`
+		// motifs to compare against. Every dataset is searched if
+		// none are given
+		Ids      []string `json:"ids"`
+		Datasets []string `json:"datasets"`
+		Query    string   `json:"q"`
+
+		Metric     string  `json:"metric"`
+		MinOverlap int     `json:"minOverlap"`
+		MaxEValue  float64 `json:"maxEValue"`
+		MaxMatches int     `json:"maxMatches"`
+	}
+
+	ExportReqParams struct {
+		// motifs to export, either by id or as sets
+		Ids      []string `json:"ids" form:"ids"`
+		Datasets []string `json:"datasets"`
+		Query    string   `json:"q" form:"q"`
+		// see ExportFormats, jaspar if empty
+		Format  string `json:"format" form:"format"`
+		RevComp bool   `json:"revComp" form:"revComp"`
+		Strand  string `json:"strand" form:"strand"`
+	}
+
+	// how to write motifs in an export and how to serve the file
+	exportFormat struct {
+		contentType string
+		ext         string
+		write       func(w io.Writer, ms []*motifs.Motif) error
+	}
+
+	LogoReqParams struct {
+		Mode    string `json:"mode" form:"mode"`
+		RevComp bool   `json:"revComp" form:"revComp"`
+		Strand  string `json:"strand" form:"strand"`
+		// axis is shown unless this is false
+		Axis   string `json:"axis" form:"axis"`
+		Width  int    `json:"width" form:"width"`
+		Height int    `json:"height" form:"height"`
+		// comma separated a, c, g, t colours. The # is optional so
+		// colours need not be escaped in urls
+		Colors string `json:"colors" form:"colors"`
+	}
+)
+
+const (
+	// SearchMode for ranked full text search. "adv" selects boolean
+	// search and anything else simple prefix search
+	SearchModeFullText = "fts"
+
+	// limit on the total length of sequences in one scan request
+	MaxScanBases = 1000000
+	// limit on the motifs selected by one scan, enrichment or central
+	// enrichment request, each of which scans every sequence
+	MaxScanMotifs = 100
+
+	// limit on the motifs in one export. HOMER exports compute a
+	// score distribution for each
+	MaxExportMotifs = 500
+
+	// shuffles of each target used as the background of an
+	// enrichment request without one
+	DefaultEnrichShuffles = 1
+	MaxEnrichShuffles     = 10
+
+	ExportFormatJaspar     = "jaspar"
+	ExportFormatJasparJSON = "jaspar-json"
+	ExportFormatMeme       = "meme"
+	ExportFormatHomer      = "homer"
+	ExportFormatTransfac   = "transfac"
 )
 
 var (
-	ErrSearchTooShort = errors.New("search too short")
+	ErrSearchTooShort   = errors.New("search too short")
+	ErrNoScanMotifs     = errors.New("no motifs selected")
+	ErrTooManyScanBases = errors.New("too many bases to scan")
+	ErrTooManyShuffles  = errors.New("at most 10 shuffles of each sequence can be used as a background")
+	ErrNoQueryMotif     = errors.New("no query motif given")
+	ErrWeightsStrand    = errors.New("strand must be + or -")
+	ErrExportFormat     = errors.New("export format must be jaspar, jaspar-json, meme, homer or transfac")
+
+	ExportFormats = map[string]*exportFormat{
+		ExportFormatJaspar:     {contentType: "text/plain", ext: "jaspar", write: jaspar.Write},
+		ExportFormatJasparJSON: {contentType: "application/json", ext: "json", write: jaspar.WriteJSONMotifs},
+		ExportFormatMeme:       {contentType: "text/plain", ext: "meme", write: meme.WriteMotifs},
+		ExportFormatHomer:      {contentType: "text/plain", ext: "motif", write: homer.WriteMotifs},
+		ExportFormatTransfac:   {contentType: "text/plain", ext: "transfac", write: transfac.WriteMotifs},
+	}
 )
 
 // utility to convert cache param string to bool
@@ -75,6 +262,156 @@ func ParseMotifsToGenesParamsFromPost(c *gin.Context) (*MotifsToGenesReqParams,
 	return &params, nil
 }
 
+func ParseGenesToMotifsParamsFromPost(c *gin.Context) (*GenesToMotifsReqParams, error) {
+
+	var params GenesToMotifsReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+func ParseScanParamsFromPost(c *gin.Context) (*ScanReqParams, error) {
+
+	var params ScanReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+func ParseEnrichParamsFromPost(c *gin.Context) (*EnrichReqParams, error) {
+
+	var params EnrichReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+func ParseCentralParamsFromPost(c *gin.Context) (*CentralReqParams, error) {
+
+	var params CentralReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+func ParseCompareParamsFromPost(c *gin.Context) (*CompareReqParams, error) {
+
+	var params CompareReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+// split a comma separated search into individual queries
+func parseQueries(q string) []string {
+	queries := make([]string, 0, 10)
+
+	q = query.SanitizeQuery(q)
+
+	if q == "" {
+		return queries
+	}
+
+	for _, query := range strings.Split(q, ",") {
+		query = strings.TrimSpace(query)
+
+		if query != "" {
+			queries = append(queries, query)
+		}
+	}
+
+	return queries
+}
+
+func ParseExportParamsFromPost(c *gin.Context) (*ExportReqParams, error) {
+
+	var params ExportReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+func ParseLogoParams(c *gin.Context) (*LogoReqParams, error) {
+
+	var params LogoReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+func ParseStrandParams(c *gin.Context) (*StrandReqParams, error) {
+
+	var params StrandReqParams
+
+	err := web.BindQueryAndJSON(c, &params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+// whether weights should be reverse complemented. A strand, if
+// given, takes precedence over the older revComp flag.
+func revCompForStrand(revComp bool, strand string) (bool, error) {
+	switch strand {
+	case "":
+		return revComp, nil
+	case motifs.StrandPlus:
+		return false, nil
+	case motifs.StrandMinus:
+		return true, nil
+	default:
+		return false, ErrWeightsStrand
+	}
+}
+
+// flags default to true unless explicitly turned off
+func boolFromString(s string) bool {
+	switch strings.ToLower(s) {
+	case "0", "f", "false", "n", "no":
+		return false
+	default:
+		return true
+	}
+}
+
 func DatasetsRoute(c *gin.Context) {
 
 	// useCache := useCacheFromString(params.UseCache)
@@ -90,6 +427,19 @@ func DatasetsRoute(c *gin.Context) {
 	web.MakeDataResp(c, "", datasets)
 }
 
+// FamiliesRoute lists transcription factor classes and families with
+// their motif counts
+func FamiliesRoute(c *gin.Context) {
+	families, err := motifsdb.Families()
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	web.MakeDataResp(c, "", families)
+}
+
 func SearchRoute(c *gin.Context) {
 
 	params, err := ParseParamsFromPost(c)
@@ -110,6 +460,13 @@ func SearchRoute(c *gin.Context) {
 
 	q = query.SanitizeQuery(q)
 
+	revComp, err := revCompForStrand(params.RevComp, params.Strand)
+
+	if err != nil {
+		web.BadReqResp(c, err)
+		return
+	}
+
 	// // which datasets to search in
 	// datasets := sys.NewStringSet()
 
@@ -126,11 +483,19 @@ func SearchRoute(c *gin.Context) {
 		PageSize: max(params.PageSize, motifs.MinPageSize),
 	}
 
+	filter := motifs.MotifFilter{Species: params.Species,
+		Families: params.Families,
+		Grades:   params.Grades}
+
 	// we can enable bool search mode for more complex queries
 	if strings.HasPrefix(params.SearchMode, "adv") {
 		log.Debug().Msgf("bool search mode")
 
-		result, err = motifsdb.BoolSearch(q, params.Datasets, &paging, false)
+		result, err = motifsdb.BoolSearch(q, params.Datasets, &filter, &paging, revComp)
+	} else if params.SearchMode == SearchModeFullText {
+		// full text queries are escaped when they are parsed and
+		// need quotes and wildcards that sanitizing would remove
+		result, err = motifsdb.FullTextSearch(params.Query, params.Datasets, &filter, &paging, revComp)
 	} else {
 		log.Debug().Msgf("bool search mode disabled")
 		queries := strings.Split(q, ",")
@@ -142,10 +507,18 @@ func SearchRoute(c *gin.Context) {
 			queriesTrimmed = append(queriesTrimmed, strings.TrimSpace(query))
 		}
 
-		result, err = motifsdb.Search(queriesTrimmed, params.Datasets, &paging, false)
+		result, err = motifsdb.Search(queriesTrimmed, params.Datasets, &filter, &paging, revComp)
 	}
 
 	if err != nil {
+		if errors.Is(err, motifs.ErrFullTextQuery) ||
+			errors.Is(err, motifs.ErrNoFullTextIndex) ||
+			errors.Is(err, motifs.ErrUnknownSpecies) ||
+			errors.Is(err, motifs.ErrGrade) {
+			web.BadReqResp(c, err)
+			return
+		}
+
 		log.Debug().Msgf("motif %s", err)
 		c.Error(err)
 		return
@@ -186,3 +559,592 @@ func MotifsToGenesRoute(c *gin.Context) {
 
 	//web.MakeDataResp(c, "", mutationdbcache.GetInstance().List())
 }
+
+func GenesToMotifsRoute(c *gin.Context) {
+
+	params, err := ParseGenesToMotifsParamsFromPost(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// limit the number of genes to the maximum allowed records
+	genes := params.Genes[0:min(len(params.Genes), motifs.MaxRecords)]
+
+	revComp, err := revCompForStrand(params.RevComp, params.Strand)
+
+	if err != nil {
+		web.BadReqResp(c, err)
+		return
+	}
+
+	filter := motifs.MotifFilter{Species: params.Species,
+		Families: params.Families,
+		Grades:   params.Grades}
+
+	result, err := motifsdb.GenesToMotifs(genes, params.Datasets, &filter, params.Weights)
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrUnknownSpecies) || errors.Is(err, motifs.ErrGrade) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		log.Debug().Msgf("genes to motifs %s", err)
+		c.Error(err)
+		return
+	}
+
+	if revComp {
+		for _, entry := range result {
+			for _, motif := range entry.Motifs {
+				motifs.RevCompWeights(motif.Weights)
+				motifs.RevCompWeights(motif.Counts)
+			}
+		}
+	}
+
+	web.MakeDataResp(c, "", result)
+}
+
+func ScanRoute(c *gin.Context) {
+
+	params, err := ParseScanParamsFromPost(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(params.Sequences) == 0 {
+		web.BadReqResp(c, motifs.ErrNoSequences)
+		return
+	}
+
+	bases := 0
+
+	for _, seq := range params.Sequences {
+		bases += len(seq.Seq)
+	}
+
+	if bases > MaxScanBases {
+		web.BadReqResp(c, ErrTooManyScanBases)
+		return
+	}
+
+	selection := motifs.MotifSelection{Ids: params.Ids,
+		Datasets:  params.Datasets,
+		Queries:   parseQueries(params.Query),
+		MaxMotifs: MaxScanMotifs}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		web.BadReqResp(c, ErrNoScanMotifs)
+		return
+	}
+
+	// unknown formats are rejected before scanning
+	var buf bytes.Buffer
+	var hw *tracks.HitWriter
+
+	if params.Format != "" {
+		hw, err = tracks.NewHitWriter(&buf, params.Format)
+
+		if err != nil {
+			web.BadReqResp(c, err)
+			return
+		}
+	}
+
+	opts := motifs.NewScanOptions()
+	opts.Strand = params.Strand
+	opts.SkipSoftMasked = params.SkipSoftMasked
+	opts.BackgroundModel = params.BackgroundModel
+
+	if params.PValue > 0 {
+		opts.PValue = params.PValue
+	}
+
+	if len(params.Background) > 0 {
+		opts.Background = params.Background
+	}
+
+	if params.Pseudocount != "" {
+		opts.Pseudocount = motifs.PseudocountMode(params.Pseudocount)
+	}
+
+	hits, err := motifsdb.Scan(params.Sequences, &selection, opts)
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrBackground) ||
+			errors.Is(err, motifs.ErrBackgroundModel) ||
+			errors.Is(err, motifs.ErrTooManyMotifs) ||
+			errors.Is(err, motifs.ErrPseudocount) ||
+			errors.Is(err, motifs.ErrScanStrand) ||
+			errors.Is(err, motifs.ErrScanPValue) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		log.Debug().Msgf("motif scan %s", err)
+		c.Error(err)
+		return
+	}
+
+	if hw != nil {
+		for _, hit := range hits {
+			err = hw.Write(hit)
+
+			if err != nil {
+				c.Error(err)
+				return
+			}
+		}
+
+		err = hw.Flush()
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="hits.`+strings.ToLower(params.Format)+`"`)
+		c.Data(http.StatusOK, "text/plain", buf.Bytes())
+		return
+	}
+
+	web.MakeDataResp(c, "", hits)
+}
+
+// EnrichRoute tests the selected motifs for enrichment in the given
+// sequences relative to a background, which is made by shuffling the
+// sequences if none is given
+func EnrichRoute(c *gin.Context) {
+
+	params, err := ParseEnrichParamsFromPost(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(params.Sequences) == 0 {
+		web.BadReqResp(c, motifs.ErrNoSequences)
+		return
+	}
+
+	// sizes are checked before any shuffles are made so a request
+	// cannot ask for more background than the server will scan
+	bases := 0
+
+	for _, seq := range params.Sequences {
+		bases += len(seq.Seq)
+	}
+
+	backgroundSeqs := params.Background
+	shuffles := 0
+
+	if len(backgroundSeqs) == 0 {
+		shuffles = params.Shuffles
+
+		if shuffles <= 0 {
+			shuffles = DefaultEnrichShuffles
+		}
+
+		if shuffles > MaxEnrichShuffles {
+			web.BadReqResp(c, ErrTooManyShuffles)
+			return
+		}
+
+		bases *= 1 + shuffles
+	} else {
+		for _, seq := range backgroundSeqs {
+			bases += len(seq.Seq)
+		}
+	}
+
+	if bases > MaxScanBases {
+		web.BadReqResp(c, ErrTooManyScanBases)
+		return
+	}
+
+	if shuffles > 0 {
+		k := params.ShuffleK
+
+		if k == 0 {
+			k = background.DefaultShuffleK
+		}
+
+		backgroundSeqs, err = background.Shuffled(params.Sequences, k, shuffles, background.NewRand(params.Seed))
+
+		if err != nil {
+			web.BadReqResp(c, err)
+			return
+		}
+	}
+
+	selection := motifs.MotifSelection{Ids: params.Ids,
+		Datasets:  params.Datasets,
+		Queries:   parseQueries(params.Query),
+		MaxMotifs: MaxScanMotifs}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		web.BadReqResp(c, ErrNoScanMotifs)
+		return
+	}
+
+	opts := motifs.NewEnrichOptions()
+	opts.Strand = params.Strand
+	opts.SkipSoftMasked = params.SkipSoftMasked
+	opts.BackgroundModel = params.BackgroundModel
+	opts.MaxQValue = params.MaxQValue
+
+	if params.PValue > 0 {
+		opts.PValue = params.PValue
+	}
+
+	if params.Test != "" {
+		opts.Test = motifs.EnrichTest(params.Test)
+	}
+
+	results, err := motifsdb.Enrich(params.Sequences, backgroundSeqs, &selection, opts)
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrEnrichTest) ||
+			errors.Is(err, motifs.ErrBackgroundModel) ||
+			errors.Is(err, motifs.ErrTooManyMotifs) ||
+			errors.Is(err, motifs.ErrScanStrand) ||
+			errors.Is(err, motifs.ErrScanPValue) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		log.Debug().Msgf("motif enrich %s", err)
+		c.Error(err)
+		return
+	}
+
+	web.MakeDataResp(c, "", results)
+}
+
+// CentralRoute tests whether the best sites of the selected motifs
+// are concentrated at the centre of equal length sequences, returning
+// the best central window and site histogram of each motif
+func CentralRoute(c *gin.Context) {
+
+	params, err := ParseCentralParamsFromPost(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(params.Sequences) == 0 {
+		web.BadReqResp(c, motifs.ErrNoSequences)
+		return
+	}
+
+	bases := 0
+
+	for _, seq := range params.Sequences {
+		bases += len(seq.Seq)
+	}
+
+	if bases > MaxScanBases {
+		web.BadReqResp(c, ErrTooManyScanBases)
+		return
+	}
+
+	selection := motifs.MotifSelection{Ids: params.Ids,
+		Datasets:  params.Datasets,
+		Queries:   parseQueries(params.Query),
+		MaxMotifs: MaxScanMotifs}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		web.BadReqResp(c, ErrNoScanMotifs)
+		return
+	}
+
+	opts := motifs.NewCentralOptions()
+	opts.Strand = params.Strand
+	opts.SkipSoftMasked = params.SkipSoftMasked
+	opts.BackgroundModel = params.BackgroundModel
+	opts.MaxEValue = params.MaxEValue
+
+	if params.PValue > 0 {
+		opts.PValue = params.PValue
+	}
+
+	results, err := motifsdb.CentralEnrichment(params.Sequences, &selection, opts)
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrCentralLengths) ||
+			errors.Is(err, motifs.ErrBackgroundModel) ||
+			errors.Is(err, motifs.ErrTooManyMotifs) ||
+			errors.Is(err, motifs.ErrScanStrand) ||
+			errors.Is(err, motifs.ErrScanPValue) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		log.Debug().Msgf("motif central enrichment %s", err)
+		c.Error(err)
+		return
+	}
+
+	web.MakeDataResp(c, "", results)
+}
+
+// CompareRoute finds the database motifs most similar to a query
+// motif
+func CompareRoute(c *gin.Context) {
+
+	params, err := ParseCompareParamsFromPost(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	queryMotif := motifs.Motif{Weights: params.Weights}
+
+	if params.Meme != "" {
+		f, err := meme.Read(strings.NewReader(params.Meme))
+
+		if err != nil {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		if len(f.Motifs) == 0 {
+			web.BadReqResp(c, ErrNoQueryMotif)
+			return
+		}
+
+		queryMotif = *f.Motifs[0]
+	}
+
+	if len(queryMotif.Weights) == 0 {
+		web.BadReqResp(c, ErrNoQueryMotif)
+		return
+	}
+
+	selection := motifs.MotifSelection{Ids: params.Ids,
+		Datasets: params.Datasets,
+		Queries:  parseQueries(params.Query)}
+
+	opts := motifs.NewCompareOptions()
+	opts.MinOverlap = params.MinOverlap
+	opts.MaxEValue = params.MaxEValue
+	opts.MaxMatches = params.MaxMatches
+
+	if params.Metric != "" {
+		opts.Metric = motifs.CompareMetric(params.Metric)
+	}
+
+	matches, err := motifsdb.CompareMotif(&queryMotif, &selection, opts)
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrCompareMetric) ||
+			errors.Is(err, motifs.ErrWeights) ||
+			errors.Is(err, motifs.ErrEmptyMotif) ||
+			errors.Is(err, motifs.ErrQueryTooWide) ||
+			errors.Is(err, motifs.ErrNoTargets) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		log.Debug().Msgf("motif compare %s", err)
+		c.Error(err)
+		return
+	}
+
+	web.MakeDataResp(c, "", matches)
+}
+
+// MotifRoute returns one motif with its weights and metadata
+func MotifRoute(c *gin.Context) {
+
+	params, err := ParseStrandParams(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	revComp, err := revCompForStrand(params.RevComp, params.Strand)
+
+	if err != nil {
+		web.BadReqResp(c, err)
+		return
+	}
+
+	motif, err := motifsdb.Motif(c.Param("id"))
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrMotifNotFound) {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	if revComp {
+		motifs.RevCompWeights(motif.Weights)
+		motifs.RevCompWeights(motif.Counts)
+	}
+
+	web.MakeDataResp(c, "", motif)
+}
+
+// ExportRoute serves the selected motifs as a file in JASPAR count
+// format, or in another of ExportFormats if asked for
+func ExportRoute(c *gin.Context) {
+
+	params, err := ParseExportParamsFromPost(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if params.Format == "" {
+		params.Format = ExportFormatJaspar
+	}
+
+	format, found := ExportFormats[strings.ToLower(params.Format)]
+
+	if !found {
+		web.BadReqResp(c, ErrExportFormat)
+		return
+	}
+
+	revComp, err := revCompForStrand(params.RevComp, params.Strand)
+
+	if err != nil {
+		web.BadReqResp(c, err)
+		return
+	}
+
+	selection := motifs.MotifSelection{Ids: params.Ids,
+		Datasets:  params.Datasets,
+		Queries:   parseQueries(params.Query),
+		MaxMotifs: MaxExportMotifs}
+
+	if len(selection.Ids) == 0 && len(selection.Datasets) == 0 && len(selection.Queries) == 0 {
+		web.BadReqResp(c, ErrNoScanMotifs)
+		return
+	}
+
+	ms, err := motifsdb.SelectMotifs(&selection)
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrTooManyMotifs) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	if revComp {
+		for _, motif := range ms {
+			motifs.RevCompWeights(motif.Weights)
+			motifs.RevCompWeights(motif.Counts)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err = format.write(&buf, ms)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="motifs.`+format.ext+`"`)
+	c.Data(http.StatusOK, format.contentType, buf.Bytes())
+}
+
+func LogoSVGRoute(c *gin.Context) {
+	logoRoute(c, "image/svg+xml")
+}
+
+func LogoPNGRoute(c *gin.Context) {
+	logoRoute(c, "image/png")
+}
+
+// render the logo of the motif in the url as an image
+func logoRoute(c *gin.Context, contentType string) {
+
+	params, err := ParseLogoParams(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	motif, err := motifsdb.Motif(c.Param("id"))
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrMotifNotFound) {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	opts := motifs.NewLogoOptions()
+	opts.Mode = motifs.LogoMode(params.Mode)
+	opts.RevComp, err = revCompForStrand(params.RevComp, params.Strand)
+
+	if err != nil {
+		web.BadReqResp(c, err)
+		return
+	}
+	opts.Axis = boolFromString(params.Axis)
+	opts.PositionWidth = params.Width
+	opts.Height = params.Height
+
+	if params.Colors != "" {
+		opts.Colors = strings.Split(params.Colors, ",")
+
+		for i, color := range opts.Colors {
+			color = strings.TrimSpace(color)
+
+			if !strings.HasPrefix(color, "#") {
+				color = "#" + color
+			}
+
+			opts.Colors[i] = color
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if contentType == "image/png" {
+		err = motif.LogoPNG(&buf, opts)
+	} else {
+		err = motif.LogoSVG(&buf, opts)
+	}
+
+	if err != nil {
+		if errors.Is(err, motifs.ErrLogoMode) ||
+			errors.Is(err, motifs.ErrLogoColor) ||
+			errors.Is(err, motifs.ErrLogoSize) {
+			web.BadReqResp(c, err)
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,575 @@
+package motifs
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type (
+	// A named DNA sequence to scan
+	Sequence struct {
+		Name string `json:"name"`
+		Seq  string `json:"seq"`
+	}
+
+	ScanOptions struct {
+		// how motifs are converted to log odds
+		PWMOptions
+
+		// report hits with a p-value at or below this threshold
+		PValue float64 `json:"pvalue"`
+		// "+" or "-" to scan one strand, otherwise both strands
+		// are scanned
+		Strand string `json:"strand"`
+		// lowercase bases are soft-masked repeats. By default they
+		// are scanned like uppercase bases, but they can be treated
+		// like N so no hit overlaps them
+		SkipSoftMasked bool `json:"skipSoftMasked"`
+		// keep at most this many of the best hits
+		MaxHits int `json:"maxHits"`
+		// BackgroundModelDataset scores motifs chosen from the
+		// database against the background of their dataset rather
+		// than Background
+		BackgroundModel string `json:"backgroundModel"`
+
+		// backgrounds of the datasets of the motifs being scanned by
+		// public id, filled in by the database when BackgroundModel
+		// is BackgroundModelDataset
+		datasetBackgrounds map[string][]float64
+	}
+
+	// A motif occurrence in a sequence. Positions are 1-based and
+	// inclusive as in FIMO, and are on the chromosome if Chr is set
+	ScanHit struct {
+		Id       string  `json:"id"`
+		MotifId  string  `json:"motifId"`
+		Name     string  `json:"name"`
+		Sequence string  `json:"seq"`
+		Chr      string  `json:"chr,omitempty"`
+		Start    int     `json:"start"`
+		End      int     `json:"end"`
+		Strand   string  `json:"strand"`
+		Score    float64 `json:"score"`
+		PValue   float64 `json:"pvalue"`
+		// matched bases read on the hit strand
+		Match string `json:"match"`
+	}
+)
+
+const (
+	StrandPlus  = "+"
+	StrandMinus = "-"
+
+	DefaultScanPValue = 1e-4
+	DefaultMaxHits    = 100000
+
+	// score each motif against the background of its dataset, see
+	// MotifDB.DatasetBackground
+	BackgroundModelDataset = "dataset"
+
+	// encoded value for N and any other base we cannot score
+	invalidBase int8 = -1
+)
+
+var (
+	ErrEmptyMotif  = errors.New("motif has no weights")
+	ErrBackground  = errors.New("background must be 4 positive frequencies summing to 1")
+	ErrScanStrand  = errors.New("strand must be +, - or empty for both")
+	ErrScanPValue  = errors.New("p-value threshold must be between 0 and 1")
+	ErrNoSequences = errors.New("no sequences to scan")
+
+	ErrBackgroundModel = errors.New("background model must be dataset or empty")
+)
+
+func NewScanOptions() *ScanOptions {
+	return &ScanOptions{PWMOptions: *NewPWMOptions(),
+		PValue:  DefaultScanPValue,
+		MaxHits: DefaultMaxHits}
+}
+
+// fill in defaults and check options are sensible
+func (opts *ScanOptions) validate() error {
+	if opts.PValue == 0 {
+		opts.PValue = DefaultScanPValue
+	}
+
+	if opts.PValue < 0 || opts.PValue > 1 {
+		return ErrScanPValue
+	}
+
+	if opts.Strand != "" && opts.Strand != StrandPlus && opts.Strand != StrandMinus {
+		return ErrScanStrand
+	}
+
+	if opts.MaxHits <= 0 {
+		opts.MaxHits = DefaultMaxHits
+	}
+
+	if opts.BackgroundModel != "" && opts.BackgroundModel != BackgroundModelDataset {
+		return fmt.Errorf("%w: %s", ErrBackgroundModel, opts.BackgroundModel)
+	}
+
+	return opts.PWMOptions.validate()
+}
+
+// EncodeSequence maps bases to the weight column they score
+// against, 0-3 for A, C, G, T. N, IUPAC ambiguity codes and,
+// optionally, soft-masked lowercase bases are encoded as -1 so
+// no window containing them is scored
+func EncodeSequence(seq string, skipSoftMasked bool) []int8 {
+	ret := make([]int8, len(seq))
+
+	for i := 0; i < len(seq); i++ {
+		ret[i] = encodeBase(seq[i], skipSoftMasked)
+	}
+
+	return ret
+}
+
+func encodeBase(b byte, skipSoftMasked bool) int8 {
+	switch b {
+	case 'A':
+		return 0
+	case 'C':
+		return 1
+	case 'G':
+		return 2
+	case 'T':
+		return 3
+	}
+
+	if skipSoftMasked {
+		return invalidBase
+	}
+
+	switch b {
+	case 'a':
+		return 0
+	case 'c':
+		return 1
+	case 'g':
+		return 2
+	case 't':
+		return 3
+	default:
+		return invalidBase
+	}
+}
+
+// ReverseComplement of a DNA sequence preserving case. Bases
+// other than ACGT are kept as is
+func ReverseComplement(seq string) string {
+	var b strings.Builder
+	b.Grow(len(seq))
+
+	for i := len(seq) - 1; i >= 0; i-- {
+		b.WriteByte(complementBase(seq[i]))
+	}
+
+	return b.String()
+}
+
+func complementBase(b byte) byte {
+	switch b {
+	case 'A':
+		return 'T'
+	case 'C':
+		return 'G'
+	case 'G':
+		return 'C'
+	case 'T':
+		return 'A'
+	case 'a':
+		return 't'
+	case 'c':
+		return 'g'
+	case 'g':
+		return 'c'
+	case 't':
+		return 'a'
+	default:
+		return b
+	}
+}
+
+// matrix and score distribution for scoring one strand
+type strandScorer struct {
+	strand string
+	pwm    *PWM
+	dist   *ScoreDistribution
+	// windows scoring below this cannot be significant
+	minScore float64
+}
+
+func newStrandScorers(motif *Motif, opts *ScanOptions) ([]*strandScorer, error) {
+	pwmOpts := opts.PWMOptions
+
+	if motif.Dataset != nil {
+		if bg, found := opts.datasetBackgrounds[motif.Dataset.PublicId]; found {
+			pwmOpts.Background = bg
+		}
+	}
+
+	pwm, err := motif.PWM(&pwmOpts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scorers := make([]*strandScorer, 0, 2)
+
+	if opts.Strand != StrandMinus {
+		scorers = append(scorers, newStrandScorer(StrandPlus, pwm, opts.PValue))
+	}
+
+	if opts.Strand != StrandPlus {
+		// the minus strand is scored by reading the forward
+		// sequence with the reverse complement matrix
+		scorers = append(scorers, newStrandScorer(StrandMinus, pwm.RevComp(), opts.PValue))
+	}
+
+	return scorers, nil
+}
+
+func newStrandScorer(strand string, pwm *PWM, pvalue float64) *strandScorer {
+	dist := distributionCache.Get(pwm)
+
+	return &strandScorer{strand: strand,
+		pwm:      pwm,
+		dist:     dist,
+		minScore: dist.minScoreForPValue(pvalue)}
+}
+
+// Scanner holds the scoring matrices of a set of motifs so that
+// sequences, or pieces of a long sequence such as a chromosome, can be
+// scanned without rebuilding them
+type Scanner struct {
+	motifs   []*Motif
+	scorers  [][]*strandScorer
+	opts     *ScanOptions
+	maxWidth int
+}
+
+// a window of sequence to scan. Hits are reported at offset plus
+// their position in seq and only if they start before limit, which
+// lets consecutive windows overlap by a motif width without
+// reporting hits twice.
+type scanWindow struct {
+	name   string
+	chr    string
+	seq    string
+	bases  []int8
+	offset int
+	limit  int
+}
+
+// NewScanner checks the options and builds the matrices for scanning
+// with a set of motifs
+func NewScanner(motifs []*Motif, opts *ScanOptions) (*Scanner, error) {
+	if opts == nil {
+		opts = NewScanOptions()
+	}
+
+	err := opts.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := Scanner{motifs: motifs,
+		scorers: make([][]*strandScorer, len(motifs)),
+		opts:    opts}
+
+	for i, motif := range motifs {
+		scanner.scorers[i], err = newStrandScorers(motif, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		scanner.maxWidth = max(scanner.maxWidth, len(motif.Weights))
+	}
+
+	return &scanner, nil
+}
+
+// MaxWidth is the length of the longest motif, so pieces of a long
+// sequence must overlap by MaxWidth - 1 for no hit to be missed
+func (scanner *Scanner) MaxWidth() int {
+	return scanner.maxWidth
+}
+
+// Options returns the options with defaults filled in
+func (scanner *Scanner) Options() *ScanOptions {
+	return scanner.opts
+}
+
+// ScanChr scans part of a chromosome starting at the 0-based offset.
+// Hits are given 1-based chromosome coordinates, name as their
+// sequence and are only reported if they start before the 0-based
+// position limit so that overlapping pieces do not repeat hits.
+func (scanner *Scanner) ScanChr(name string,
+	chr string,
+	seq string,
+	offset int,
+	limit int,
+	fn func(hit *ScanHit) error) error {
+
+	window := scanWindow{name: name,
+		chr:    chr,
+		seq:    seq,
+		bases:  EncodeSequence(seq, scanner.opts.SkipSoftMasked),
+		offset: offset,
+		limit:  limit}
+
+	for i := range scanner.motifs {
+		err := scanner.scanMotif(i, &window, fn)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (scanner *Scanner) scanMotif(i int, window *scanWindow, fn func(hit *ScanHit) error) error {
+	motif := scanner.motifs[i]
+	scorers := scanner.scorers[i]
+	w := len(motif.Weights)
+	bases := window.bases
+
+	for start := 0; start+w <= len(bases) && window.offset+start < window.limit; start++ {
+		bw := bases[start : start+w]
+
+		if slices.Contains(bw, invalidBase) {
+			continue
+		}
+
+		for _, scorer := range scorers {
+			score := scorer.pwm.Score(bw)
+
+			if score <= scorer.minScore {
+				continue
+			}
+
+			p := scorer.dist.PValue(score)
+
+			if p > scanner.opts.PValue {
+				continue
+			}
+
+			match := window.seq[start : start+w]
+
+			if scorer.strand == StrandMinus {
+				match = ReverseComplement(match)
+			}
+
+			err := fn(&ScanHit{Id: motif.PublicId,
+				MotifId:  motif.MotifId,
+				Name:     motif.Name,
+				Sequence: window.name,
+				Chr:      window.chr,
+				Start:    window.offset + start + 1,
+				End:      window.offset + start + w,
+				Strand:   scorer.strand,
+				Score:    score,
+				PValue:   p,
+				Match:    match})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// windows covering whole sequences, encoded once so that they can be
+// scanned with every motif
+func (scanner *Scanner) sequenceWindows(seqs []*Sequence) []*scanWindow {
+	windows := make([]*scanWindow, len(seqs))
+
+	for i, seq := range seqs {
+		windows[i] = &scanWindow{name: seq.Name,
+			seq:   seq.Seq,
+			bases: EncodeSequence(seq.Seq, scanner.opts.SkipSoftMasked),
+			limit: len(seq.Seq)}
+	}
+
+	return windows
+}
+
+// SortHits orders hits by p-value, keeping at most maxHits
+func SortHits(hits []*ScanHit, maxHits int) []*ScanHit {
+	slices.SortStableFunc(hits, func(a, b *ScanHit) int {
+		return cmp.Compare(a.PValue, b.PValue)
+	})
+
+	if len(hits) > maxHits {
+		hits = hits[:maxHits]
+	}
+
+	return hits
+}
+
+// a hit numbered in the order it was found
+type rankedHit struct {
+	hit   *ScanHit
+	order int
+}
+
+// max-heap of the best hits found so far with the worst at the top.
+// Ties are broken by the order hits were found so that keeping the
+// top hits gives the same result as SortHits.
+type hitHeap []*rankedHit
+
+func (h hitHeap) Len() int {
+	return len(h)
+}
+
+func (h hitHeap) Less(i, j int) bool {
+	return worseHit(h[i], h[j])
+}
+
+func (h hitHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *hitHeap) Push(x any) {
+	*h = append(*h, x.(*rankedHit))
+}
+
+func (h *hitHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+
+	return x
+}
+
+func worseHit(a, b *rankedHit) bool {
+	if a.hit.PValue != b.hit.PValue {
+		return a.hit.PValue > b.hit.PValue
+	}
+
+	return a.order > b.order
+}
+
+// Scan finds every occurrence of each motif in each sequence with
+// a p-value at or below the threshold. Hits are sorted by p-value and
+// only the best MaxHits are kept as the scan runs, so memory does not
+// grow with the number of hits.
+func Scan(seqs []*Sequence, motifs []*Motif, opts *ScanOptions) ([]*ScanHit, error) {
+	scanner, err := NewScanner(motifs, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(seqs) == 0 {
+		return nil, ErrNoSequences
+	}
+
+	windows := scanner.sequenceWindows(seqs)
+
+	maxHits := scanner.opts.MaxHits
+	best := make(hitHeap, 0, min(maxHits, 1024))
+	found := 0
+
+	collect := func(hit *ScanHit) error {
+		ranked := rankedHit{hit: hit, order: found}
+		found++
+
+		if len(best) < maxHits {
+			heap.Push(&best, &ranked)
+			return nil
+		}
+
+		if worseHit(best[0], &ranked) {
+			best[0] = &ranked
+			heap.Fix(&best, 0)
+		}
+
+		return nil
+	}
+
+	for i := range motifs {
+		for _, window := range windows {
+			err := scanner.scanMotif(i, window, collect)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the worst hit comes off the heap first
+	hits := make([]*ScanHit, len(best))
+
+	for i := len(hits) - 1; i >= 0; i-- {
+		hits[i] = heap.Pop(&best).(*rankedHit).hit
+	}
+
+	return hits, nil
+}
+
+// Scan sequences with motifs chosen from the database
+func (mdb *MotifDB) Scan(seqs []*Sequence,
+	selection *MotifSelection,
+	opts *ScanOptions) ([]*ScanHit, error) {
+
+	motifs, err := mdb.SelectMotifs(selection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err = mdb.withDatasetBackgrounds(motifs, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Scan(seqs, motifs, opts)
+}
+
+// withDatasetBackgrounds returns a copy of the options holding the
+// backgrounds of the datasets of the motifs if they are to be scored
+// against them, otherwise the options unchanged
+func (mdb *MotifDB) withDatasetBackgrounds(motifs []*Motif, opts *ScanOptions) (*ScanOptions, error) {
+	if opts == nil || opts.BackgroundModel != BackgroundModelDataset {
+		return opts, nil
+	}
+
+	ret := *opts
+	ret.datasetBackgrounds = make(map[string][]float64, 10)
+
+	for _, motif := range motifs {
+		if motif.Dataset == nil {
+			continue
+		}
+
+		id := motif.Dataset.PublicId
+
+		if _, found := ret.datasetBackgrounds[id]; found {
+			continue
+		}
+
+		bg, err := mdb.DatasetBackground(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret.datasetBackgrounds[id] = bg
+	}
+
+	return &ret, nil
+}
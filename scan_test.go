@@ -0,0 +1,238 @@
+package motifs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs/genome"
+	"github.com/antonybholmes/go-sys/db"
+)
+
+// GATA like motif
+func testMotif() *Motif {
+	motif := Motif{MotifId: "TEST1",
+		Weights: [][]float64{
+			{0.1, 0.1, 0.7, 0.1},
+			{0.9, 0.0, 0.05, 0.05},
+			{0.0, 0.0, 0.0, 1.0},
+			{0.8, 0.1, 0.1, 0.0},
+		}}
+
+	motif.PublicId = "test1"
+	motif.Name = "GATA"
+
+	return &motif
+}
+
+func TestScan(t *testing.T) {
+	seqs := []*Sequence{{Name: "seq1", Seq: "CCCCGATACCCCTATCCCNGATA"}}
+
+	opts := NewScanOptions()
+	opts.PValue = 0.01
+
+	hits, err := Scan(seqs, []*Motif{testMotif()}, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]bool{}
+
+	for _, hit := range hits {
+		found[hit.Strand+hit.Match] = true
+
+		if hit.Match != "GATA" {
+			t.Errorf("unexpected hit %+v", hit)
+		}
+	}
+
+	// GATA at 5, TATC (GATA on the minus strand) at 13 and GATA at 20
+	if len(hits) != 3 || !found["+GATA"] || !found["-GATA"] {
+		t.Fatalf("expected 3 hits, found %d", len(hits))
+	}
+
+	if hits[0].Start != 5 && hits[0].Start != 13 && hits[0].Start != 20 {
+		t.Fatalf("unexpected start %d", hits[0].Start)
+	}
+}
+
+func TestScanMaxHits(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	b := make([]byte, 5000)
+
+	for i := range b {
+		b[i] = "ACGT"[r.IntN(4)]
+	}
+
+	seqs := []*Sequence{{Name: "seq1", Seq: string(b)}}
+	ms := randomMotifs(5, 6)
+
+	opts := NewScanOptions()
+	opts.PValue = 0.05
+	opts.MaxHits = math.MaxInt
+
+	all, err := Scan(seqs, ms, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same := func(a, b *ScanHit) bool {
+		return *a == *b
+	}
+
+	// keeping the best as the scan runs must match sorting every hit
+	for _, maxHits := range []int{1, 10, 100, len(all) + 1} {
+		opts.MaxHits = maxHits
+
+		hits, err := Scan(seqs, ms, opts)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.EqualFunc(hits, all[:min(maxHits, len(all))], same) {
+			t.Fatalf("max hits %d: best hits differ", maxHits)
+		}
+	}
+}
+
+func TestSkipSoftMasked(t *testing.T) {
+	seqs := []*Sequence{{Name: "seq1", Seq: "CCCCgataCCCC"}}
+
+	opts := NewScanOptions()
+	opts.PValue = 0.01
+
+	hits, err := Scan(seqs, []*Motif{testMotif()}, opts)
+
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("expected 1 hit, found %d %v", len(hits), err)
+	}
+
+	opts.SkipSoftMasked = true
+
+	hits, err = Scan(seqs, []*Motif{testMotif()}, opts)
+
+	if err != nil || len(hits) != 0 {
+		t.Fatalf("expected no hits, found %d %v", len(hits), err)
+	}
+}
+
+func TestDatasetBackgrounds(t *testing.T) {
+	bg := []float64{0.3, 0.2, 0.2, 0.3}
+
+	motif := testMotif()
+	motif.Dataset = &db.Entity{PublicId: "d1"}
+
+	other := testMotif()
+	other.Dataset = &db.Entity{PublicId: "d2"}
+
+	opts := NewScanOptions()
+	opts.BackgroundModel = BackgroundModelDataset
+	opts.datasetBackgrounds = map[string][]float64{"d1": bg}
+
+	scanner, err := NewScanner([]*Motif{motif, other}, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// datasets without a background use the default
+	if !slices.Equal(scanner.scorers[0][0].pwm.Background, bg) ||
+		!slices.Equal(scanner.scorers[1][0].pwm.Background, UniformBackground) {
+		t.Fatalf("unexpected backgrounds %v %v", scanner.scorers[0][0].pwm.Background,
+			scanner.scorers[1][0].pwm.Background)
+	}
+
+	opts.BackgroundModel = "markov"
+
+	_, err = NewScanner([]*Motif{motif}, opts)
+
+	if !errors.Is(err, ErrBackgroundModel) {
+		t.Fatalf("expected background model error, found %v", err)
+	}
+}
+
+// an in memory genome
+type testGenome map[string]string
+
+func (g testGenome) Chromosomes() []*genome.Chromosome {
+	return []*genome.Chromosome{{Name: "chr1", Length: len(g["chr1"])}}
+}
+
+func (g testGenome) Seq(chr string, start int, end int) (string, error) {
+	return g[chr][start:end], nil
+}
+
+func (g testGenome) Close() error {
+	return nil
+}
+
+func TestScanGenome(t *testing.T) {
+	// GATA sites either side of and across a chunk boundary
+	seq := []byte(strings.Repeat("C", DefaultGenomeChunkSize+1000))
+
+	for _, start := range []int{10, DefaultGenomeChunkSize - 6, DefaultGenomeChunkSize - 2, DefaultGenomeChunkSize + 500} {
+		copy(seq[start:], "GATA")
+	}
+
+	reader := testGenome{"chr1": string(seq)}
+
+	opts := NewScanOptions()
+	opts.PValue = 0.01
+	opts.Strand = StrandPlus
+
+	scanner, err := NewScanner([]*Motif{testMotif()}, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starts := []int{}
+
+	err = scanner.ScanGenome(reader, nil, func(hit *ScanHit) error {
+		if hit.Chr != "chr1" || hit.Match != "GATA" {
+			t.Errorf("unexpected hit %+v", hit)
+		}
+
+		starts = append(starts, hit.Start)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{11, DefaultGenomeChunkSize - 5, DefaultGenomeChunkSize - 1, DefaultGenomeChunkSize + 501}
+
+	if !slices.Equal(starts, expected) {
+		t.Fatalf("expected hits at %v, found %v", expected, starts)
+	}
+
+	// a region in 1-based coordinates only sees the hits inside it
+	region, err := genome.ParseRegion(fmt.Sprintf("chr1:%d-%d", DefaultGenomeChunkSize-2, DefaultGenomeChunkSize+2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starts = starts[:0]
+
+	err = scanner.ScanGenome(reader, []*genome.Region{region}, func(hit *ScanHit) error {
+		starts = append(starts, hit.Start)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(starts, []int{DefaultGenomeChunkSize - 1}) {
+		t.Fatalf("unexpected hits in region %v", starts)
+	}
+}
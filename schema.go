@@ -0,0 +1,114 @@
+package motifs
+
+import (
+	"strings"
+)
+
+// Databases made by scripts/step1_motifs_db.py predate the builder and
+// have no species, counts, metadata or HOCOMOCO ids. Queries check
+// which parts of the schema exist, as for synonyms and the full text
+// index, and treat whatever is missing as unknown.
+
+type (
+	schema struct {
+		// made by the builder, see build.Build
+		builder bool
+		// class and family tables, which the builder always creates
+		// and build.AddFamilies adds to older databases
+		families bool
+	}
+)
+
+const (
+	// only the builder creates the species table
+	HasBuilderSchemaSql = `SELECT COUNT(*) FROM sqlite_master WHERE name = 'species'`
+
+	HasFamiliesSql = `SELECT COUNT(*) FROM sqlite_master WHERE name = 'motif_families'`
+
+	// WeightsSql and PageWeightsSql for databases without counts
+	LegacyWeightsSql = `SELECT
+		w.a,
+		w.c,
+		w.g,
+		w.t,
+		NULL,
+		NULL,
+		NULL,
+		NULL
+		FROM weights w
+		JOIN motifs m ON w.motif_id = m.id
+		WHERE m.public_id = :id
+		ORDER BY w.id`
+
+	LegacyPageWeightsSql = `SELECT
+		w.motif_id,
+		w.a,
+		w.c,
+		w.g,
+		w.t,
+		NULL,
+		NULL,
+		NULL,
+		NULL
+		FROM temp_page_motifs tpm
+		JOIN weights w ON tpm.id = w.motif_id
+		ORDER BY w.motif_id, w.position`
+
+	// MotifSql for databases without species or metadata
+	LegacyMotifSql = `SELECT
+		d.public_id,
+		d.name,
+		m.public_id,
+		m.motif_id,
+		m.motif_name,
+		m.length,
+		0,
+		'',
+		0,
+		0,
+		''
+		FROM motifs m
+		JOIN datasets d ON m.dataset_id = d.id
+		WHERE m.public_id = :id`
+)
+
+func readSchema(q querier) (*schema, error) {
+	var ret schema
+
+	for _, check := range []struct {
+		sql   string
+		found *bool
+	}{{HasBuilderSchemaSql, &ret.builder}, {HasFamiliesSql, &ret.families}} {
+		var n int
+
+		err := q.QueryRow(check.sql).Scan(&n)
+
+		if err != nil {
+			return nil, err
+		}
+
+		*check.found = n > 0
+	}
+
+	return &ret, nil
+}
+
+// filterSql adapts a query using MotifFilterSql to the schema. Motifs
+// in older databases have no species or grade so match no filter by
+// them.
+func (s *schema) filterSql(query string) string {
+	if s.builder {
+		return query
+	}
+
+	families := FamilyFilterSql
+
+	if !s.families {
+		families = ":all_families"
+	}
+
+	return strings.Replace(query,
+		MotifFilterSql,
+		`(:all_species AND `+families+` AND :all_grades)`,
+		1)
+}
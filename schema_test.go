@@ -0,0 +1,24 @@
+package motifs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaFilterSql(t *testing.T) {
+	if (&schema{builder: true, families: true}).filterSql(SearchSql) != SearchSql {
+		t.Fatalf("builder queries should not change")
+	}
+
+	for _, s := range []*schema{{}, {families: true}} {
+		query := s.filterSql(SearchSql)
+
+		if strings.Contains(query, "taxon_id") || strings.Contains(query, "hocomoco_motifs") {
+			t.Fatalf("query uses columns older databases lack: %s", query)
+		}
+
+		if strings.Contains(query, "motif_families") != s.families {
+			t.Fatalf("families filter %v: %s", s.families, query)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package motifs
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs/genome"
+)
+
+var (
+	ErrFasta = errors.New("sequence found before a > header")
+)
+
+// ReadFastaFile reads the sequences in a FASTA file
+func ReadFastaFile(file string) ([]*Sequence, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadFasta(f)
+}
+
+// ReadFasta reads sequences from FASTA text. Sequences are named by the
+// first word of their header line.
+func ReadFasta(r io.Reader) ([]*Sequence, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	seqs := make([]*Sequence, 0, 100)
+
+	var name string
+	var b strings.Builder
+	inSeq := false
+
+	flush := func() {
+		if inSeq {
+			seqs = append(seqs, &Sequence{Name: name, Seq: b.String()})
+		}
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, ">") {
+			flush()
+
+			name = ""
+			fields := strings.Fields(line[1:])
+
+			if len(fields) > 0 {
+				name = fields[0]
+			}
+
+			b.Reset()
+			inSeq = true
+			continue
+		}
+
+		if !inSeq {
+			return nil, ErrFasta
+		}
+
+		b.WriteString(line)
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	return seqs, nil
+}
+
+// RegionSequences reads the sequences of regions from a genome. Each
+// sequence is named after its region, or its location if the region
+// has no name.
+func RegionSequences(reader genome.Reader, regions []*genome.Region) ([]*Sequence, error) {
+	seqs := make([]*Sequence, 0, len(regions))
+
+	for _, region := range regions {
+		seq, err := reader.Seq(region.Chr, region.Start, region.End)
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := region.Name
+
+		if name == "" {
+			name = region.Location()
+		}
+
+		seqs = append(seqs, &Sequence{Name: name, Seq: seq})
+	}
+
+	return seqs, nil
+}
@@ -0,0 +1,25 @@
+package motifs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadFasta(t *testing.T) {
+	seqs, err := ReadFasta(strings.NewReader(">seq1 first\nACGT\nacgt\n\n>seq2\nNNNN\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seqs) != 2 || seqs[0].Name != "seq1" || seqs[0].Seq != "ACGTacgt" || seqs[1].Seq != "NNNN" {
+		t.Fatalf("unexpected sequences %+v %+v", seqs[0], seqs[1])
+	}
+
+	_, err = ReadFasta(strings.NewReader("ACGT\n"))
+
+	if !errors.Is(err, ErrFasta) {
+		t.Fatalf("expected FASTA error, found %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package motifs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// NCBI taxonomy of the organism a motif was derived from
+	Species struct {
+		TaxonId int    `json:"taxonId"`
+		Name    string `json:"name,omitempty"`
+	}
+)
+
+const (
+	TempSpeciesTableSql = `CREATE TEMP TABLE IF NOT EXISTS temp_species (id INTEGER PRIMARY KEY);`
+
+	ClearTempSpeciesSql = `DELETE FROM temp_species;`
+
+	InsertTempSpeciesSql = `INSERT INTO temp_species (id) VALUES (:id) ON CONFLICT DO NOTHING;`
+
+	// added to the where clause of queries over motifs m so that
+	// only motifs of the species in temp_species are returned
+	SpeciesFilterSql = `(:all_species OR m.id IN (
+		SELECT sm.id FROM motifs sm
+		JOIN temp_species ts ON sm.taxon_id = ts.id))`
+
+	PageSpeciesSql = `SELECT
+		tpm.id,
+		s.taxon_id,
+		s.name
+		FROM temp_page_motifs tpm
+		JOIN motifs m ON tpm.id = m.id
+		JOIN species s ON m.taxon_id = s.taxon_id`
+)
+
+var (
+	Human = &Species{TaxonId: 9606, Name: "Homo sapiens"}
+	Mouse = &Species{TaxonId: 10090, Name: "Mus musculus"}
+
+	ErrUnknownSpecies = errors.New("unknown species")
+
+	// common names and abbreviations of species, lowercase
+	speciesNames = map[string]*Species{
+		"human":        Human,
+		"homo sapiens": Human,
+		"hs":           Human,
+		"hsa":          Human,
+		"mouse":        Mouse,
+		"mus musculus": Mouse,
+		"mm":           Mouse,
+		"mmu":          Mouse,
+	}
+)
+
+// ParseSpecies finds a species by common name, e.g. human, scientific
+// name, e.g. Mus musculus, or NCBI taxonomy id, e.g. 9606. Other
+// taxonomy ids are accepted but will have no name.
+func ParseSpecies(s string) (*Species, error) {
+	s = strings.TrimSpace(s)
+
+	if species, found := speciesNames[strings.ToLower(s)]; found {
+		return species, nil
+	}
+
+	taxonId, err := strconv.Atoi(s)
+
+	if err != nil || taxonId < 1 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSpecies, s)
+	}
+
+	for _, species := range speciesNames {
+		if species.TaxonId == taxonId {
+			return species, nil
+		}
+	}
+
+	return &Species{TaxonId: taxonId}, nil
+}
+
+// addTempSpecies fills temp_species with the species to filter by and
+// returns true if there are none, meaning all species should be
+// returned
+func addTempSpecies(tx *sql.Tx, species []string) (bool, error) {
+	_, err := tx.Exec(TempSpeciesTableSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ClearTempSpeciesSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(species) == 0 {
+		return true, nil
+	}
+
+	stmt, err := tx.Prepare(InsertTempSpeciesSql)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+
+	for _, s := range species {
+		parsed, err := ParseSpecies(s)
+
+		if err != nil {
+			return false, err
+		}
+
+		_, err = stmt.Exec(sql.Named("id", parsed.TaxonId))
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,311 @@
+package motifs
+
+import (
+	"slices"
+	"strings"
+)
+
+type (
+	// A close match to a search term that found nothing
+	Suggestion struct {
+		Value string `json:"value"`
+		// where the value came from, see SuggestionGene etc
+		Kind string `json:"kind"`
+		// similarity in [0, 1], higher is closer
+		Score float64 `json:"score"`
+	}
+
+	suggestTerm struct {
+		value    string
+		lower    string
+		kind     string
+		trigrams []string
+	}
+)
+
+const (
+	SuggestionGene      = "gene"
+	SuggestionMotifId   = "motifId"
+	SuggestionMotifName = "motifName"
+	// another name of a gene in the database, see build.AddSynonyms
+	SuggestionAlias = "alias"
+
+	MaxSuggestions     = 10
+	MinSuggestionScore = 0.5
+
+	// terms shorter than this are too vague to suggest for
+	MinSuggestionLength = 2
+
+	// candidates whose length differs by more than this are only
+	// compared on trigrams
+	maxEditLengthDiff = 3
+
+	// names motifs can be found by, motif names that are the same as
+	// their id are skipped
+	SuggestionTermsSql = `SELECT name, '` + SuggestionGene + `' FROM genes
+		UNION ALL
+		SELECT motif_id, '` + SuggestionMotifId + `' FROM motifs
+		UNION ALL
+		SELECT motif_name, '` + SuggestionMotifName + `' FROM motifs WHERE motif_name != motif_id`
+
+	// synonyms are stored lowercase so are suggested in uppercase
+	// like human symbols. Only those of genes with motifs can find
+	// anything.
+	SuggestionAliasesSql = `SELECT DISTINCT UPPER(gs.synonym), '` + SuggestionAlias + `'
+		FROM gene_synonyms gs
+		JOIN genes g ON LOWER(g.name) = gs.symbol
+		ORDER BY 1`
+)
+
+// trigrams of a lowercase string padded so that the start and end
+// of words count for more, sorted and without duplicates
+func trigrams(s string) []string {
+	runes := []rune("  " + s + " ")
+	ret := make([]string, 0, len(runes))
+
+	for i := 0; i+3 <= len(runes); i++ {
+		ret = append(ret, string(runes[i:i+3]))
+	}
+
+	slices.Sort(ret)
+
+	return slices.Compact(ret)
+}
+
+// Jaccard similarity of two sorted trigram sets
+func trigramSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	i := 0
+	j := 0
+
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			shared++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// editDistance is the optimal string alignment distance between a
+// and b, i.e. Levenshtein distance where swapping two adjacent
+// letters counts as one edit, which is the most common typo.
+func editDistance(a, b string) int {
+	s := []rune(a)
+	t := []rune(b)
+
+	// three rows are enough since transpositions look back two
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(t)]
+}
+
+// how similar a candidate is to a lowercase query
+func suggestionScore(query string, queryTrigrams []string, term *suggestTerm) float64 {
+	score := trigramSimilarity(queryTrigrams, term.trigrams)
+
+	n := len([]rune(query))
+	m := len([]rune(term.lower))
+
+	if strings.HasPrefix(term.lower, query) {
+		// the query is the start of a longer name
+		score = max(score, 0.5+0.5*float64(n)/float64(m))
+	}
+
+	if n-m <= maxEditLengthDiff && m-n <= maxEditLengthDiff {
+		d := editDistance(query, term.lower)
+
+		score = max(score, 1-float64(d)/float64(max(n, m)))
+	}
+
+	return score
+}
+
+// names motifs can be found by, loaded on first use. Loading is
+// retried if it fails.
+func (mdb *MotifDB) suggestionTerms() ([]*suggestTerm, error) {
+	mdb.termsLock.Lock()
+	defer mdb.termsLock.Unlock()
+
+	if mdb.terms != nil {
+		return mdb.terms, nil
+	}
+
+	terms, err := loadSuggestionTerms(mdb.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mdb.terms = terms
+
+	return terms, nil
+}
+
+func loadSuggestionTerms(q querier) ([]*suggestTerm, error) {
+	queries := []string{SuggestionTermsSql}
+
+	var n int
+
+	err := q.QueryRow(HasSynonymsSql).Scan(&n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n > 0 {
+		queries = append(queries, SuggestionAliasesSql)
+	}
+
+	terms := make([]*suggestTerm, 0, 10000)
+	seen := make(map[string]struct{}, 10000)
+
+	for _, query := range queries {
+		rows, err := q.Query(query)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var term suggestTerm
+
+			err := rows.Scan(&term.value, &term.kind)
+
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			term.lower = strings.ToLower(term.value)
+
+			// genes are loaded first so a motif named after a gene is
+			// suggested as the gene, and aliases last so they are
+			// only suggested if they name nothing else
+			if _, ok := seen[term.lower]; ok {
+				continue
+			}
+
+			seen[term.lower] = struct{}{}
+
+			term.trigrams = trigrams(term.lower)
+
+			terms = append(terms, &term)
+		}
+
+		err = rows.Err()
+
+		rows.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return terms, nil
+}
+
+// Suggest finds gene names, motif ids and motif names that are close
+// to the queries, for example to correct typos in a search that
+// found nothing. At most n suggestions are returned, best first.
+func (mdb *MotifDB) Suggest(queries []string, n int) ([]*Suggestion, error) {
+	terms, err := mdb.suggestionTerms()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Suggestion, 0, n)
+	seen := make(map[string]int, n)
+
+	for _, q := range queries {
+		q = strings.ToLower(strings.TrimSpace(q))
+
+		if len([]rune(q)) < MinSuggestionLength {
+			continue
+		}
+
+		qt := trigrams(q)
+
+		for _, term := range terms {
+			// exact matches are not suggestions
+			if term.lower == q {
+				continue
+			}
+
+			score := suggestionScore(q, qt, term)
+
+			if score < MinSuggestionScore {
+				continue
+			}
+
+			// keep the best score if several queries suggest the
+			// same term
+			if i, ok := seen[term.lower]; ok {
+				ret[i].Score = max(ret[i].Score, score)
+				continue
+			}
+
+			seen[term.lower] = len(ret)
+			ret = append(ret, &Suggestion{Value: term.value, Kind: term.kind, Score: score})
+		}
+	}
+
+	// best first, then shortest since it is more likely to be what
+	// was meant
+	slices.SortStableFunc(ret, func(a, b *Suggestion) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		case len(a.Value) != len(b.Value):
+			return len(a.Value) - len(b.Value)
+		default:
+			return strings.Compare(a.Value, b.Value)
+		}
+	})
+
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,116 @@
+package motifs
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-sys/db"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+	}{
+		{"sox2", "sox2", 0},
+		{"sox2", "sxo2", 1},
+		{"gata1", "gatta1", 1},
+		{"klf4", "klf", 1},
+		{"", "abc", 3},
+		{"nfkb1", "rela", 5},
+	}
+
+	for _, test := range tests {
+		d := editDistance(test.a, test.b)
+
+		if d != test.d {
+			t.Fatalf("distance from %s to %s is %d not %d", test.a, test.b, d, test.d)
+		}
+	}
+
+	if trigramSimilarity(trigrams("sox2"), trigrams("sox2")) != 1 {
+		t.Fatalf("identical trigrams should be 1")
+	}
+
+	if trigramSimilarity(trigrams("sox2"), trigrams("gata1")) != 0 {
+		t.Fatalf("unrelated trigrams should be 0")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+
+	db := NewMotifDB("../data/modules/motifs/motifs.db")
+
+	datasets, err := db.Datasets()
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]string, 0, len(datasets))
+
+	for _, dataset := range datasets {
+		ids = append(ids, dataset.PublicId)
+	}
+
+	page := Paging{Page: 1, PageSize: 10}
+
+	// typo so nothing matches
+	res, err := db.Search([]string{"GATTA1"}, ids, nil, &page, false)
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if res.Total != 0 || len(res.Suggestions) == 0 {
+		t.Fatalf("expected suggestions not %d motifs", res.Total)
+	}
+
+	if !strings.EqualFold(res.Suggestions[0].Value, "GATA1") {
+		t.Fatalf("best suggestion is %s", res.Suggestions[0].Value)
+	}
+}
+
+func TestSuggestionTermsRetry(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "motifs.db")
+
+	mdb := NewMotifDB(file)
+
+	// the database does not exist yet
+	_, err := mdb.suggestionTerms()
+
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	conn, err := sql.Open(db.Sqlite3DB, file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Exec(`CREATE TABLE genes (name TEXT);
+		CREATE TABLE motifs (motif_id TEXT, motif_name TEXT);
+		CREATE TABLE gene_synonyms (symbol TEXT, synonym TEXT);
+		INSERT INTO genes VALUES ('POU5F1');
+		INSERT INTO motifs VALUES ('MA1115.1', 'POU5F1');
+		INSERT INTO gene_synonyms VALUES ('pou5f1', 'oct4'), ('sox2', 'sox-2');`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suggestions, err := mdb.Suggest([]string{"OCT3"}, MaxSuggestions)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(suggestions) == 0 || suggestions[0].Value != "OCT4" || suggestions[0].Kind != SuggestionAlias {
+		t.Fatalf("unexpected suggestions %v", suggestions)
+	}
+}
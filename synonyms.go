@@ -0,0 +1,188 @@
+package motifs
+
+import (
+	"database/sql"
+)
+
+type (
+	// Why a motif was found by a search term that is not in its name,
+	// e.g. searching OCT4 finds motifs of POU5F1
+	AliasMatch struct {
+		// the search term as given
+		Query string `json:"query"`
+		// the gene of the motif the term is an alias of
+		Gene string `json:"gene"`
+	}
+)
+
+const (
+	// only in databases where synonyms have been loaded, see
+	// build.AddSynonyms
+	HasSynonymsSql = `SELECT COUNT(*) FROM sqlite_master WHERE name = 'gene_synonyms'`
+
+	// lowercase names that each query is an alias of
+	TempAliasesTableSql = `CREATE TEMP TABLE IF NOT EXISTS temp_aliases (
+		query TEXT NOT NULL,
+		alias TEXT NOT NULL,
+		PRIMARY KEY (query, alias)
+	);`
+
+	ClearTempAliasesSql = `DELETE FROM temp_aliases;`
+
+	// every other name of the genes a query is a symbol or synonym
+	// of, so symbols expand to their synonyms and synonyms to their
+	// symbol and the other synonyms
+	InsertTempAliasesSql = `WITH symbols AS (
+			SELECT symbol FROM gene_synonyms
+			WHERE symbol = LOWER(:query) OR synonym = LOWER(:query))
+		INSERT INTO temp_aliases (query, alias)
+		SELECT :query, a.name FROM (
+			SELECT symbol AS name FROM symbols
+			UNION
+			SELECT synonym AS name FROM gene_synonyms
+			WHERE symbol IN (SELECT symbol FROM symbols)
+		) AS a
+		WHERE a.name != LOWER(:query)
+		ON CONFLICT DO NOTHING;`
+
+	// genes of the current page that were found through an alias
+	PageAliasesSql = `SELECT
+		m.public_id,
+		ta.query,
+		g.name
+		FROM temp_page_motifs tpm
+		JOIN motifs m ON tpm.id = m.id
+		JOIN motif_genes mg ON m.id = mg.motif_id
+		JOIN genes g ON mg.gene_id = g.id
+		JOIN temp_aliases ta ON LOWER(g.name) = ta.alias
+		ORDER BY
+			m.public_id,
+			ta.query,
+			g.name`
+
+	// genes in the database each query is an alias of
+	QueryAliasesSql = `SELECT DISTINCT
+		ta.query,
+		g.name
+		FROM temp_aliases ta
+		JOIN genes g ON LOWER(g.name) = ta.alias
+		ORDER BY
+			ta.query,
+			g.name`
+)
+
+// addTempAliases fills temp_aliases with the other names of the genes
+// each query names. The table is always created so that searches can
+// join it, but is left empty if the database has no synonyms.
+func addTempAliases(tx *sql.Tx, queries []string) error {
+	_, err := tx.Exec(TempAliasesTableSql)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ClearTempAliasesSql)
+
+	if err != nil {
+		return err
+	}
+
+	var n int
+
+	err = tx.QueryRow(HasSynonymsSql).Scan(&n)
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(InsertTempAliasesSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, q := range queries {
+		_, err := stmt.Exec(sql.Named("query", q))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPageAliases records which alias found each motif in the page
+// last passed to addGenesAndWeights
+func addPageAliases(tx *sql.Tx, result *MotifSearchResult) error {
+	if len(result.Motifs) == 0 {
+		return nil
+	}
+
+	index := make(map[string][]*Motif, len(result.Motifs))
+
+	for _, motif := range result.Motifs {
+		index[motif.PublicId] = append(index[motif.PublicId], motif)
+	}
+
+	rows, err := tx.Query(PageAliasesSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var publicId string
+	var q string
+	var gene string
+
+	for rows.Next() {
+		err := rows.Scan(&publicId, &q, &gene)
+
+		if err != nil {
+			return err
+		}
+
+		for _, motif := range index[publicId] {
+			motif.Aliases = append(motif.Aliases, &AliasMatch{Query: q, Gene: gene})
+		}
+	}
+
+	return rows.Err()
+}
+
+// queryAliases maps each query to the genes in the database it is an
+// alias of
+func queryAliases(tx *sql.Tx) (map[string][]string, error) {
+	rows, err := tx.Query(QueryAliasesSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ret := make(map[string][]string, 10)
+
+	var q string
+	var gene string
+
+	for rows.Next() {
+		err := rows.Scan(&q, &gene)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret[q] = append(ret[q], gene)
+	}
+
+	return ret, rows.Err()
+}
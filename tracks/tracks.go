@@ -0,0 +1,183 @@
+// Package tracks writes motif scan hits as genome browser tracks and
+// FIMO style tables
+package tracks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+type (
+	// HitWriter writes hits one at a time so that genome scans can be
+	// streamed. Flush must be called once all hits are written.
+	HitWriter struct {
+		bw    *bufio.Writer
+		write func(bw *bufio.Writer, hit *motifs.ScanHit) error
+	}
+)
+
+const (
+	FormatTSV  = "tsv"
+	FormatBed  = "bed"
+	FormatGFF3 = "gff3"
+
+	FimoHeader = "motif_id\tmotif_alt_id\tsequence_name\tstart\tstop\tstrand\tscore\tp-value\tq-value\tmatched_sequence"
+
+	// GFF3 source and sequence ontology type of hits
+	GFF3Source = "go-motifs"
+	GFF3Type   = "TF_binding_site"
+
+	// BED scores are -log10(p) times this, capped at 1000, so that a
+	// p-value of 1e-10 or less is the darkest shade in a browser
+	BedScoreScale = 100
+)
+
+var (
+	ErrFormat = errors.New("track format must be tsv, bed or gff3")
+
+	// reserved characters in GFF3 attribute values
+	gff3Escaper = strings.NewReplacer("%", "%25",
+		";", "%3B",
+		"=", "%3D",
+		"&", "%26",
+		",", "%2C",
+		"\t", "%09",
+		"\n", "%0A")
+)
+
+// NewHitWriter creates a writer for a format, writing any header the
+// format needs
+func NewHitWriter(w io.Writer, format string) (*HitWriter, error) {
+	hw := HitWriter{bw: bufio.NewWriter(w)}
+
+	switch strings.ToLower(format) {
+	case FormatTSV, "fimo", "":
+		hw.write = writeTSV
+		fmt.Fprintln(hw.bw, FimoHeader)
+	case FormatBed:
+		hw.write = writeBed
+	case FormatGFF3, "gff":
+		hw.write = writeGFF3
+		fmt.Fprintln(hw.bw, "##gff-version 3")
+	default:
+		return nil, ErrFormat
+	}
+
+	return &hw, nil
+}
+
+func (hw *HitWriter) Write(hit *motifs.ScanHit) error {
+	return hw.write(hw.bw, hit)
+}
+
+func (hw *HitWriter) Flush() error {
+	return hw.bw.Flush()
+}
+
+// WriteHits writes hits in one of the track formats
+func WriteHits(w io.Writer, format string, hits []*motifs.ScanHit) error {
+	hw, err := NewHitWriter(w, format)
+
+	if err != nil {
+		return err
+	}
+
+	for _, hit := range hits {
+		err = hw.Write(hit)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return hw.Flush()
+}
+
+// the chromosome of a hit, or the sequence it was found in if it was
+// not scanned from a genome
+func seqName(hit *motifs.ScanHit) string {
+	if hit.Chr != "" {
+		return hit.Chr
+	}
+
+	return hit.Sequence
+}
+
+// BedName labels hits by motif id and, if it is different, the motif
+// name, which is usually the gene, e.g. MA0035.4/GATA1
+func BedName(hit *motifs.ScanHit) string {
+	if hit.Name == "" || hit.Name == hit.MotifId {
+		return hit.MotifId
+	}
+
+	return hit.MotifId + "/" + hit.Name
+}
+
+// BedScore scales a p-value to the 0 to 1000 range of BED scores
+func BedScore(pvalue float64) int {
+	if pvalue <= 0 {
+		return 1000
+	}
+
+	return min(max(int(math.Round(-math.Log10(pvalue)*BedScoreScale)), 0), 1000)
+}
+
+// columns as FIMO writes them. FIMO gives q-values for whole runs so
+// that column is left empty as hits are streamed.
+func writeTSV(bw *bufio.Writer, hit *motifs.ScanHit) error {
+	_, err := fmt.Fprintf(bw, "%s\t%s\t%s\t%d\t%d\t%s\t%.6g\t%.3g\t\t%s\n",
+		hit.MotifId,
+		hit.Name,
+		seqName(hit),
+		hit.Start,
+		hit.End,
+		hit.Strand,
+		hit.Score,
+		hit.PValue,
+		hit.Match)
+
+	return err
+}
+
+// BED6 with 0-based starts
+func writeBed(bw *bufio.Writer, hit *motifs.ScanHit) error {
+	_, err := fmt.Fprintf(bw, "%s\t%d\t%d\t%s\t%d\t%s\n",
+		seqName(hit),
+		hit.Start-1,
+		hit.End,
+		BedName(hit),
+		BedScore(hit.PValue),
+		hit.Strand)
+
+	return err
+}
+
+func writeGFF3(bw *bufio.Writer, hit *motifs.ScanHit) error {
+	attributes := []string{"Name=" + gff3Escaper.Replace(BedName(hit)),
+		"motif=" + gff3Escaper.Replace(hit.Id),
+		fmt.Sprintf("pvalue=%.3g", hit.PValue),
+		"sequence=" + gff3Escaper.Replace(hit.Match)}
+
+	// the region or sequence scanned, e.g. a peak id
+	if hit.Chr != "" && hit.Sequence != "" {
+		attributes = append(attributes, "region="+gff3Escaper.Replace(hit.Sequence))
+	}
+
+	_, err := fmt.Fprintf(bw, "%s\t%s\t%s\t%d\t%d\t%.6g\t%s\t.\t%s\n",
+		gff3Escaper.Replace(seqName(hit)),
+		GFF3Source,
+		GFF3Type,
+		hit.Start,
+		hit.End,
+		hit.Score,
+		hit.Strand,
+		strings.Join(attributes, ";"))
+
+	return err
+}
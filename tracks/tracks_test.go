@@ -0,0 +1,83 @@
+package tracks
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+func testHits() []*motifs.ScanHit {
+	return []*motifs.ScanHit{{Id: "abc123",
+		MotifId:  "MA0035.4",
+		Name:     "GATA1",
+		Sequence: "peak;1",
+		Chr:      "chr1",
+		Start:    101,
+		End:      104,
+		Strand:   "-",
+		Score:    8.5,
+		PValue:   1e-5,
+		Match:    "GATA"}}
+}
+
+func TestWriteHits(t *testing.T) {
+	var buf strings.Builder
+
+	err := WriteHits(&buf, FormatBed, testHits())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "chr1\t100\t104\tMA0035.4/GATA1\t500\t-\n" {
+		t.Fatalf("unexpected BED %q", buf.String())
+	}
+
+	buf.Reset()
+
+	err = WriteHits(&buf, FormatTSV, testHits())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if lines[0] != FimoHeader || lines[1] != "MA0035.4\tGATA1\tchr1\t101\t104\t-\t8.5\t1e-05\t\tGATA" {
+		t.Fatalf("unexpected TSV %q", buf.String())
+	}
+
+	buf.Reset()
+
+	err = WriteHits(&buf, FormatGFF3, testHits())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if lines[0] != "##gff-version 3" ||
+		lines[1] != "chr1\tgo-motifs\tTF_binding_site\t101\t104\t8.5\t-\t.\tName=MA0035.4/GATA1;motif=abc123;pvalue=1e-05;sequence=GATA;region=peak%3B1" {
+		t.Fatalf("unexpected GFF3 %q", buf.String())
+	}
+
+	err = WriteHits(&buf, "wig", testHits())
+
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("expected format error, found %v", err)
+	}
+}
+
+func TestBedScore(t *testing.T) {
+	for _, test := range []struct {
+		pvalue float64
+		score  int
+	}{{1, 0}, {1e-4, 400}, {1e-12, 1000}, {0, 1000}} {
+		if score := BedScore(test.pvalue); score != test.score {
+			t.Errorf("p-value %g: expected %d, found %d", test.pvalue, test.score, score)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package transfac
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+type (
+	// A TRANSFAC matrix and the fields describing it
+	Motif struct {
+		*motifs.Motif
+		// AC, used as the motif id
+		Accession string `json:"accession,omitempty"`
+		// ID, used as the motif name
+		Id          string `json:"id,omitempty"`
+		Description string `json:"description,omitempty"`
+		// one entry per BF line, e.g.
+		// T00526; MyoD; Species: mouse, Mus musculus.
+		Factors []string `json:"factors,omitempty"`
+		// IUPAC letters given at the end of each P0 row
+		Consensus string `json:"consensus,omitempty"`
+	}
+
+	// ParseError reports the line in the file where parsing failed
+	ParseError struct {
+		Line int
+		Err  error
+	}
+)
+
+const (
+	DNAAlphabet = "ACGT"
+
+	// rows summing to no more than this are taken to be
+	// probabilities rather than counts
+	probabilitySum = 1.01
+)
+
+var (
+	ErrMatrixHeader = errors.New("malformed P0 header")
+	ErrMatrixRow    = errors.New("malformed matrix row")
+	ErrNoMatrix     = errors.New("entry has no matrix")
+	ErrMotifId      = errors.New("entry has no AC or ID")
+)
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("transfac: line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func ReadFile(file string) ([]*Motif, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read parses TRANSFAC matrix entries, which are made of lines
+// starting with a two letter code and end with //, e.g.
+//
+//	AC  M00001
+//	XX
+//	ID  V$MYOD_01
+//	XX
+//	BF  T00526; MyoD; Species: mouse, Mus musculus.
+//	XX
+//	P0      A      C      G      T
+//	01      1      2      2      0      S
+//	XX
+//	//
+//
+// Columns follow the order of the P0 (or PO) header. Rows of counts
+// are kept as Counts, while rows of probabilities only set Weights.
+// The accession becomes MotifId and the ID, or NA if there is no ID,
+// the Name. Fields other than AC, ID, NA, DE and BF are ignored.
+func Read(r io.Reader) ([]*Motif, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	ret := make([]*Motif, 0, 100)
+
+	var current *Motif = nil
+	// base index of each matrix column, nil outside a matrix
+	var columns []int
+	var rows [][]float64
+	var consensus strings.Builder
+	entryLine := 0
+	line := 0
+
+	finishEntry := func() error {
+		if current == nil {
+			return nil
+		}
+
+		// blocks such as the VV version header are not matrices
+		if current.Accession == "" && current.Id == "" && len(rows) == 0 {
+			current = nil
+			return nil
+		}
+
+		err := finishMotif(current, rows, consensus.String())
+
+		if err != nil {
+			return &ParseError{Line: entryLine, Err: err}
+		}
+
+		ret = append(ret, current)
+		current = nil
+
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if current == nil {
+			current = &Motif{Motif: &motifs.Motif{}}
+			columns = nil
+			rows = make([][]float64, 0, 20)
+			consensus.Reset()
+			entryLine = line
+		}
+
+		tag := strings.TrimSpace(text)
+		value := ""
+
+		if i := strings.IndexAny(tag, " \t"); i != -1 {
+			value = strings.TrimSpace(tag[i:])
+			tag = tag[:i]
+		}
+
+		switch {
+		case tag == "//":
+			err := finishEntry()
+
+			if err != nil {
+				return nil, err
+			}
+
+		case tag == "P0" || tag == "PO":
+			var err error
+
+			columns, err = parseColumns(value)
+
+			if err != nil {
+				return nil, &ParseError{Line: line, Err: err}
+			}
+
+		case columns != nil && isRowTag(tag):
+			row, letter, err := parseRow(value, columns)
+
+			if err != nil {
+				return nil, &ParseError{Line: line, Err: err}
+			}
+
+			rows = append(rows, row)
+
+			if letter != 0 {
+				consensus.WriteByte(letter)
+			}
+
+		default:
+			// any other field ends the matrix
+			columns = nil
+
+			switch tag {
+			case "AC":
+				current.Accession = value
+			case "ID":
+				current.Id = value
+			case "NA":
+				if current.Name == "" {
+					current.Name = value
+				}
+			case "DE":
+				current.Description = value
+			case "BF":
+				current.Factors = append(current.Factors, value)
+			}
+		}
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the last entry need not end with //
+	err = finishEntry()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// row tags are position numbers such as 01
+func isRowTag(tag string) bool {
+	_, err := strconv.Atoi(tag)
+
+	return err == nil
+}
+
+func parseColumns(value string) ([]int, error) {
+	tokens := strings.Fields(value)
+
+	if len(tokens) != len(DNAAlphabet) {
+		return nil, fmt.Errorf("%w: expected %d bases, found %d", ErrMatrixHeader, len(DNAAlphabet), len(tokens))
+	}
+
+	columns := make([]int, len(tokens))
+	seen := make(map[int]struct{}, len(tokens))
+
+	for i, token := range tokens {
+		idx := strings.Index(DNAAlphabet, strings.ToUpper(token))
+
+		if len(token) != 1 || idx == -1 {
+			return nil, fmt.Errorf("%w: unknown base %s", ErrMatrixHeader, token)
+		}
+
+		if _, found := seen[idx]; found {
+			return nil, fmt.Errorf("%w: repeated base %s", ErrMatrixHeader, token)
+		}
+
+		seen[idx] = struct{}{}
+		columns[i] = idx
+	}
+
+	return columns, nil
+}
+
+// parse the values of a matrix row in a, c, g, t order and the
+// optional consensus letter after them
+func parseRow(value string, columns []int) ([]float64, byte, error) {
+	tokens := strings.Fields(value)
+
+	if len(tokens) != len(columns) && len(tokens) != len(columns)+1 {
+		return nil, 0, fmt.Errorf("%w: expected %d columns, found %d", ErrMatrixRow, len(columns), len(tokens))
+	}
+
+	row := make([]float64, len(DNAAlphabet))
+
+	for i, idx := range columns {
+		v, err := strconv.ParseFloat(tokens[i], 64)
+
+		if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, 0, fmt.Errorf("%w: bad value %s", ErrMatrixRow, tokens[i])
+		}
+
+		row[idx] = v
+	}
+
+	var letter byte
+
+	if len(tokens) > len(columns) {
+		letter = strings.ToUpper(tokens[len(columns)])[0]
+	}
+
+	return row, letter, nil
+}
+
+func finishMotif(motif *Motif, rows [][]float64, consensus string) error {
+	switch {
+	case motif.Accession != "":
+		motif.MotifId = motif.Accession
+	case motif.Id != "":
+		motif.MotifId = motif.Id
+	default:
+		return ErrMotifId
+	}
+
+	if motif.Id != "" {
+		motif.Name = motif.Id
+	} else if motif.Name == "" {
+		motif.Name = motif.MotifId
+	}
+
+	if len(rows) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoMatrix, motif.MotifId)
+	}
+
+	if len(consensus) == len(rows) {
+		motif.Consensus = consensus
+	}
+
+	probabilities := true
+
+	for _, row := range rows {
+		sum := 0.0
+
+		for _, v := range row {
+			sum += v
+		}
+
+		if sum > probabilitySum {
+			probabilities = false
+			break
+		}
+	}
+
+	if !probabilities {
+		return motif.SetCounts(rows)
+	}
+
+	weights, err := motifs.NormalizeWeights(rows)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", motif.MotifId, err)
+	}
+
+	motif.Weights = weights
+
+	return nil
+}
+
+// NewMotif converts a motif to TRANSFAC format. The genes, and species
+// if known, are listed as binding factors.
+func NewMotif(motif *motifs.Motif) *Motif {
+	ret := Motif{Motif: motif,
+		Accession: motif.MotifId,
+		Id:        motif.Name,
+		Factors:   make([]string, 0, len(motif.Genes))}
+
+	for _, gene := range motif.Genes {
+		if motif.Species != nil && motif.Species.Name != "" {
+			ret.Factors = append(ret.Factors, fmt.Sprintf("%s; Species: %s.", gene, motif.Species.Name))
+		} else {
+			ret.Factors = append(ret.Factors, gene)
+		}
+	}
+
+	return &ret
+}
+
+// Write writes motifs as TRANSFAC entries with a P0 matrix of counts,
+// estimated from the weights if the motif has none
+func Write(w io.Writer, ms []*Motif) error {
+	bw := bufio.NewWriter(w)
+
+	for _, motif := range ms {
+		err := writeMotif(bw, motif)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteMotifs writes motifs in TRANSFAC format, see NewMotif
+func WriteMotifs(w io.Writer, ms []*motifs.Motif) error {
+	transfacMotifs := make([]*Motif, 0, len(ms))
+
+	for _, motif := range ms {
+		transfacMotifs = append(transfacMotifs, NewMotif(motif))
+	}
+
+	return Write(w, transfacMotifs)
+}
+
+func writeMotif(bw *bufio.Writer, motif *Motif) error {
+	accession := motif.Accession
+
+	if accession == "" {
+		accession = motif.MotifId
+	}
+
+	id := motif.Id
+
+	if id == "" {
+		id = motif.Name
+	}
+
+	if accession == "" && id == "" {
+		return ErrMotifId
+	}
+
+	if len(motif.Weights) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoMatrix, accession)
+	}
+
+	if accession != "" {
+		fmt.Fprintf(bw, "AC  %s\nXX\n", accession)
+	}
+
+	if id != "" {
+		fmt.Fprintf(bw, "ID  %s\nXX\n", id)
+	}
+
+	if motif.Description != "" {
+		fmt.Fprintf(bw, "DE  %s\nXX\n", motif.Description)
+	}
+
+	for _, factor := range motif.Factors {
+		fmt.Fprintf(bw, "BF  %s\n", factor)
+	}
+
+	if len(motif.Factors) > 0 {
+		bw.WriteString("XX\n")
+	}
+
+	consensus := motif.Consensus
+
+	if len(consensus) != len(motif.Weights) {
+		consensus = motif.Motif.Consensus()
+	}
+
+	bw.WriteString("P0      A      C      G      T\n")
+
+	for i, row := range motif.CountMatrix() {
+		if len(row) != len(DNAAlphabet) {
+			return fmt.Errorf("%w: %s", ErrMatrixRow, accession)
+		}
+
+		fmt.Fprintf(bw, "%02d", i+1)
+
+		for _, v := range row {
+			fmt.Fprintf(bw, " %6s", formatFloat(v))
+		}
+
+		fmt.Fprintf(bw, "      %c\n", consensus[i])
+	}
+
+	bw.WriteString("XX\n//\n")
+
+	return nil
+}
+
+// shortest representation that parses back to the same value,
+// without exponents since counts can be large
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,149 @@
+package transfac
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/antonybholmes/go-motifs"
+)
+
+const testTransfac = `VV  TRANSFAC MATRIX TABLE
+XX
+//
+AC  M00001
+XX
+ID  V$MYOD_01
+XX
+DE  myoD
+XX
+BF  T00526; MyoD; Species: mouse, Mus musculus.
+XX
+P0      A      C      G      T
+01      1      2      2      0      S
+02      2      1      2      0      R
+03      3      0      1      1      A
+04      0      5      0      0      C
+XX
+//
+AC  MA0004.1
+XX
+ID  Arnt
+XX
+PO      T      G      C      A
+01      0.0    0.0    0.8    0.2
+02      0.05   0.05   0.0    0.9
+XX
+//
+`
+
+func TestRead(t *testing.T) {
+	ms, err := Read(strings.NewReader(testTransfac))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms) != 2 {
+		t.Fatalf("expected 2 motifs, found %d", len(ms))
+	}
+
+	m := ms[0]
+
+	if m.MotifId != "M00001" || m.Name != "V$MYOD_01" || m.Description != "myoD" || len(m.Factors) != 1 {
+		t.Fatalf("bad motif %+v", m)
+	}
+
+	if m.Consensus != "SRAC" || m.Counts[3][1] != 5 || m.NSites != 5 || m.Weights[0][1] != 0.4 {
+		t.Fatalf("bad matrix %+v", m)
+	}
+
+	// probabilities in a different column order
+	m = ms[1]
+
+	if m.Counts != nil || m.Weights[0][1] != 0.8 || m.Weights[1][0] != 0.9 {
+		t.Fatalf("bad matrix %+v", m)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ms, err := Read(strings.NewReader(testTransfac))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err = Write(&buf, ms)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms2, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := ms[0]
+	m2 := ms2[0]
+
+	if m.Accession != m2.Accession || m.Id != m2.Id || m.Factors[0] != m2.Factors[0] || m.Consensus != m2.Consensus {
+		t.Fatalf("motif differs: %+v %+v", m, m2)
+	}
+
+	for j, row := range m.Counts {
+		for k, v := range row {
+			if m2.Counts[j][k] != v {
+				t.Fatalf("count %d,%d differs", j, k)
+			}
+		}
+	}
+}
+
+func TestWriteMotifs(t *testing.T) {
+	m := motifs.Motif{MotifId: "MA0001.1",
+		Genes:   []string{"GATA1"},
+		Species: motifs.Human,
+		NSites:  10,
+		Weights: [][]float64{{0.1, 0.1, 0.7, 0.1}, {0.9, 0, 0.05, 0.05}}}
+
+	m.Name = "GATA1"
+
+	var buf bytes.Buffer
+
+	err := WriteMotifs(&buf, []*motifs.Motif{&m})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := Read(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms[0].Factors[0] != "GATA1; Species: Homo sapiens." || ms[0].Counts[0][2] != 7 || ms[0].Consensus != "GA" {
+		t.Fatalf("bad motif %+v", ms[0])
+	}
+}
+
+func TestMalformedRow(t *testing.T) {
+	bad := strings.Replace(testTransfac, "03      3      0      1      1      A", "03      3      0      1", 1)
+
+	_, err := Read(strings.NewReader(bad))
+
+	var pe *ParseError
+
+	if !errors.As(err, &pe) || !errors.Is(err, ErrMatrixRow) {
+		t.Fatalf("expected matrix row error, found %v", err)
+	}
+
+	if pe.Line != 15 {
+		t.Fatalf("expected error on line 15, found %d", pe.Line)
+	}
+}